@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/astjson"
+	"monkey/internal/dot"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runAST prints the syntax tree of a file as an indented outline, with
+// --json in the encoding of package astjson or with --dot as a Graphviz
+// graph. The tree of a file with syntax errors is printed too, but the
+// command fails.
+func runAST(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey ast [--json | --dot] [file]")
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print the tree as JSON")
+	asDot := flags.Bool("dot", false, "print the tree as a graph in the DOT language")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *asJSON && *asDot {
+		fmt.Fprintln(os.Stderr, "ast: --json and --dot cannot be used together")
+		return 2
+	}
+
+	name, src, status := readSource("ast", flags)
+	if status != 0 {
+		return status
+	}
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	switch {
+	case *asJSON:
+		data, err := astjson.MarshalIndent(program, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ast: %s\n", err)
+			return 1
+		}
+
+		fmt.Println(string(data))
+	case *asDot:
+		if err := dot.AST(os.Stdout, program); err != nil {
+			fmt.Fprintf(os.Stderr, "ast: %s\n", err)
+			return 1
+		}
+	default:
+		printOutline(program)
+	}
+
+	for _, d := range p.Diagnostics() {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+		status = 1
+	}
+
+	return status
+}
+
+// printOutline prints a line for every node, indented by its depth, with
+// its kind, position and token.
+func printOutline(program *ast.Program) {
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+
+		line := strings.Repeat("  ", depth) + strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+		if _, program := node.(*ast.Program); !program {
+			tok := ast.TokenOf(node)
+			line += " " + tok.Pos.String() + " " + strconv.Quote(tok.Literal)
+		}
+		fmt.Println(line)
+
+		depth++
+		return true
+	})
+}
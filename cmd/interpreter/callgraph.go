@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/internal/callgraph"
+	"monkey/internal/dot"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"os"
+)
+
+// runCallgraph prints which let-bound functions of a file call which, one
+// edge per line or, with --dot, as a Graphviz graph.
+func runCallgraph(args []string) int {
+	flags := flag.NewFlagSet("callgraph", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey callgraph [--dot] [file]")
+		flags.PrintDefaults()
+	}
+	asDot := flags.Bool("dot", false, "print the call graph in the DOT language")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	name, src, status := readSource("callgraph", flags)
+	if status != 0 {
+		return status
+	}
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if diags := p.Diagnostics(); len(diags) > 0 {
+		for _, d := range diags {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+		}
+		return 1
+	}
+
+	r := resolver.New(eval.BuiltinNames())
+	r.Resolve(program)
+	g := callgraph.Build(program, r)
+
+	if *asDot {
+		if err := dot.CallGraph(os.Stdout, g); err != nil {
+			fmt.Fprintf(os.Stderr, "callgraph: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, e := range g.Edges {
+		caller := "<top level>"
+		if e.Caller != nil {
+			caller = e.Caller.Name
+		}
+
+		verb := "calls"
+		if !e.Call {
+			verb = "uses"
+		}
+
+		fmt.Printf("%s %s %s\n", caller, verb, e.Callee.Name)
+	}
+
+	return 0
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/internal/diagnostic"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"monkey/internal/types"
+	"os"
+	"sort"
+)
+
+// runCheck type checks the files named in args without running them. It
+// fails if any of them has an error.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey check file ...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "check: %s\n", err)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			r := resolver.New(eval.BuiltinNames())
+			diags = r.Resolve(program)
+			if !diagnostic.HasErrors(diags) {
+				diags = append(diags, types.New(r).Check(program)...)
+			}
+		}
+
+		sort.SliceStable(diags, func(i, j int) bool {
+			return diags[i].Pos.Before(diags[j].Pos)
+		})
+
+		for _, d := range diags {
+			fmt.Printf("%s:%s\n", name, d)
+			if d.Severity == diagnostic.Error {
+				status = 1
+			}
+		}
+	}
+
+	return status
+}
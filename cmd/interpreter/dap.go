@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"monkey/internal/dap"
+	"os"
+)
+
+// runDap serves the Debug Adapter Protocol on standard input and output, so
+// editors can debug Monkey programs.
+func runDap(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey dap")
+		return 2
+	}
+
+	if err := dap.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "dap: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/internal/format"
+	"os"
+)
+
+// runFmt formats the files named in args, or standard input if there are
+// none, and prints the result. With --write it rewrites the files that
+// change instead, and with --check it only lists them and fails if there
+// are any.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey fmt [--check | --write] [file ...]")
+		flags.PrintDefaults()
+	}
+	check := flags.Bool("check", false, "list the files that are not formatted and exit with status 1 if there are any")
+	write := flags.Bool("write", false, "rewrite the files that are not formatted instead of printing the result")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *check && *write {
+		fmt.Fprintln(os.Stderr, "fmt: --check and --write cannot be used together")
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "fmt: --write needs file names")
+			return 2
+		}
+
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fmt: %s\n", err)
+			return 1
+		}
+
+		return fmtFile("<stdin>", src, *check, false)
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fmt: %s\n", err)
+			status = 1
+			continue
+		}
+
+		if s := fmtFile(name, src, *check, *write); s != 0 {
+			status = s
+		}
+	}
+
+	return status
+}
+
+func fmtFile(name string, src []byte, check, write bool) int {
+	formatted, err := format.Source(string(src))
+
+	var syntaxErr *format.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		for _, d := range syntaxErr.Diagnostics {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+		}
+		return 1
+	}
+
+	switch {
+	case check:
+		if formatted != string(src) {
+			fmt.Println(name)
+			return 1
+		}
+	case write:
+		if formatted != string(src) {
+			if err := os.WriteFile(name, []byte(formatted), 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "fmt: %s\n", err)
+				return 1
+			}
+		}
+	default:
+		fmt.Print(formatted)
+	}
+
+	return 0
+}
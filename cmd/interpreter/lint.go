@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/internal/lint"
+	"os"
+	"strings"
+)
+
+// runLint checks the files named in args and prints what it finds. It fails
+// if it finds anything.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey lint [--enable RULES | --disable RULES] [--list] file ...")
+		flags.PrintDefaults()
+	}
+	enable := flags.String("enable", "", "check only the comma-separated `RULES`")
+	disable := flags.String("disable", "", "do not check the comma-separated `RULES`")
+	list := flags.Bool("list", false, "list the rules and exit")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		for _, r := range lint.Rules {
+			fmt.Printf("%-16s %s\n", r.Name, r.Doc)
+		}
+		return 0
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	l := lint.New()
+	if *enable != "" {
+		if err := l.Enable(splitList(*enable)...); err != nil {
+			fmt.Fprintf(os.Stderr, "lint: %s\n", err)
+			return 2
+		}
+	}
+	if *disable != "" {
+		if err := l.Disable(splitList(*disable)...); err != nil {
+			fmt.Fprintf(os.Stderr, "lint: %s\n", err)
+			return 2
+		}
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lint: %s\n", err)
+			status = 1
+			continue
+		}
+
+		for _, d := range l.Lint(string(src)) {
+			fmt.Printf("%s:%s\n", name, d)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
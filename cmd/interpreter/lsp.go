@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"monkey/internal/lsp"
+	"os"
+)
+
+// runLsp serves the Language Server Protocol on standard input and output
+// until the editor asks it to exit.
+func runLsp(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey lsp")
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "lsp: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -8,7 +8,32 @@ import (
 	"os/user"
 )
 
+// commands are the subcommands of the interpreter. Each one gets the
+// arguments after its name and returns the exit status.
+var commands = map[string]func(args []string) int{
+	"ast":       runAST,
+	"callgraph": runCallgraph,
+	"check":     runCheck,
+	"dap":       runDap,
+	"fmt":       runFmt,
+	"lint":      runLint,
+	"lsp":       runLsp,
+	"run":       runRun,
+	"test":      runTest,
+	"tokens":    runTokens,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		cmd, ok := commands[os.Args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+
+		os.Exit(cmd(os.Args[2:]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/internal/cover"
+	"monkey/internal/diagnostic"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/optimize"
+	"monkey/internal/parser"
+	"monkey/internal/profile"
+	"monkey/internal/resolver"
+	"os"
+)
+
+// runRun runs the program in the file named in args. With --optimize it
+// first optimizes the program, with --profile it also measures where the
+// program spends its time and writes a report to the file named by the
+// flag, and with --cover it records which parts of the program ran.
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey run [--optimize PASSES] [--profile FILE [--profile-format FORMAT] | --cover [--cover-lcov FILE] [--cover-listing FILE]] file.mk")
+		flags.PrintDefaults()
+	}
+	profilePath := flags.String("profile", "", "write a profile of the run to `FILE`")
+	profileFormat := flags.String("profile-format", "text", "write the profile as text, folded stacks for flame graphs, or pprof")
+	coverSummary := flags.Bool("cover", false, "print how many statements, branches and functions ran")
+	coverLCOV := flags.String("cover-lcov", "", "write the coverage in LCOV format to `FILE`")
+	coverListing := flags.String("cover-listing", "", "write the source annotated with how often each line ran to `FILE`")
+	passes := flags.String("optimize", "", "optimize the program with the comma-separated `PASSES`, or all of them with all")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	covering := *coverSummary || *coverLCOV != "" || *coverListing != ""
+	if covering && *profilePath != "" {
+		fmt.Fprintln(os.Stderr, "run: --profile and --cover cannot be used together")
+		return 2
+	}
+
+	switch *profileFormat {
+	case "text", "folded", "pprof":
+	default:
+		fmt.Fprintf(os.Stderr, "run: unknown profile format %q\n", *profileFormat)
+		return 2
+	}
+
+	var optimizer *optimize.Optimizer
+	if *passes != "" {
+		optimizer = optimize.New()
+		if *passes != "all" {
+			if err := optimizer.Enable(splitList(*passes)...); err != nil {
+				fmt.Fprintf(os.Stderr, "run: %s\n", err)
+				return 2
+			}
+		}
+	}
+
+	name := flags.Arg(0)
+	src, err := os.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run: %s\n", err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	diags := p.Diagnostics()
+	if len(diags) == 0 {
+		if optimizer != nil {
+			optimizer.Optimize(program)
+		}
+		diags = resolver.New(eval.BuiltinNames()).Resolve(program)
+	}
+
+	for _, d := range diags {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+	}
+	if diagnostic.HasErrors(diags) {
+		return 1
+	}
+
+	var profiler *profile.Profiler
+	if *profilePath != "" {
+		profiler = profile.New()
+		profiler.Start()
+	}
+
+	var coverage *cover.Coverage
+	if covering {
+		coverage = cover.New()
+		coverage.Add(name, string(src), program)
+		coverage.Start()
+	}
+
+	status := 0
+	if err, ok := eval.Eval(program, object.NewEnv()).(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		status = 1
+	}
+
+	if profiler != nil {
+		if err := writeProfile(*profilePath, *profileFormat, name, string(src), profiler.Stop()); err != nil {
+			fmt.Fprintf(os.Stderr, "run: %s\n", err)
+			return 1
+		}
+	}
+
+	if coverage != nil {
+		coverage.Stop()
+		if err := writeCoverage(coverage, *coverSummary, *coverLCOV, *coverListing); err != nil {
+			fmt.Fprintf(os.Stderr, "run: %s\n", err)
+			return 1
+		}
+	}
+
+	return status
+}
+
+// writeCoverage prints the summary of c if summary is set and writes it in
+// LCOV format and as a listing to the files named by lcov and listing,
+// unless they are empty.
+func writeCoverage(c *cover.Coverage, summary bool, lcov, listing string) error {
+	if summary {
+		if err := c.WriteSummary(os.Stderr); err != nil {
+			return err
+		}
+	}
+
+	if lcov != "" {
+		if err := writeFile(lcov, c.WriteLCOV); err != nil {
+			return err
+		}
+	}
+
+	if listing != "" {
+		if err := writeFile(listing, c.WriteListing); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func writeProfile(path, format, name, src string, prof *profile.Profile) error {
+	return writeFile(path, func(w io.Writer) error {
+		switch format {
+		case "folded":
+			return prof.WriteFolded(w)
+		case "pprof":
+			return prof.WritePprof(w, name)
+		default:
+			return prof.WriteText(w, src)
+		}
+	})
+}
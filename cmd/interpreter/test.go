@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/internal/cover"
+	"monkey/internal/tester"
+	"os"
+	"regexp"
+)
+
+// runTest runs the tests in the _test.mk files named in args, or found in
+// the directories named there or the current directory, and reports the
+// results. It fails if any test does.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey test [--run REGEXP] [--format FORMAT] [-v] [--cover [--cover-lcov FILE] [--cover-listing FILE]] [path ...]")
+		flags.PrintDefaults()
+	}
+	run := flags.String("run", "", "run only the tests whose name matches `REGEXP`")
+	format := flags.String("format", "text", "report the results as text, junit XML or tap")
+	verbose := flags.Bool("v", false, "list the tests that pass and what they print too")
+	coverSummary := flags.Bool("cover", false, "print how many statements, branches and functions of the test files ran")
+	coverLCOV := flags.String("cover-lcov", "", "write the coverage in LCOV format to `FILE`")
+	coverListing := flags.String("cover-listing", "", "write the source annotated with how often each line ran to `FILE`")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var write func([]tester.Result) error
+	switch *format {
+	case "text":
+		write = func(results []tester.Result) error { return tester.WriteText(os.Stdout, results, *verbose) }
+	case "junit":
+		write = func(results []tester.Result) error { return tester.WriteJUnit(os.Stdout, results) }
+	case "tap":
+		write = func(results []tester.Result) error { return tester.WriteTAP(os.Stdout, results) }
+	default:
+		fmt.Fprintf(os.Stderr, "test: unknown format %q\n", *format)
+		return 2
+	}
+
+	runner := tester.New()
+	if *run != "" {
+		filter, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test: %s\n", err)
+			return 2
+		}
+		runner.Filter = filter
+	}
+	if *coverSummary || *coverLCOV != "" || *coverListing != "" {
+		runner.Coverage = cover.New()
+	}
+
+	files, err := tester.Discover(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "test: %s\n", err)
+		return 1
+	}
+
+	var results []tester.Result
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test: %s\n", err)
+			return 1
+		}
+
+		results = append(results, runner.RunFile(name, string(src))...)
+	}
+
+	if err := write(results); err != nil {
+		fmt.Fprintf(os.Stderr, "test: %s\n", err)
+		return 1
+	}
+
+	if runner.Coverage != nil {
+		if err := writeCoverage(runner.Coverage, *coverSummary, *coverLCOV, *coverListing); err != nil {
+			fmt.Fprintf(os.Stderr, "test: %s\n", err)
+			return 1
+		}
+	}
+
+	for _, r := range results {
+		if !r.Passed() {
+			return 1
+		}
+	}
+
+	return 0
+}
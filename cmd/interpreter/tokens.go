@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/internal/lexer"
+	"monkey/internal/token"
+	"os"
+	"strconv"
+)
+
+// runTokens prints the tokens of a file, one per line or, with --json, as
+// a JSON array.
+func runTokens(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey tokens [--json] [file]")
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print the tokens as JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	name, src, status := readSource("tokens", flags)
+	if status != 0 {
+		return status
+	}
+
+	l := lexer.New(src)
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(tokens, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tokens: %s\n", err)
+			return 1
+		}
+
+		fmt.Println(string(data))
+		return 0
+	}
+
+	for _, tok := range tokens {
+		fmt.Printf("%s:%s\t%s\t%s\n", name, tok.Pos, tok.Type, strconv.Quote(tok.Literal))
+	}
+
+	return 0
+}
+
+// readSource reads the file named by the only argument left in flags, or
+// standard input if there is none. It returns a nonzero exit status if
+// that fails.
+func readSource(cmd string, flags *flag.FlagSet) (name, src string, status int) {
+	var data []byte
+	var err error
+
+	switch flags.NArg() {
+	case 0:
+		name = "<stdin>"
+		data, err = io.ReadAll(os.Stdin)
+	case 1:
+		name = flags.Arg(0)
+		data, err = os.ReadFile(name)
+	default:
+		flags.Usage()
+		return "", "", 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd, err)
+		return "", "", 1
+	}
+
+	return name, string(data), 0
+}
@@ -0,0 +1,57 @@
+package ast
+
+import (
+	"monkey/internal/token"
+	"strings"
+)
+
+// NamedType is a type written as a name, like int or any.
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// ArrayType is [Element].
+type ArrayType struct {
+	Token   token.Token
+	Element Type
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string       { return "[" + at.Element.String() + "]" }
+
+// HashMapType is {Key: Value}.
+type HashMapType struct {
+	Token token.Token
+	Key   Type
+	Value Type
+}
+
+func (ht *HashMapType) typeNode()            {}
+func (ht *HashMapType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashMapType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// FunctionType is fn(Params) -> Return.
+type FunctionType struct {
+	Token  token.Token
+	Params []Type
+	Return Type
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	params := make([]string, len(ft.Params))
+	for i, p := range ft.Params {
+		params[i] = p.String()
+	}
+
+	return "fn(" + strings.Join(params, ", ") + ") -> " + ft.Return.String()
+}
@@ -1,6 +1,10 @@
 package ast
 
-import "bytes"
+import (
+	"bytes"
+	"fmt"
+	"monkey/internal/token"
+)
 
 type Program struct {
 	Statements []Statement
@@ -15,11 +19,78 @@ func (p *Program) TokenLiteral() string {
 }
 
 func (p *Program) String() string {
+	return statements(p.Statements)
+}
+
+// statements renders stmts so that they parse back to the same statements:
+// an expression statement followed by another one ends with a semicolon.
+func statements(stmts []Statement) string {
 	var out bytes.Buffer
 
-	for _, stmt := range p.Statements {
+	for i, stmt := range stmts {
 		out.WriteString(stmt.String())
+		if _, ok := stmt.(*ExpressionStatement); ok && i < len(stmts)-1 {
+			out.WriteString(";")
+		}
 	}
 
 	return out.String()
 }
+
+// TokenOf returns the token stored in node, which for most nodes is the
+// one they start with, but for example the operator of an infix
+// expression. A program has no token of its own and gets the zero token.
+func TokenOf(node Node) token.Token {
+	switch n := node.(type) {
+	case *Program:
+		return token.Token{}
+	case *LetStatement:
+		return n.Token
+	case *ReturnStatement:
+		return n.Token
+	case *ExpressionStatement:
+		return n.Token
+	case *BlockStatement:
+		return n.Token
+	case *ID:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *InfixExpression:
+		return n.Token
+	case *BooleanExpression:
+		return n.Token
+	case *IfExpression:
+		return n.Token
+	case *FunctionLiteral:
+		return n.Token
+	case *CallExpression:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *ArrayLiteral:
+		return n.Token
+	case *IndexExpression:
+		return n.Token
+	case *SliceExpression:
+		return n.Token
+	case *HashMapLiteral:
+		return n.Token
+	case *BadStatement:
+		return n.Token
+	case *BadExpression:
+		return n.Token
+	case *NamedType:
+		return n.Token
+	case *ArrayType:
+		return n.Token
+	case *HashMapType:
+		return n.Token
+	case *FunctionType:
+		return n.Token
+	}
+
+	panic(fmt.Sprintf("ast.TokenOf: unexpected node type %T", node))
+}
@@ -17,7 +17,7 @@ func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
-	out.WriteString(ls.TokenLiteral() + " " + ls.Name.String() + " = ")
+	out.WriteString(ls.TokenLiteral() + " " + annotated(ls.Name) + " = ")
 
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -46,9 +46,30 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// Scope tells the evaluator where a resolved identifier lives.
+type Scope uint8
+
+const (
+	// Unresolved identifiers are looked up by name at runtime.
+	Unresolved Scope = iota
+	// Local identifiers live in slot Slot of the environment Depth
+	// functions out from the one they are used in.
+	Local
+	// Builtin identifiers name a builtin function.
+	Builtin
+)
+
 type ID struct {
 	Token token.Token
 	Value string
+
+	// Type is the annotation of a let name or parameter, or nil.
+	Type Type
+
+	// Scope, Depth and Slot are filled in by the resolver.
+	Scope Scope
+	Depth int
+	Slot  int
 }
 
 func (i *ID) expressionNode()      {}
@@ -57,6 +78,15 @@ func (i *ID) String() string {
 	return i.Value
 }
 
+// annotated renders a declared name with its type annotation, if any.
+func annotated(id *ID) string {
+	if id.Type == nil {
+		return id.String()
+	}
+
+	return id.String() + ": " + id.Type.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -115,9 +145,12 @@ func (be *BooleanExpression) expressionNode()      {}
 func (be *BooleanExpression) TokenLiteral() string { return be.Token.Literal }
 func (be *BooleanExpression) String() string       { return be.Token.Literal }
 
+// BlockStatement spans from its opening brace, Token, to its closing one at
+// End.
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	End        token.Position
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -126,9 +159,7 @@ func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("{ ")
-	for _, stmt := range bs.Statements {
-		out.WriteString(stmt.String())
-	}
+	out.WriteString(statements(bs.Statements))
 	out.WriteString(" }")
 
 	return out.String()
@@ -147,7 +178,13 @@ func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("if")
-	out.WriteString(ie.Condition.String())
+	switch ie.Condition.(type) {
+	case *PrefixExpression, *InfixExpression, *IndexExpression, *SliceExpression:
+		// These are in parentheses already.
+		out.WriteString(ie.Condition.String())
+	default:
+		out.WriteString(" (" + ie.Condition.String() + ")")
+	}
 	out.WriteString(" ")
 	out.WriteString(ie.Consequence.String())
 
@@ -163,6 +200,17 @@ type FunctionLiteral struct {
 	Token  token.Token
 	Params []*ID
 	Body   *BlockStatement
+
+	// ReturnType is the annotation after the parameters, or nil.
+	ReturnType Type
+
+	// Name is the name a let statement binds the function to, set by the
+	// parser, or "" for anonymous functions.
+	Name string
+
+	// Locals names the environment slots of a call, parameters first. It
+	// is filled in by the resolver and is nil for unresolved functions.
+	Locals []string
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -171,10 +219,15 @@ func (fl *FunctionLiteral) String() string {
 	params := make([]string, len(fl.Params))
 
 	for i, p := range fl.Params {
-		params[i] = p.String()
+		params[i] = annotated(p)
 	}
 
-	return fl.Token.Literal + "(" + strings.Join(params, ", ") + ")" + fl.Body.String()
+	out := fl.Token.Literal + "(" + strings.Join(params, ", ") + ")"
+	if fl.ReturnType != nil {
+		out += " -> " + fl.ReturnType.String()
+	}
+
+	return out + fl.Body.String()
 }
 
 type CallExpression struct {
@@ -202,7 +255,32 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return quote(sl.Value) }
+
+// quote writes s as a string literal, escaping what the lexer decodes.
+func quote(s string) string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+	for _, ch := range s {
+		switch ch {
+		case '"', '\\':
+			out.WriteRune('\\')
+			out.WriteRune(ch)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			out.WriteRune(ch)
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
 
 type ArrayLiteral struct {
 	Token    token.Token
@@ -229,23 +307,77 @@ type IndexExpression struct {
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IndexExpression) String() string {
-	return "(" + ie.Left.String() + "[" + ie.Index.String() + "]"
+	return "(" + ie.Left.String() + "[" + ie.Index.String() + "])"
 }
 
+// SliceExpression is left[Start:End:Step]. Omitted parts are nil.
+type SliceExpression struct {
+	Token token.Token
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	bound := func(e Expression) string {
+		if e == nil {
+			return ""
+		}
+
+		return e.String()
+	}
+
+	out := "(" + se.Left.String() + "[" + bound(se.Start) + ":" + bound(se.End)
+	if se.Step != nil {
+		out += ":" + se.Step.String()
+	}
+
+	return out + "])"
+}
+
+type HashMapPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashMapLiteral keeps its pairs in source order, which is also the order
+// the evaluator inserts them in.
 type HashMapLiteral struct {
 	Token token.Token
-	Pairs map[Expression]Expression
+	Pairs []HashMapPair
 }
 
 func (hml *HashMapLiteral) expressionNode()      {}
 func (hml *HashMapLiteral) TokenLiteral() string { return hml.Token.Literal }
 func (hml *HashMapLiteral) String() string {
 	pairs := make([]string, len(hml.Pairs))
-	i := 0
-	for k, v := range hml.Pairs {
-		pairs[i] = k.String() + ":" + v.String()
-		i++
+	for i, pair := range hml.Pairs {
+		pairs[i] = pair.Key.String() + ":" + pair.Value.String()
 	}
 
 	return "{" + strings.Join(pairs, ", ") + "}"
 }
+
+// BadStatement stands for a statement the parser could not make sense of.
+// It spans from Token to the position of the last token skipped.
+type BadStatement struct {
+	Token token.Token
+	End   token.Position
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
+// BadExpression stands for an expression that failed to parse, so a
+// program with syntax errors still has no nil nodes.
+type BadExpression struct {
+	Token token.Token
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) String() string       { return "<bad expression>" }
@@ -0,0 +1,170 @@
+package ast
+
+import "fmt"
+
+// Rewrite replaces nodes of the tree below node bottom-up: it rewrites the
+// children of a node, stores the results in the node's fields and then
+// replaces the node by fn(node). It returns what node was replaced by.
+// fn keeps a node by returning it and changes its fields in place.
+//
+// A replacement must fit the field it is stored in: statements replace
+// statements, expressions replace expressions, names replace names, blocks
+// replace blocks and annotations annotations. Returning nil for a
+// statement of a program or block removes the statement; nil anywhere else
+// panics, like a replacement that does not fit.
+func Rewrite(node Node, fn func(Node) Node) Node {
+	return rewriter(fn).node(node)
+}
+
+type rewriter func(Node) Node
+
+func (r rewriter) node(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = r.statements(n.Statements)
+	case *LetStatement:
+		n.Name = r.id(n.Name)
+		n.Value = r.expression(n.Value)
+	case *ReturnStatement:
+		n.ReturnValue = r.expression(n.ReturnValue)
+	case *ExpressionStatement:
+		n.Expression = r.expression(n.Expression)
+	case *BlockStatement:
+		n.Statements = r.statements(n.Statements)
+	case *ID:
+		n.Type = r.typ(n.Type)
+	case *PrefixExpression:
+		n.Right = r.expression(n.Right)
+	case *InfixExpression:
+		n.Left = r.expression(n.Left)
+		n.Right = r.expression(n.Right)
+	case *IfExpression:
+		n.Condition = r.expression(n.Condition)
+		n.Consequence = r.block(n.Consequence)
+		n.Alternative = r.block(n.Alternative)
+	case *FunctionLiteral:
+		for i, param := range n.Params {
+			n.Params[i] = r.id(param)
+		}
+		n.ReturnType = r.typ(n.ReturnType)
+		n.Body = r.block(n.Body)
+	case *CallExpression:
+		n.Function = r.expression(n.Function)
+		for i, arg := range n.Arguments {
+			n.Arguments[i] = r.expression(arg)
+		}
+	case *ArrayLiteral:
+		for i, e := range n.Elements {
+			n.Elements[i] = r.expression(e)
+		}
+	case *IndexExpression:
+		n.Left = r.expression(n.Left)
+		n.Index = r.expression(n.Index)
+	case *SliceExpression:
+		n.Left = r.expression(n.Left)
+		n.Start = r.expression(n.Start)
+		n.End = r.expression(n.End)
+		n.Step = r.expression(n.Step)
+	case *HashMapLiteral:
+		for i, pair := range n.Pairs {
+			n.Pairs[i] = HashMapPair{Key: r.expression(pair.Key), Value: r.expression(pair.Value)}
+		}
+	case *ArrayType:
+		n.Element = r.typ(n.Element)
+	case *HashMapType:
+		n.Key = r.typ(n.Key)
+		n.Value = r.typ(n.Value)
+	case *FunctionType:
+		for i, param := range n.Params {
+			n.Params[i] = r.typ(param)
+		}
+		n.Return = r.typ(n.Return)
+	case *IntegerLiteral, *BooleanExpression, *StringLiteral, *NamedType, *BadStatement, *BadExpression:
+		// no children
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return r(node)
+}
+
+// statements rewrites stmts in place, leaving out the removed ones.
+func (r rewriter) statements(stmts []Statement) []Statement {
+	kept := stmts[:0]
+	for _, stmt := range stmts {
+		n := r.node(stmt)
+		if n == nil {
+			continue
+		}
+
+		s, ok := n.(Statement)
+		if !ok {
+			panic(misfit(stmt, n))
+		}
+		kept = append(kept, s)
+	}
+
+	return kept
+}
+
+// The helpers for the other fields keep missing children missing.
+
+func (r rewriter) expression(e Expression) Expression {
+	if e == nil {
+		return nil
+	}
+
+	n := r.node(e)
+	expr, ok := n.(Expression)
+	if !ok {
+		panic(misfit(e, n))
+	}
+
+	return expr
+}
+
+func (r rewriter) id(id *ID) *ID {
+	if id == nil {
+		return nil
+	}
+
+	n := r.node(id)
+	replacement, ok := n.(*ID)
+	if !ok || replacement == nil {
+		panic(misfit(id, n))
+	}
+
+	return replacement
+}
+
+func (r rewriter) block(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+
+	n := r.node(block)
+	replacement, ok := n.(*BlockStatement)
+	if !ok || replacement == nil {
+		panic(misfit(block, n))
+	}
+
+	return replacement
+}
+
+func (r rewriter) typ(t Type) Type {
+	if t == nil {
+		return nil
+	}
+
+	n := r.node(t)
+	replacement, ok := n.(Type)
+	if !ok {
+		panic(misfit(t, n))
+	}
+
+	return replacement
+}
+
+func misfit(old, replacement Node) string {
+	return fmt.Sprintf("ast.Rewrite: cannot replace %T with %T", old, replacement)
+}
@@ -14,3 +14,10 @@ type Expression interface {
 	Node
 	expressionNode()
 }
+
+// Type is a type annotation. Annotations are optional and only read by the
+// type checker; the evaluator ignores them.
+type Type interface {
+	Node
+	typeNode()
+}
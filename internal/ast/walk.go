@@ -0,0 +1,143 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is called for every node Walk finds. If it
+// returns a visitor w other than nil, Walk visits the children of the node
+// with w and then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree below node depth-first, in source order: it
+// calls v.Visit(node) and, unless that returns nil, walks each child of
+// node with the visitor it returned, followed by a call of Visit(nil).
+// Annotations are children of the names and functions they annotate.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		walkID(v, n.Name)
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *ID:
+		walkType(v, n.Type)
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+		walkBlock(v, n.Alternative)
+	case *FunctionLiteral:
+		for _, param := range n.Params {
+			walkID(v, param)
+		}
+		walkType(v, n.ReturnType)
+		walkBlock(v, n.Body)
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *SliceExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Start)
+		walkExpression(v, n.End)
+		walkExpression(v, n.Step)
+	case *HashMapLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+	case *ArrayType:
+		walkType(v, n.Element)
+	case *HashMapType:
+		walkType(v, n.Key)
+		walkType(v, n.Value)
+	case *FunctionType:
+		for _, param := range n.Params {
+			walkType(v, param)
+		}
+		walkType(v, n.Return)
+	case *IntegerLiteral, *BooleanExpression, *StringLiteral, *NamedType, *BadStatement, *BadExpression:
+		// no children
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// The walk helpers skip missing children, which are nil interfaces or nil
+// pointers depending on the type of the field.
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+func walkExpressions(v Visitor, exprs []Expression) {
+	for _, e := range exprs {
+		walkExpression(v, e)
+	}
+}
+
+func walkExpression(v Visitor, e Expression) {
+	if e != nil {
+		Walk(v, e)
+	}
+}
+
+func walkID(v Visitor, id *ID) {
+	if id != nil {
+		Walk(v, id)
+	}
+}
+
+func walkBlock(v Visitor, block *BlockStatement) {
+	if block != nil {
+		Walk(v, block)
+	}
+}
+
+func walkType(v Visitor, t Type) {
+	if t != nil {
+		Walk(v, t)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect traverses the tree below node like Walk: it calls f(node) and,
+// unless that returns false, inspects each child of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
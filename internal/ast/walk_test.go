@@ -0,0 +1,235 @@
+package ast_test
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	"go/token"
+	"monkey/internal/ast"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// everything has a node of every kind, including the ones standing for
+// syntax errors.
+const everything = `let f = fn(x: int, g: fn([string]) -> {string: bool}) -> int { if (!x) { return -x } else { x + 1 } };
+[1, "s", true][0:1:2];
+{"k": f(1, 2)}["k"];
+let = 1;
+let y = (1 + );`
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+
+	return parser.New(lexer.New(src)).ParseProgram()
+}
+
+// nodeKinds returns the names of all types in package ast that implement
+// ast.Node, found by reading its source, so that a new kind of node fails
+// the tests until Walk and Rewrite know about it.
+func nodeKinds(t *testing.T) []string {
+	t.Helper()
+
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		file, err := goparser.ParseFile(token.NewFileSet(), name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "TokenLiteral" {
+				continue
+			}
+
+			star := fn.Recv.List[0].Type.(*goast.StarExpr)
+			kinds = append(kinds, "*ast."+star.X.(*goast.Ident).Name)
+		}
+	}
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+func TestEveryNodeKind(t *testing.T) {
+	kinds := nodeKinds(t)
+
+	collect := func(seen map[string]bool) []string {
+		var names []string
+		for name := range seen {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	t.Run("Inspect", func(t *testing.T) {
+		seen := make(map[string]bool)
+		ast.Inspect(parse(t, everything), func(node ast.Node) bool {
+			if node != nil {
+				seen[fmt.Sprintf("%T", node)] = true
+			}
+			return true
+		})
+
+		if got := collect(seen); !reflect.DeepEqual(got, kinds) {
+			t.Errorf("visited\n%v\nexpected\n%v", got, kinds)
+		}
+	})
+
+	t.Run("TokenOf", func(t *testing.T) {
+		ast.Inspect(parse(t, everything), func(node ast.Node) bool {
+			if node == nil {
+				return false
+			}
+
+			if _, program := node.(*ast.Program); !program && ast.TokenOf(node).Pos.Line == 0 {
+				t.Errorf("%T has no token", node)
+			}
+			return true
+		})
+	})
+
+	t.Run("Rewrite", func(t *testing.T) {
+		seen := make(map[string]bool)
+		program := parse(t, everything)
+		before := program.String()
+
+		ast.Rewrite(program, func(node ast.Node) ast.Node {
+			seen[fmt.Sprintf("%T", node)] = true
+			return node
+		})
+
+		if got := collect(seen); !reflect.DeepEqual(got, kinds) {
+			t.Errorf("rewrote\n%v\nexpected\n%v", got, kinds)
+		}
+		if after := program.String(); after != before {
+			t.Errorf("identity rewrite changed\n%s\nto\n%s", before, after)
+		}
+	})
+}
+
+type recorder struct {
+	events *[]string
+	depth  int
+}
+
+func (r recorder) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*r.events = append(*r.events, strings.Repeat(" ", r.depth-1)+"end")
+		return nil
+	}
+
+	*r.events = append(*r.events, strings.Repeat(" ", r.depth)+fmt.Sprintf("%T", node))
+	if _, ok := node.(*ast.FunctionLiteral); ok {
+		return nil
+	}
+
+	return recorder{events: r.events, depth: r.depth + 1}
+}
+
+func TestWalk(t *testing.T) {
+	var events []string
+	ast.Walk(recorder{events: &events}, parse(t, "let x: int = -a[1]; f(fn() { 1 });"))
+
+	expected := []string{
+		"*ast.Program",
+		" *ast.LetStatement",
+		"  *ast.ID",
+		"   *ast.NamedType",
+		"   end",
+		"  end",
+		"  *ast.PrefixExpression",
+		"   *ast.IndexExpression",
+		"    *ast.ID",
+		"    end",
+		"    *ast.IntegerLiteral",
+		"    end",
+		"   end",
+		"  end",
+		" end",
+		" *ast.ExpressionStatement",
+		"  *ast.CallExpression",
+		"   *ast.ID",
+		"   end",
+		"   *ast.FunctionLiteral",
+		"  end",
+		" end",
+		"end",
+	}
+
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(events, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var ids []string
+	ast.Inspect(parse(t, "let f = fn(a) { a + b }; f(c);"), func(node ast.Node) bool {
+		if id, ok := node.(*ast.ID); ok {
+			ids = append(ids, id.Value)
+		}
+
+		// Skip the body of f.
+		_, block := node.(*ast.BlockStatement)
+		return !block
+	})
+
+	if expected := []string{"f", "a", "f", "c"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("found %v, expected %v", ids, expected)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	program := parse(t, `let x = 1 + 2; "drop me"; if (x) { "drop me"; [3, 4] };`)
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.IntegerLiteral:
+			tok := node.Token
+			tok.Literal = strconv.FormatInt(node.Value*10, 10)
+			return &ast.IntegerLiteral{Token: tok, Value: node.Value * 10}
+		case *ast.ExpressionStatement:
+			if _, ok := node.Expression.(*ast.StringLiteral); ok {
+				return nil
+			}
+		}
+
+		return node
+	})
+
+	if got, expected := program.String(), "let x = (10 + 20);if (x) { [30, 40] }"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+
+	t.Run("replacement that does not fit", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != "ast.Rewrite: cannot replace *ast.ID with *ast.IntegerLiteral" {
+				t.Errorf("recovered %v", r)
+			}
+		}()
+
+		ast.Rewrite(parse(t, "let x = 1;"), func(node ast.Node) ast.Node {
+			if _, ok := node.(*ast.ID); ok {
+				return &ast.IntegerLiteral{}
+			}
+			return node
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package astjson_test
+
+import (
+	"encoding/json"
+	"monkey/internal/ast"
+	"monkey/internal/astjson"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse(src string) *ast.Program {
+	return parser.New(lexer.New(src)).ParseProgram()
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"let x = 5; return x;",
+		`let f = fn(x: int, g: fn([string]) -> {string: bool}) -> int { if (!x) { return -x } else { x + 1 } };`,
+		`[1, "s", true][0:1:2]; a[:]; a[::-1]; {"k": f(1, 2)}["k"]; {}; []; f();`,
+		"let add = fn(a, b) { a + b }; add(1, 2 * 3) == 7 != false",
+		"fn() {}; fn() -> any { if (x) {} }",
+		// Syntax errors.
+		"let = 1; let y = (1 + );",
+	}
+
+	for _, src := range tests {
+		t.Run(src, func(t *testing.T) {
+			program := parse(src)
+
+			data, err := astjson.Marshal(program)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := astjson.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("%s\nin %s", err, data)
+			}
+
+			if !reflect.DeepEqual(decoded, program) {
+				t.Errorf("decoded %s\nexpected %s", decoded, program)
+			}
+
+			again, err := astjson.Marshal(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("encoded again as\n%s\nexpected\n%s", again, data)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	data, err := astjson.Marshal(parse("let x: [int] = -1;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"kind":"Program","statements":[` +
+		`{"kind":"LetStatement","token":{"type":"let","literal":"let","pos":{"line":1,"column":1}},` +
+		`"name":{"kind":"ID","token":{"type":"ID","literal":"x","pos":{"line":1,"column":5}},"value":"x",` +
+		`"type":{"kind":"ArrayType","token":{"type":"[","literal":"[","pos":{"line":1,"column":8}},` +
+		`"element":{"kind":"NamedType","token":{"type":"ID","literal":"int","pos":{"line":1,"column":9}},"name":"int"}}},` +
+		`"value":{"kind":"PrefixExpression","token":{"type":"-","literal":"-","pos":{"line":1,"column":16}},"operator":"-",` +
+		`"right":{"kind":"IntegerLiteral","token":{"type":"INT","literal":"1","pos":{"line":1,"column":17}},"value":1}}}]}`
+
+	if string(data) != expected {
+		t.Errorf("got\n%s\nexpected\n%s", data, expected)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{`null`, "astjson: no node to decode"},
+		{`{"statements":[]}`, "astjson: node without a kind"},
+		{`{"kind":"WhileLoop"}`, `astjson: unknown node kind "WhileLoop"`},
+		{`{"kind":"Program","statements":[{"kind":"IntegerLiteral","value":1}]}`,
+			"astjson: unexpected *ast.IntegerLiteral where ast.Statement belongs"},
+		{`{"kind":"LetStatement","name":{"kind":"StringLiteral"}}`,
+			"astjson: unexpected *ast.StringLiteral where *ast.ID belongs"},
+		{`{"kind":"ID","token":{"type":"while"}}`, `astjson: unknown token type "while"`},
+		{`{"kind":"IntegerLiteral","value":"1"}`, "astjson: json: cannot unmarshal string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			_, err := astjson.Unmarshal([]byte(tt.data))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("got error %v, expected %q", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTokens(t *testing.T) {
+	l := lexer.New("let s = \"a\\\"b\" -> x;")
+
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []token.Token
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, tokens) {
+		t.Errorf("decoded %v, expected %v", decoded, tokens)
+	}
+}
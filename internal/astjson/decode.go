@@ -0,0 +1,237 @@
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/token"
+	"reflect"
+)
+
+// Unmarshal decodes a tree encoded by Marshal. Decoding the encoding of a
+// parsed program gives a program equal to the parsed one.
+func Unmarshal(data []byte) (ast.Node, error) {
+	d := &decoder{}
+	node := d.node(data)
+	if d.err != nil {
+		return nil, d.err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("astjson: no node to decode")
+	}
+
+	return node, nil
+}
+
+// decoder remembers the first error, so that the code building the nodes
+// can go on without checking after every field.
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf("astjson: "+format, args...)
+	}
+}
+
+// value decodes data into v unless it is missing.
+func (d *decoder) value(data json.RawMessage, v any) {
+	if missing(data) || d.err != nil {
+		return
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		d.fail("%s", err)
+	}
+}
+
+func missing(data json.RawMessage) bool {
+	return len(data) == 0 || bytes.Equal(data, []byte("null"))
+}
+
+// node decodes the object in data, or returns nil if it is missing.
+func (d *decoder) node(data json.RawMessage) ast.Node {
+	if missing(data) || d.err != nil {
+		return nil
+	}
+
+	var f map[string]json.RawMessage
+	d.value(data, &f)
+
+	var kind string
+	var tok token.Token
+	d.value(f["kind"], &kind)
+	d.value(f["token"], &tok)
+	if d.err != nil {
+		return nil
+	}
+
+	switch kind {
+	case "Program":
+		return &ast.Program{Statements: children[ast.Statement](d, f["statements"])}
+	case "LetStatement":
+		return &ast.LetStatement{
+			Token: tok,
+			Name:  child[*ast.ID](d, f["name"]),
+			Value: child[ast.Expression](d, f["value"]),
+		}
+	case "ReturnStatement":
+		return &ast.ReturnStatement{Token: tok, ReturnValue: child[ast.Expression](d, f["returnValue"])}
+	case "ExpressionStatement":
+		return &ast.ExpressionStatement{Token: tok, Expression: child[ast.Expression](d, f["expression"])}
+	case "BlockStatement":
+		block := &ast.BlockStatement{Token: tok, Statements: children[ast.Statement](d, f["statements"])}
+		d.value(f["end"], &block.End)
+		return block
+	case "ID":
+		id := &ast.ID{Token: tok, Type: child[ast.Type](d, f["type"])}
+		d.value(f["value"], &id.Value)
+		return id
+	case "IntegerLiteral":
+		il := &ast.IntegerLiteral{Token: tok}
+		d.value(f["value"], &il.Value)
+		return il
+	case "BooleanExpression":
+		be := &ast.BooleanExpression{Token: tok}
+		d.value(f["value"], &be.Value)
+		return be
+	case "StringLiteral":
+		sl := &ast.StringLiteral{Token: tok}
+		d.value(f["value"], &sl.Value)
+		return sl
+	case "PrefixExpression":
+		pe := &ast.PrefixExpression{Token: tok, Right: child[ast.Expression](d, f["right"])}
+		d.value(f["operator"], &pe.Operator)
+		return pe
+	case "InfixExpression":
+		ie := &ast.InfixExpression{
+			Token: tok,
+			Left:  child[ast.Expression](d, f["left"]),
+			Right: child[ast.Expression](d, f["right"]),
+		}
+		d.value(f["operator"], &ie.Operator)
+		return ie
+	case "IfExpression":
+		return &ast.IfExpression{
+			Token:       tok,
+			Condition:   child[ast.Expression](d, f["condition"]),
+			Consequence: child[*ast.BlockStatement](d, f["consequence"]),
+			Alternative: child[*ast.BlockStatement](d, f["alternative"]),
+		}
+	case "FunctionLiteral":
+		fl := &ast.FunctionLiteral{
+			Token:      tok,
+			Params:     children[*ast.ID](d, f["params"]),
+			ReturnType: child[ast.Type](d, f["returnType"]),
+			Body:       child[*ast.BlockStatement](d, f["body"]),
+		}
+		d.value(f["name"], &fl.Name)
+		return fl
+	case "CallExpression":
+		return &ast.CallExpression{
+			Token:     tok,
+			Function:  child[ast.Expression](d, f["function"]),
+			Arguments: children[ast.Expression](d, f["arguments"]),
+		}
+	case "ArrayLiteral":
+		return &ast.ArrayLiteral{Token: tok, Elements: children[ast.Expression](d, f["elements"])}
+	case "IndexExpression":
+		return &ast.IndexExpression{
+			Token: tok,
+			Left:  child[ast.Expression](d, f["left"]),
+			Index: child[ast.Expression](d, f["index"]),
+		}
+	case "SliceExpression":
+		return &ast.SliceExpression{
+			Token: tok,
+			Left:  child[ast.Expression](d, f["left"]),
+			Start: child[ast.Expression](d, f["start"]),
+			End:   child[ast.Expression](d, f["end"]),
+			Step:  child[ast.Expression](d, f["step"]),
+		}
+	case "HashMapLiteral":
+		hml := &ast.HashMapLiteral{Token: tok}
+		if !missing(f["pairs"]) {
+			var pairs []map[string]json.RawMessage
+			d.value(f["pairs"], &pairs)
+
+			hml.Pairs = make([]ast.HashMapPair, len(pairs))
+			for i, pair := range pairs {
+				hml.Pairs[i] = ast.HashMapPair{
+					Key:   child[ast.Expression](d, pair["key"]),
+					Value: child[ast.Expression](d, pair["value"]),
+				}
+			}
+		}
+		return hml
+	case "BadStatement":
+		bs := &ast.BadStatement{Token: tok}
+		d.value(f["end"], &bs.End)
+		return bs
+	case "BadExpression":
+		return &ast.BadExpression{Token: tok}
+	case "NamedType":
+		nt := &ast.NamedType{Token: tok}
+		d.value(f["name"], &nt.Name)
+		return nt
+	case "ArrayType":
+		return &ast.ArrayType{Token: tok, Element: child[ast.Type](d, f["element"])}
+	case "HashMapType":
+		return &ast.HashMapType{
+			Token: tok,
+			Key:   child[ast.Type](d, f["key"]),
+			Value: child[ast.Type](d, f["value"]),
+		}
+	case "FunctionType":
+		return &ast.FunctionType{
+			Token:  tok,
+			Params: children[ast.Type](d, f["params"]),
+			Return: child[ast.Type](d, f["return"]),
+		}
+	case "":
+		d.fail("node without a kind")
+	default:
+		d.fail("unknown node kind %q", kind)
+	}
+
+	return nil
+}
+
+// child decodes the node in data, which has to be a T, or returns the
+// zero T if it is missing.
+func child[T ast.Node](d *decoder, data json.RawMessage) T {
+	var zero T
+
+	node := d.node(data)
+	if node == nil {
+		return zero
+	}
+
+	t, ok := node.(T)
+	if !ok {
+		d.fail("unexpected %T where %s belongs", node, reflect.TypeOf((*T)(nil)).Elem())
+		return zero
+	}
+
+	return t
+}
+
+// children decodes a list of nodes. A missing list is nil, an empty one is
+// not.
+func children[T ast.Node](d *decoder, data json.RawMessage) []T {
+	if missing(data) {
+		return nil
+	}
+
+	var list []json.RawMessage
+	d.value(data, &list)
+
+	nodes := make([]T, len(list))
+	for i, item := range list {
+		nodes[i] = child[T](d, item)
+	}
+
+	return nodes
+}
@@ -0,0 +1,194 @@
+// Package astjson converts syntax trees to JSON and back, for tools that
+// are not written in Go.
+//
+// Every node is an object whose "kind" is the name of its type in package
+// ast, such as "LetStatement", followed by its "token", with the token's
+// type, literal and position, and then its fields, named like the Go
+// fields in lower camel case. Missing children, like the alternative of an
+// if without else, are left out. The fields the resolver fills in are not
+// encoded: a decoded program has to be resolved again before it is run.
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/token"
+)
+
+// Marshal returns the JSON encoding of the tree below node.
+func Marshal(node ast.Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// MarshalIndent is like Marshal but indents the output like
+// json.MarshalIndent.
+func MarshalIndent(node ast.Node, prefix, indent string) ([]byte, error) {
+	return json.MarshalIndent(encode(node), prefix, indent)
+}
+
+// object is a JSON object that keeps its members in the order they were
+// added, so that the kind of a node comes first.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+// add adds a member unless value is a missing child.
+func (o object) add(key string, value any) object {
+	if value == nil {
+		return o
+	}
+
+	return append(o, member{key, value})
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(m.key)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// encode returns the object for node, or nil for a missing child. Typed
+// nil pointers count as missing too.
+func encode(node ast.Node) any {
+	switch n := node.(type) {
+	case *ast.Program:
+		return object{{"kind", "Program"}}.add("statements", nodes(n.Statements))
+	case *ast.LetStatement:
+		return start("LetStatement", n.Token).
+			add("name", encode(n.Name)).
+			add("value", encode(n.Value))
+	case *ast.ReturnStatement:
+		return start("ReturnStatement", n.Token).add("returnValue", encode(n.ReturnValue))
+	case *ast.ExpressionStatement:
+		return start("ExpressionStatement", n.Token).add("expression", encode(n.Expression))
+	case *ast.BlockStatement:
+		if n == nil {
+			return nil
+		}
+		return start("BlockStatement", n.Token).
+			add("statements", nodes(n.Statements)).
+			add("end", n.End)
+	case *ast.ID:
+		if n == nil {
+			return nil
+		}
+		return start("ID", n.Token).add("value", n.Value).add("type", encode(n.Type))
+	case *ast.IntegerLiteral:
+		return start("IntegerLiteral", n.Token).add("value", n.Value)
+	case *ast.BooleanExpression:
+		return start("BooleanExpression", n.Token).add("value", n.Value)
+	case *ast.StringLiteral:
+		return start("StringLiteral", n.Token).add("value", n.Value)
+	case *ast.PrefixExpression:
+		return start("PrefixExpression", n.Token).
+			add("operator", n.Operator).
+			add("right", encode(n.Right))
+	case *ast.InfixExpression:
+		return start("InfixExpression", n.Token).
+			add("left", encode(n.Left)).
+			add("operator", n.Operator).
+			add("right", encode(n.Right))
+	case *ast.IfExpression:
+		return start("IfExpression", n.Token).
+			add("condition", encode(n.Condition)).
+			add("consequence", encode(n.Consequence)).
+			add("alternative", encode(n.Alternative))
+	case *ast.FunctionLiteral:
+		o := start("FunctionLiteral", n.Token).
+			add("params", nodes(n.Params)).
+			add("returnType", encode(n.ReturnType)).
+			add("body", encode(n.Body))
+		if n.Name != "" {
+			o = o.add("name", n.Name)
+		}
+		return o
+	case *ast.CallExpression:
+		return start("CallExpression", n.Token).
+			add("function", encode(n.Function)).
+			add("arguments", nodes(n.Arguments))
+	case *ast.ArrayLiteral:
+		return start("ArrayLiteral", n.Token).add("elements", nodes(n.Elements))
+	case *ast.IndexExpression:
+		return start("IndexExpression", n.Token).
+			add("left", encode(n.Left)).
+			add("index", encode(n.Index))
+	case *ast.SliceExpression:
+		return start("SliceExpression", n.Token).
+			add("left", encode(n.Left)).
+			add("start", encode(n.Start)).
+			add("end", encode(n.End)).
+			add("step", encode(n.Step))
+	case *ast.HashMapLiteral:
+		var pairs any
+		if n.Pairs != nil {
+			list := make([]any, len(n.Pairs))
+			for i, pair := range n.Pairs {
+				list[i] = object{}.add("key", encode(pair.Key)).add("value", encode(pair.Value))
+			}
+			pairs = list
+		}
+		return start("HashMapLiteral", n.Token).add("pairs", pairs)
+	case *ast.BadStatement:
+		return start("BadStatement", n.Token).add("end", n.End)
+	case *ast.BadExpression:
+		return start("BadExpression", n.Token)
+	case *ast.NamedType:
+		return start("NamedType", n.Token).add("name", n.Name)
+	case *ast.ArrayType:
+		return start("ArrayType", n.Token).add("element", encode(n.Element))
+	case *ast.HashMapType:
+		return start("HashMapType", n.Token).
+			add("key", encode(n.Key)).
+			add("value", encode(n.Value))
+	case *ast.FunctionType:
+		return start("FunctionType", n.Token).
+			add("params", nodes(n.Params)).
+			add("return", encode(n.Return))
+	case nil:
+		return nil
+	default:
+		panic(fmt.Sprintf("astjson: unexpected node type %T", n))
+	}
+}
+
+func start(kind string, tok token.Token) object {
+	return object{{"kind", kind}, {"token", tok}}
+}
+
+// nodes encodes a list of children, keeping the difference between a nil
+// list, which is missing, and an empty one.
+func nodes[T ast.Node](list []T) any {
+	if list == nil {
+		return nil
+	}
+
+	encoded := make([]any, len(list))
+	for i, node := range list {
+		encoded[i] = encode(node)
+	}
+
+	return encoded
+}
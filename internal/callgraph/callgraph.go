@@ -0,0 +1,126 @@
+// Package callgraph finds which functions of a Monkey program call which
+// others, without running it. It only knows the functions bound by let
+// statements: anonymous functions belong to the function they are written
+// in, and calls through parameters, arrays or hashes are not followed.
+package callgraph
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/resolver"
+	"monkey/internal/token"
+)
+
+// Function is a function literal bound by a let statement.
+type Function struct {
+	Name    string
+	Pos     token.Position // of the name
+	Literal *ast.FunctionLiteral
+}
+
+// Edge says that Caller calls Callee or, if Call is false, only mentions
+// it, for example to pass it to map. Caller is nil for the top level of
+// the program.
+type Edge struct {
+	Caller, Callee *Function
+	Call           bool
+}
+
+// Graph is the call graph of a program. Functions are in source order and
+// edges are grouped by caller, the top level first, in the order of their
+// first use.
+type Graph struct {
+	Functions []*Function
+	Edges     []*Edge
+}
+
+// Build returns the call graph of program, which r must have resolved.
+// A name bound to different functions by several lets in the same scope
+// gets an edge to each of them.
+func Build(program *ast.Program, r *resolver.Resolver) *Graph {
+	b := &builder{
+		graph:     &Graph{},
+		resolver:  r,
+		bound:     make(map[*ast.ID][]*Function),
+		functions: make(map[*ast.FunctionLiteral]*Function),
+		edges:     make(map[[2]*Function]*Edge),
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		let, ok := node.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			return true
+		}
+
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			f := &Function{Name: let.Name.Value, Pos: let.Name.Token.Pos, Literal: fn}
+			b.graph.Functions = append(b.graph.Functions, f)
+			b.functions[fn] = f
+
+			if decl := r.Declaration(let.Name); decl != nil {
+				b.bound[decl] = append(b.bound[decl], f)
+			}
+		}
+		return true
+	})
+
+	b.scan(nil, program)
+	for _, f := range b.graph.Functions {
+		if f.Literal.Body != nil {
+			b.scan(f, f.Literal.Body)
+		}
+	}
+
+	return b.graph
+}
+
+type builder struct {
+	graph     *Graph
+	resolver  *resolver.Resolver
+	bound     map[*ast.ID][]*Function // declaring names to the functions bound to them
+	functions map[*ast.FunctionLiteral]*Function
+	edges     map[[2]*Function]*Edge
+}
+
+// scan adds the edges from caller for the names used below node, which is
+// the program or the body of caller, leaving out the let-bound functions
+// defined in it.
+func (b *builder) scan(caller *Function, node ast.Node) {
+	called := make(map[*ast.ID]bool)
+	declaring := make(map[*ast.ID]bool)
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			if _, ok := b.functions[node]; ok {
+				return false
+			}
+		case *ast.LetStatement:
+			declaring[node.Name] = true
+		case *ast.CallExpression:
+			if id, ok := node.Function.(*ast.ID); ok {
+				called[id] = true
+			}
+		case *ast.ID:
+			decl := b.resolver.Declaration(node)
+			if decl == nil || declaring[node] {
+				return true
+			}
+
+			for _, callee := range b.bound[decl] {
+				b.add(caller, callee, called[node])
+			}
+		}
+		return true
+	})
+}
+
+func (b *builder) add(caller, callee *Function, call bool) {
+	key := [2]*Function{caller, callee}
+	if e, ok := b.edges[key]; ok {
+		e.Call = e.Call || call
+		return
+	}
+
+	e := &Edge{Caller: caller, Callee: callee, Call: call}
+	b.edges[key] = e
+	b.graph.Edges = append(b.graph.Edges, e)
+}
@@ -0,0 +1,92 @@
+package callgraph_test
+
+import (
+	"fmt"
+	"monkey/internal/callgraph"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"reflect"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			"calls and uses",
+			`let double = fn(x) { x * 2 };
+			 let twice = fn(f, x) { f(f(x)) };
+			 twice(double, 1);
+			 map([1], double);`,
+			[]string{"<top level> calls twice", "<top level> uses double"},
+		},
+		{
+			"recursion",
+			`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+			 let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+			 let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };`,
+			[]string{"even calls odd", "odd calls even", "fact calls fact"},
+		},
+		{
+			"nested functions",
+			`let outer = fn() {
+			     let inner = fn() { helper() };
+			     fn() { inner() }()
+			 };
+			 let helper = fn() { 1 };`,
+			[]string{"outer calls inner", "inner calls helper"},
+		},
+		{
+			"a call and a use count once",
+			`let f = fn() { 1 }; let g = fn() { f(); f; f() };`,
+			[]string{"g calls f"},
+		},
+		{
+			"shadowing",
+			`let f = fn() { 1 }; let g = fn(f) { f() }; let h = fn() { let f = 2; f };`,
+			nil,
+		},
+		{
+			"redeclaration",
+			`let f = fn() { 1 }; let f = fn() { 2 }; f();`,
+			[]string{"<top level> calls f", "<top level> calls f"},
+		},
+		{
+			"builtins and undefined names",
+			`let f = fn() { len(g()) };`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := parser.New(lexer.New(tt.input)).ParseProgram()
+			r := resolver.New(eval.BuiltinNames())
+			r.Resolve(program)
+
+			var edges []string
+			for _, e := range callgraph.Build(program, r).Edges {
+				caller := "<top level>"
+				if e.Caller != nil {
+					caller = e.Caller.Name
+				}
+
+				verb := "calls"
+				if !e.Call {
+					verb = "uses"
+				}
+
+				edges = append(edges, fmt.Sprintf("%s %s %s", caller, verb, e.Callee.Name))
+			}
+
+			if !reflect.DeepEqual(edges, tt.expected) {
+				t.Errorf("got %q, expected %q", edges, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Package cover records which parts of Monkey programs run. A Coverage
+// knows the statements, functions and if branches of the programs added to
+// it and traces evaluation to count how often each of them runs.
+package cover
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/eval"
+	"monkey/internal/object"
+	"monkey/internal/token"
+)
+
+type file struct {
+	name, src  string
+	statements []*statement
+	branches   []*branch
+	functions  []*function
+}
+
+type statement struct {
+	pos  token.Position
+	hits int
+}
+
+// branch is an if expression, whose hits count the runs of its consequence
+// and its alternative. An if without else has an alternative too: doing
+// nothing when the condition is false.
+type branch struct {
+	node *ast.IfExpression
+	pos  token.Position
+	runs int
+	hits [2]int
+}
+
+type function struct {
+	name  string
+	pos   token.Position
+	calls int
+}
+
+// ifEntry is an if expression in progress.
+type ifEntry struct {
+	branch *branch
+	taken  bool
+}
+
+// Coverage is an eval.Tracer that counts how often the statements,
+// functions and branches of its programs run.
+type Coverage struct {
+	files      []*file
+	statements map[ast.Statement]*statement
+	branches   map[*ast.IfExpression]*branch
+	functions  map[*ast.BlockStatement]*function // by body
+	ifs        []ifEntry
+}
+
+func New() *Coverage {
+	return &Coverage{
+		statements: make(map[ast.Statement]*statement),
+		branches:   make(map[*ast.IfExpression]*branch),
+		functions:  make(map[*ast.BlockStatement]*function),
+	}
+}
+
+// Add adds program, parsed from src in the file called name, to the
+// programs whose coverage is recorded. Adding the same file twice adds up
+// the runs of both programs.
+func (c *Coverage) Add(name, src string, program *ast.Program) {
+	f := c.file(name, src)
+
+	walk(program, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			c.addStatement(f, node, node.Token.Pos)
+		case *ast.ReturnStatement:
+			c.addStatement(f, node, node.Token.Pos)
+		case *ast.ExpressionStatement:
+			c.addStatement(f, node, node.Token.Pos)
+		case *ast.IfExpression:
+			b := &branch{node: node, pos: node.Token.Pos}
+			c.branches[node] = b
+			f.branches = append(f.branches, b)
+		case *ast.FunctionLiteral:
+			name := node.Name
+			if name == "" {
+				name = fmt.Sprintf("<fn at %s>", node.Body.Token.Pos)
+			}
+
+			fn := &function{name: name, pos: node.Token.Pos}
+			c.functions[node.Body] = fn
+			f.functions = append(f.functions, fn)
+		}
+	})
+}
+
+func (c *Coverage) file(name, src string) *file {
+	for _, f := range c.files {
+		if f.name == name {
+			return f
+		}
+	}
+
+	f := &file{name: name, src: src}
+	c.files = append(c.files, f)
+	return f
+}
+
+func (c *Coverage) addStatement(f *file, node ast.Statement, pos token.Position) {
+	s := &statement{pos: pos}
+	c.statements[node] = s
+	f.statements = append(f.statements, s)
+}
+
+// Start installs the coverage as the evaluator's tracer.
+func (c *Coverage) Start() {
+	c.ifs = nil
+	eval.SetTracer(c)
+}
+
+// Stop removes the tracer.
+func (c *Coverage) Stop() {
+	eval.SetTracer(nil)
+}
+
+func (c *Coverage) EnterNode(node ast.Node) {
+	switch node := node.(type) {
+	case ast.Statement:
+		if s, ok := c.statements[node]; ok {
+			s.hits++
+			return
+		}
+
+		// Only the branches of the innermost if in progress can start
+		// now, anything else its condition runs has finished.
+		if block, ok := node.(*ast.BlockStatement); ok && len(c.ifs) > 0 {
+			top := &c.ifs[len(c.ifs)-1]
+			switch block {
+			case top.branch.node.Consequence:
+				top.branch.hits[0]++
+				top.taken = true
+			case top.branch.node.Alternative:
+				top.branch.hits[1]++
+				top.taken = true
+			}
+		}
+	case *ast.IfExpression:
+		if b, ok := c.branches[node]; ok {
+			b.runs++
+			c.ifs = append(c.ifs, ifEntry{branch: b})
+		}
+	}
+}
+
+func (c *Coverage) ExitNode(node ast.Node, result object.Object) {
+	ie, ok := node.(*ast.IfExpression)
+	if !ok || c.branches[ie] == nil {
+		return
+	}
+
+	top := c.ifs[len(c.ifs)-1]
+	c.ifs = c.ifs[:len(c.ifs)-1]
+
+	// An if without else that ran neither branch had a false condition,
+	// unless evaluating the condition failed.
+	if _, failed := result.(*object.Error); !top.taken && !failed && ie.Alternative == nil {
+		top.branch.hits[1]++
+	}
+}
+
+func (c *Coverage) EnterCall(fn object.Object, args []object.Object) {
+	if f, ok := fn.(*object.Function); ok {
+		if function, ok := c.functions[f.Body]; ok {
+			function.calls++
+		}
+	}
+}
+
+func (c *Coverage) ExitCall(fn object.Object, result object.Object) {}
+
+// walk calls fn for node and all nodes below it.
+func walk(node ast.Node, fn func(ast.Node)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			fn(n)
+		}
+		return true
+	})
+}
@@ -0,0 +1,173 @@
+package cover_test
+
+import (
+	"bytes"
+	"monkey/internal/cover"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"strings"
+	"testing"
+)
+
+// run evaluates src with its coverage recorded.
+func run(t *testing.T, src string) *cover.Coverage {
+	t.Helper()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	if diags := resolver.New(eval.BuiltinNames()).Resolve(program); len(diags) != 0 {
+		t.Fatalf("resolver diagnostics: %v", diags)
+	}
+
+	c := cover.New()
+	c.Add("test.mk", src, program)
+	c.Start()
+	eval.Eval(program, object.NewEnv())
+	c.Stop()
+
+	return c
+}
+
+func TestSummaries(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected cover.Summary
+	}{
+		{
+			"everything runs",
+			"let a = 1; let b = a + 1; b",
+			cover.Summary{Statements: 3, StatementsRun: 3},
+		},
+		{
+			"uncalled function",
+			"let f = fn(x) { let y = x; y }; 1",
+			cover.Summary{Statements: 4, StatementsRun: 2, Functions: 1},
+		},
+		{
+			"if without else",
+			"let f = fn(x) { if (x) { 1 } }; f(false)",
+			cover.Summary{Statements: 4, StatementsRun: 3, Branches: 2, BranchesTaken: 1, Functions: 1, FunctionsCalled: 1},
+		},
+		{
+			"both branches",
+			"let f = fn(x) { if (x) { 1 } else { 2 } }; f(true); f(false)",
+			cover.Summary{Statements: 6, StatementsRun: 6, Branches: 2, BranchesTaken: 2, Functions: 1, FunctionsCalled: 1},
+		},
+		{
+			"nested ifs",
+			"let f = fn(x) { if (if (x) { true }) { 1 } }; f(true)",
+			cover.Summary{Statements: 5, StatementsRun: 5, Branches: 4, BranchesTaken: 2, Functions: 1, FunctionsCalled: 1},
+		},
+		{
+			"tail calls",
+			"let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(100)",
+			cover.Summary{Statements: 5, StatementsRun: 5, Branches: 2, BranchesTaken: 2, Functions: 1, FunctionsCalled: 1},
+		},
+		{
+			"failed condition",
+			"let f = fn() { if (1 + true) { 1 } }; f()",
+			cover.Summary{Statements: 4, StatementsRun: 3, Branches: 2, Functions: 1, FunctionsCalled: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summaries := run(t, tt.src).Summaries()
+			if len(summaries) != 1 {
+				t.Fatalf("got %d summaries, expected 1", len(summaries))
+			}
+
+			tt.expected.File = "test.mk"
+			if summaries[0] != tt.expected {
+				t.Errorf("got %+v, expected %+v", summaries[0], tt.expected)
+			}
+		})
+	}
+}
+
+const program = `let sign = fn(x) {
+    if (x < 0) {
+        return -1;
+    }
+    1
+};
+let unused = fn() { 0 };
+sign(1);
+sign(2);
+`
+
+func TestReports(t *testing.T) {
+	c := run(t, program)
+
+	t.Run("summary", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := c.WriteSummary(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "test.mk: statements 6/8 (75.0%), branches 1/2 (50.0%), functions 1/2 (50.0%)\n"
+		if buf.String() != expected {
+			t.Errorf("got %q, expected %q", buf.String(), expected)
+		}
+	})
+
+	t.Run("listing", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := c.WriteListing(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `test.mk
+    1        1 | let sign = fn(x) {
+    2       2* |     if (x < 0) {
+    3        0 |         return -1;
+    4          |     }
+    5        2 |     1
+    6          | };
+    7        1 | let unused = fn() { 0 };
+    8        1 | sign(1);
+    9        1 | sign(2);
+`
+		if buf.String() != expected {
+			t.Errorf("got\n%s\nexpected\n%s", buf.String(), expected)
+		}
+	})
+
+	t.Run("lcov", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := c.WriteLCOV(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.Join([]string{
+			"TN:", "SF:test.mk",
+			"FN:1,sign", "FN:7,unused", "FNDA:2,sign", "FNDA:0,unused", "FNF:2", "FNH:1",
+			"BRDA:2,0,0,0", "BRDA:2,0,1,2", "BRF:2", "BRH:1",
+			"DA:1,1", "DA:2,2", "DA:3,0", "DA:5,2", "DA:7,1", "DA:8,1", "DA:9,1", "LF:7", "LH:6",
+			"end_of_record", "",
+		}, "\n")
+		if buf.String() != expected {
+			t.Errorf("got\n%s\nexpected\n%s", buf.String(), expected)
+		}
+	})
+}
+
+func TestLineHits(t *testing.T) {
+	c := run(t, "let c = true;\nif (c) { 1 } else { 2 };\n")
+
+	var buf bytes.Buffer
+	if err := c.WriteLCOV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "\nDA:2,1\n") {
+		t.Errorf("line 2 not reported as run once in\n%s", buf.String())
+	}
+}
@@ -0,0 +1,186 @@
+package cover
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Summary is how much of one file ran.
+type Summary struct {
+	File                       string
+	Statements, StatementsRun  int
+	Branches, BranchesTaken    int
+	Functions, FunctionsCalled int
+}
+
+// Summaries returns a summary for every file, in the order they were
+// added.
+func (c *Coverage) Summaries() []Summary {
+	summaries := make([]Summary, 0, len(c.files))
+	for _, f := range c.files {
+		s := Summary{File: f.name, Statements: len(f.statements), Branches: 2 * len(f.branches), Functions: len(f.functions)}
+		for _, stmt := range f.statements {
+			if stmt.hits > 0 {
+				s.StatementsRun++
+			}
+		}
+		for _, b := range f.branches {
+			for _, hits := range b.hits {
+				if hits > 0 {
+					s.BranchesTaken++
+				}
+			}
+		}
+		for _, fn := range f.functions {
+			if fn.calls > 0 {
+				s.FunctionsCalled++
+			}
+		}
+
+		summaries = append(summaries, s)
+	}
+
+	return summaries
+}
+
+// WriteSummary writes a line per file with how many of its statements,
+// branches and functions ran.
+func (c *Coverage) WriteSummary(w io.Writer) error {
+	for _, s := range c.Summaries() {
+		_, err := fmt.Fprintf(w, "%s: statements %s, branches %s, functions %s\n", s.File,
+			ratio(s.StatementsRun, s.Statements), ratio(s.BranchesTaken, s.Branches), ratio(s.FunctionsCalled, s.Functions))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ratio(n, total int) string {
+	if total == 0 {
+		return "0/0"
+	}
+
+	return fmt.Sprintf("%d/%d (%.1f%%)", n, total, 100*float64(n)/float64(total))
+}
+
+// WriteListing writes the source of every file with the number of times
+// each line ran in front of it. A line runs as often as the statement on
+// it that ran most, as with gcov, so only lines where nothing ran show 0.
+// A * marks lines with an if that never took one of its branches.
+func (c *Coverage) WriteListing(w io.Writer) error {
+	for i, f := range c.files {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+
+		lines, _ := f.lines()
+		partial := make(map[int]bool)
+		for _, b := range f.branches {
+			if b.hits[0] == 0 || b.hits[1] == 0 {
+				partial[b.pos.Line] = true
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\n", f.name); err != nil {
+			return err
+		}
+
+		for i, text := range strings.Split(strings.TrimSuffix(f.src, "\n"), "\n") {
+			line := i + 1
+
+			count := ""
+			if hits, ok := lines[line]; ok {
+				count = fmt.Sprint(hits)
+			}
+			if partial[line] {
+				count += "*"
+			}
+
+			if _, err := fmt.Fprintf(w, "%5d %8s | %s\n", line, count, text); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lines returns how often the lines with statements ran, and those lines in
+// order.
+func (f *file) lines() (map[int]int, []int) {
+	hits := make(map[int]int)
+	var order []int
+	for _, s := range f.statements {
+		h, ok := hits[s.pos.Line]
+		if !ok {
+			order = append(order, s.pos.Line)
+		}
+		if !ok || s.hits > h {
+			hits[s.pos.Line] = s.hits
+		}
+	}
+
+	// Statements are added outermost first, which is not always line
+	// order.
+	sort.Ints(order)
+
+	return hits, order
+}
+
+// WriteLCOV writes the coverage in the LCOV tracefile format that tools
+// such as genhtml and most coverage services read. Every if is a block
+// of two branches, the consequence and the alternative.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	var b strings.Builder
+
+	for _, f := range c.files {
+		fmt.Fprintf(&b, "TN:\nSF:%s\n", f.name)
+
+		called := 0
+		for _, fn := range f.functions {
+			fmt.Fprintf(&b, "FN:%d,%s\n", fn.pos.Line, fn.name)
+		}
+		for _, fn := range f.functions {
+			fmt.Fprintf(&b, "FNDA:%d,%s\n", fn.calls, fn.name)
+			if fn.calls > 0 {
+				called++
+			}
+		}
+		fmt.Fprintf(&b, "FNF:%d\nFNH:%d\n", len(f.functions), called)
+
+		taken := 0
+		for i, br := range f.branches {
+			for j, hits := range br.hits {
+				count := "-"
+				if br.runs > 0 {
+					count = fmt.Sprint(hits)
+				}
+				if hits > 0 {
+					taken++
+				}
+
+				fmt.Fprintf(&b, "BRDA:%d,%d,%d,%s\n", br.pos.Line, i, j, count)
+			}
+		}
+		fmt.Fprintf(&b, "BRF:%d\nBRH:%d\n", 2*len(f.branches), taken)
+
+		hits, lines := f.lines()
+		run := 0
+		for _, line := range lines {
+			fmt.Fprintf(&b, "DA:%d,%d\n", line, hits[line])
+			if hits[line] > 0 {
+				run++
+			}
+		}
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\nend_of_record\n", len(lines), run)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
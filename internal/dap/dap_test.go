@@ -0,0 +1,267 @@
+package dap_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/internal/dap"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const program = `let square = fn(x) {
+    let y = x * x;
+    y
+};
+let squares = [square(2), square(3)];
+puts(squares);
+`
+
+type message struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	messages chan message
+	pending  []message
+	seq      int
+}
+
+func start(t *testing.T) *client {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "program.mk")
+	if err := os.WriteFile(path, []byte(program), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- dap.NewServer(inR, outW).Run()
+		outW.Close()
+	}()
+
+	c := &client{t: t, w: inW, messages: make(chan message, 100)}
+	go func() {
+		defer close(c.messages)
+
+		r := bufio.NewReader(outR)
+		for {
+			header, err := textproto.NewReader(r).ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+
+			length, _ := strconv.Atoi(header.Get("Content-Length"))
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+
+			var msg message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("decoding %s: %v", body, err)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+
+	t.Cleanup(func() {
+		c.request("disconnect", nil)
+		inW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Run() returned %v", err)
+		}
+	})
+
+	c.request("initialize", map[string]string{"adapterID": "monkey"})
+	c.event("initialized")
+	c.request("launch", map[string]interface{}{"program": path, "stopOnEntry": false})
+
+	return c
+}
+
+// request sends a request and returns the body of its response.
+func (c *client) request(command string, args interface{}) json.RawMessage {
+	c.t.Helper()
+
+	c.seq++
+	req := map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args}
+	body, _ := json.Marshal(req)
+	fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+
+	msg := c.next(func(msg message) bool { return msg.Type == "response" && msg.RequestSeq == c.seq })
+	if !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+
+	return msg.Body
+}
+
+// event waits for an event and returns its body.
+func (c *client) event(name string) json.RawMessage {
+	c.t.Helper()
+	return c.next(func(msg message) bool { return msg.Type == "event" && msg.Event == name }).Body
+}
+
+// next returns the first message that matches, keeping the others for
+// later calls.
+func (c *client) next(match func(message) bool) message {
+	c.t.Helper()
+
+	for i, msg := range c.pending {
+		if match(msg) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return msg
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatal("server closed the connection")
+			}
+			if match(msg) {
+				return msg
+			}
+			c.pending = append(c.pending, msg)
+		case <-timeout:
+			c.t.Fatal("timed out waiting for a message")
+		}
+	}
+}
+
+func (c *client) stopped(reason string) {
+	c.t.Helper()
+
+	var body struct{ Reason string }
+	json.Unmarshal(c.event("stopped"), &body)
+	if body.Reason != reason {
+		c.t.Errorf("stopped for %q, expected %q", body.Reason, reason)
+	}
+}
+
+func (c *client) stack() string {
+	c.t.Helper()
+
+	var body struct {
+		StackFrames []struct {
+			Name string
+			Line int
+		}
+	}
+	json.Unmarshal(c.request("stackTrace", map[string]int{"threadId": 1}), &body)
+
+	s := ""
+	for _, frame := range body.StackFrames {
+		s += fmt.Sprintf("%s:%d ", frame.Name, frame.Line)
+	}
+	return s
+}
+
+func TestSession(t *testing.T) {
+	c := start(t)
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "program.mk"},
+		"breakpoints": []map[string]int{{"line": 3}},
+	})
+	c.request("configurationDone", nil)
+
+	c.stopped("breakpoint")
+	if got, expected := c.stack(), "square:3 main:5 "; got != expected {
+		t.Errorf("stack is %q, expected %q", got, expected)
+	}
+
+	var scopes struct {
+		Scopes []struct {
+			Name               string
+			VariablesReference int
+		}
+	}
+	json.Unmarshal(c.request("scopes", map[string]int{"frameId": 0}), &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("unexpected scopes %+v", scopes.Scopes)
+	}
+
+	var variables struct {
+		Variables []struct{ Name, Value string }
+	}
+	json.Unmarshal(c.request("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference}), &variables)
+	if got := fmt.Sprint(variables.Variables); got != "[{x 2} {y 4}]" {
+		t.Errorf("locals are %s, expected [{x 2} {y 4}]", got)
+	}
+
+	var result struct{ Result string }
+	json.Unmarshal(c.request("evaluate", map[string]interface{}{"expression": "y + 1", "frameId": 0}), &result)
+	if result.Result != "5" {
+		t.Errorf("y + 1 evaluated to %q, expected 5", result.Result)
+	}
+
+	// Stepping over the end of a function does not stop in the next call
+	// the caller makes.
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "program.mk"},
+		"breakpoints": []map[string]int{},
+	})
+	c.request("next", map[string]int{"threadId": 1})
+	c.stopped("step")
+	if got, expected := c.stack(), "main:6 "; got != expected {
+		t.Errorf("stack is %q, expected %q", got, expected)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1})
+
+	var output struct{ Category, Output string }
+	json.Unmarshal(c.event("output"), &output)
+	if output.Category != "stdout" || output.Output != "[4, 9]\n" {
+		t.Errorf("unexpected output %+v", output)
+	}
+
+	var exited struct{ ExitCode int }
+	json.Unmarshal(c.event("exited"), &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code is %d, expected 0", exited.ExitCode)
+	}
+	c.event("terminated")
+}
+
+func TestStopOnEntry(t *testing.T) {
+	c := start(t)
+	c.request("configurationDone", nil)
+
+	// The program was launched without stopping on entry, so it runs to
+	// the end.
+	c.event("terminated")
+}
+
+func TestTerminate(t *testing.T) {
+	c := start(t)
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "program.mk"},
+		"breakpoints": []map[string]int{{"line": 2}},
+	})
+	c.request("configurationDone", nil)
+	c.stopped("breakpoint")
+
+	c.request("terminate", nil)
+	c.event("terminated")
+}
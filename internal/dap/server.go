@@ -0,0 +1,514 @@
+// Package dap implements a Debug Adapter Protocol server, which lets editors
+// run Monkey programs under the debugger. It speaks over a pair of streams,
+// usually standard input and output, and debugs one program per session.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/internal/debug"
+	"monkey/internal/diagnostic"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"net/textproto"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// threadID is the only thread a Monkey program has.
+const threadID = 1
+
+// Server handles one client. Requests are read and answered on the
+// goroutine calling Run, while the program runs on another one and reports
+// its stops and output as events.
+type Server struct {
+	in *bufio.Reader
+
+	mu          sync.Mutex // guards out, seq, paused and terminating
+	out         io.Writer
+	seq         int
+	paused      bool
+	terminating bool
+
+	debugger *debug.Debugger
+	program  string
+	source   string
+	launched bool
+	started  bool
+	done     chan struct{}
+
+	resume chan debug.Action
+	refs   []interface{} // variable references, valid while paused
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:       bufio.NewReader(in),
+		out:      out,
+		debugger: debug.New(),
+		done:     make(chan struct{}),
+		resume:   make(chan debug.Action),
+	}
+}
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       interface{}     `json:"body,omitempty"`
+}
+
+// Run serves requests until the client disconnects or closes the input.
+func (s *Server) Run() error {
+	defer s.terminate()
+
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req message
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("invalid message: %s", err)
+		}
+
+		if req.Type != "request" {
+			continue
+		}
+
+		result, err := s.handle(&req)
+		resp := &message{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: result}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+
+		if err := s.send(resp); err != nil {
+			return err
+		}
+
+		switch req.Command {
+		case "initialize":
+			if err := s.event("initialized", nil); err != nil {
+				return err
+			}
+		case "disconnect":
+			return nil
+		}
+	}
+}
+
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func (s *Server) send(msg *message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	msg.Seq = s.seq
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+
+	_, err = s.out.Write(body)
+	return err
+}
+
+func (s *Server) event(name string, body interface{}) error {
+	return s.send(&message{Type: "event", Event: name, Body: body})
+}
+
+func (s *Server) handle(req *message) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args.Program, args.StopOnEntry)
+	case "setBreakpoints":
+		var args struct {
+			Breakpoints []struct {
+				Line int `json:"line"`
+			} `json:"breakpoints"`
+		}
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+
+		lines := make([]int, len(args.Breakpoints))
+		breakpoints := make([]map[string]interface{}, len(args.Breakpoints))
+		for i, bp := range args.Breakpoints {
+			lines[i] = bp.Line
+			breakpoints[i] = map[string]interface{}{"verified": true, "line": bp.Line}
+		}
+		s.debugger.SetBreakpoints(lines)
+
+		return map[string]interface{}{"breakpoints": breakpoints}, nil
+	case "setExceptionBreakpoints":
+		return nil, nil
+	case "configurationDone":
+		s.started = true
+		return nil, s.start()
+	case "threads":
+		return map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": threadID, "name": "main"}},
+		}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args.FrameID)
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args.VariablesReference)
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+		}
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args.Expression, args.FrameID)
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, s.proceed(debug.Continue)
+	case "next":
+		return nil, s.proceed(debug.StepOver)
+	case "stepIn":
+		return nil, s.proceed(debug.StepIn)
+	case "stepOut":
+		return nil, s.proceed(debug.StepOut)
+	case "pause":
+		s.debugger.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.terminate()
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported request %q", req.Command)
+}
+
+func decode(req *message, args interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(req.Arguments, args)
+}
+
+func (s *Server) launch(program string, stopOnEntry bool) error {
+	if s.launched {
+		return errors.New("a program is already launched")
+	}
+
+	src, err := os.ReadFile(program)
+	if err != nil {
+		return err
+	}
+
+	s.program, s.source, s.launched = program, string(src), true
+	if stopOnEntry {
+		s.debugger.StopOnEntry()
+	}
+
+	return s.start()
+}
+
+// start runs the program once it is launched and the client is done
+// configuring breakpoints, whichever comes last.
+func (s *Server) start() error {
+	if !s.launched || !s.started {
+		return nil
+	}
+
+	p := parser.New(lexer.New(s.source))
+	program := p.ParseProgram()
+	diags := p.Diagnostics()
+	if len(diags) == 0 {
+		diags = resolver.New(eval.BuiltinNames()).Resolve(program)
+	}
+
+	for _, d := range diags {
+		s.output("stderr", fmt.Sprintf("%s:%s\n", s.program, d))
+	}
+
+	s.debugger.Stopped = s.stopped
+
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		if !diagnostic.HasErrors(diags) {
+			defer eval.SetOutput(eval.SetOutput(outputWriter{s}))
+
+			result := s.debugger.Run(program, object.NewEnv())
+			if err, ok := result.(*object.Error); ok && err.Message != debug.ErrTerminated.Error() {
+				s.output("stderr", err.Inspect()+"\n")
+				exitCode = 1
+			}
+		} else {
+			exitCode = 1
+		}
+
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+
+	return nil
+}
+
+// stopped reports a stop to the client and waits for it to say how to go
+// on. It runs on the goroutine running the program.
+func (s *Server) stopped(d *debug.Debugger, stop debug.Stop) debug.Action {
+	s.mu.Lock()
+	if s.terminating {
+		s.mu.Unlock()
+		return debug.Terminate
+	}
+	s.paused = true
+	s.refs = nil
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]interface{}{
+		"reason":            stop.Reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+
+	return <-s.resume
+}
+
+func (s *Server) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.paused
+}
+
+func (s *Server) proceed(action debug.Action) error {
+	s.mu.Lock()
+	paused := s.paused
+	s.paused = false
+	s.mu.Unlock()
+
+	if !paused {
+		return errors.New("the program is not paused")
+	}
+
+	s.resume <- action
+	return nil
+}
+
+// terminate stops the program if it runs and waits for it to finish.
+func (s *Server) terminate() {
+	if !s.launched || !s.started {
+		return
+	}
+
+	s.mu.Lock()
+	s.terminating = true
+	s.paused = false
+	s.mu.Unlock()
+
+	// The program stops at its next statement if it runs, or right away if
+	// it waits in stopped.
+	s.debugger.Pause()
+	select {
+	case <-s.done:
+	case s.resume <- debug.Terminate:
+		<-s.done
+	}
+}
+
+func (s *Server) stackTrace() (interface{}, error) {
+	if !s.isPaused() {
+		return nil, errors.New("the program is not paused")
+	}
+
+	frames := []map[string]interface{}{}
+	for i, frame := range s.debugger.Stack() {
+		frames = append(frames, map[string]interface{}{
+			"id":     i,
+			"name":   frame.Name,
+			"line":   frame.Pos.Line,
+			"column": frame.Pos.Column,
+			"source": map[string]string{"path": s.program},
+		})
+	}
+
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+func (s *Server) scopes(frame int) (interface{}, error) {
+	if !s.isPaused() {
+		return nil, errors.New("the program is not paused")
+	}
+
+	scopes := []map[string]interface{}{}
+	for _, scope := range s.debugger.Scopes(frame) {
+		scopes = append(scopes, map[string]interface{}{
+			"name":               scope.Name,
+			"variablesReference": s.reference(scope.Variables),
+			"expensive":          false,
+		})
+	}
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+// reference returns a variables reference the client can expand v with.
+// References are numbered from 1, as 0 means there is nothing to expand.
+func (s *Server) reference(v interface{}) int {
+	s.refs = append(s.refs, v)
+	return len(s.refs)
+}
+
+func (s *Server) variables(ref int) (interface{}, error) {
+	if !s.isPaused() {
+		return nil, errors.New("the program is not paused")
+	}
+
+	if ref < 1 || ref > len(s.refs) {
+		return nil, fmt.Errorf("unknown variables reference %d", ref)
+	}
+
+	vars := []map[string]interface{}{}
+	add := func(name string, val object.Object) {
+		vars = append(vars, map[string]interface{}{
+			"name":               name,
+			"value":              val.Inspect(),
+			"type":               val.Type().String(),
+			"variablesReference": s.children(val),
+		})
+	}
+
+	switch v := s.refs[ref-1].(type) {
+	case []debug.Variable:
+		for _, variable := range v {
+			add(variable.Name, variable.Value)
+		}
+	case *object.Array:
+		for i, e := range v.Elements {
+			add(strconv.Itoa(i), e)
+		}
+	case *object.HashMap:
+		for _, pair := range v.Pairs() {
+			add(pair.Key.Inspect(), pair.Value)
+		}
+	}
+
+	return map[string]interface{}{"variables": vars}, nil
+}
+
+// children returns a reference to the elements of arrays and hash maps,
+// or 0 for values that have none.
+func (s *Server) children(val object.Object) int {
+	switch val := val.(type) {
+	case *object.Array:
+		if len(val.Elements) > 0 {
+			return s.reference(val)
+		}
+	case *object.HashMap:
+		if val.Len() > 0 {
+			return s.reference(val)
+		}
+	}
+
+	return 0
+}
+
+func (s *Server) evaluate(expr string, frame int) (interface{}, error) {
+	if !s.isPaused() {
+		return nil, errors.New("the program is not paused")
+	}
+
+	result, err := s.debugger.Evaluate(expr, frame)
+	if err != nil {
+		return nil, err
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+
+	return map[string]interface{}{
+		"result":             result.Inspect(),
+		"type":               result.Type().String(),
+		"variablesReference": s.children(result),
+	}, nil
+}
+
+func (s *Server) output(category, text string) {
+	s.event("output", map[string]string{"category": category, "output": text})
+}
+
+// outputWriter sends what the program prints to the client.
+type outputWriter struct {
+	s *Server
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.output("stdout", string(p))
+	return len(p), nil
+}
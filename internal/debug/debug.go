@@ -0,0 +1,313 @@
+// Package debug implements a step debugger on top of the evaluator's hooks.
+// A Debugger runs a program and stops it at breakpoints, after steps and
+// when asked to, handing control to a frontend such as the REPL or the
+// Debug Adapter Protocol server until it decides how to go on.
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/token"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrTerminated is the error a program stops with when the frontend
+// terminates it.
+var ErrTerminated = errors.New("terminated by the debugger")
+
+// Action tells a stopped program how to go on.
+type Action int
+
+const (
+	// Continue runs until the next breakpoint.
+	Continue Action = iota
+	// StepIn stops at the next statement, in a function it calls if the
+	// current statement calls one.
+	StepIn
+	// StepOver stops at the next statement of the current function or of
+	// one it returns to.
+	StepOver
+	// StepOut stops at the next statement of the function the current one
+	// returns to.
+	StepOut
+	// Terminate stops the program with ErrTerminated.
+	Terminate
+)
+
+// Reasons a program stops for.
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// Stop describes where and why a program stopped.
+type Stop struct {
+	Reason string
+	Pos    token.Position
+}
+
+// Frame is a function call in progress. The outermost frame is the program
+// itself.
+type Frame struct {
+	Name string
+	Fn   *object.Function // nil for the program
+	Env  *object.Environment
+	Pos  token.Position // of the statement being run
+}
+
+// Debugger runs programs under its control. Its breakpoints can be set and
+// Pause called from any goroutine; everything else belongs to the
+// goroutine that called Run, or to a frontend while the program is stopped.
+type Debugger struct {
+	// Stopped is called on the goroutine running the program whenever it
+	// stops. The program waits for it to return what to do next.
+	Stopped func(d *Debugger, stop Stop) Action
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	pause       bool
+
+	stack      []*Frame
+	action     Action
+	frame      *Frame // where the current step over or out stops
+	entry      bool   // stop at the first statement
+	evaluating bool
+}
+
+func New() *Debugger {
+	return &Debugger{breakpoints: make(map[int]bool)}
+}
+
+// StopOnEntry makes the next Run stop before the first statement.
+func (d *Debugger) StopOnEntry() {
+	d.entry = true
+}
+
+// SetBreakpoints replaces the breakpoints with ones on the given lines.
+func (d *Debugger) SetBreakpoints(lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = make(map[int]bool)
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+// Breakpoints returns the lines with a breakpoint in ascending order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	return lines
+}
+
+// Pause stops the running program before its next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = true
+	d.mu.Unlock()
+}
+
+// Run evaluates a resolved program in env under the debugger and returns
+// its result.
+func (d *Debugger) Run(program *ast.Program, env *object.Environment) object.Object {
+	d.stack = []*Frame{{Name: "main", Env: env}}
+	d.action = Continue
+
+	defer eval.SetHooks(eval.SetHooks(d))
+	result := eval.Eval(program, env)
+	d.stack = nil
+
+	return result
+}
+
+// Stack returns the calls in progress, innermost first.
+func (d *Debugger) Stack() []*Frame {
+	frames := make([]*Frame, len(d.stack))
+	for i, frame := range d.stack {
+		frames[len(frames)-1-i] = frame
+	}
+
+	return frames
+}
+
+// Evaluate evaluates the expression src in the environment of frame, an
+// index into Stack. The program is not affected by the debugger while the
+// expression runs.
+func (d *Debugger) Evaluate(src string, frame int) (object.Object, error) {
+	if frame < 0 || frame >= len(d.stack) {
+		return nil, fmt.Errorf("no frame %d", frame)
+	}
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+
+	// The expression is left unresolved, so its identifiers are looked up
+	// by name, which finds slot bindings too.
+	result := eval.Eval(program, d.stack[len(d.stack)-1-frame].Env)
+	if result == nil {
+		return eval.NULL, nil
+	}
+
+	return result, nil
+}
+
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) error {
+	if d.evaluating {
+		return nil
+	}
+
+	frame := d.stack[len(d.stack)-1]
+	line := frame.Pos.Line
+	frame.Pos = stmtPos(stmt)
+
+	reason := d.reason(line, frame.Pos.Line)
+	if reason == "" {
+		return nil
+	}
+
+	action := Continue
+	if d.Stopped != nil {
+		action = d.Stopped(d, Stop{Reason: reason, Pos: frame.Pos})
+	}
+
+	if action == Terminate {
+		return ErrTerminated
+	}
+
+	d.action = action
+	d.frame = frame
+	return nil
+}
+
+// reason returns why the program stops before a statement on line, or ""
+// if it does not. prev is the line of the previous statement of the same
+// call, so that a breakpoint stops once for a line of many statements.
+func (d *Debugger) reason(prev, line int) string {
+	d.mu.Lock()
+	pause, breakpoint := d.pause, d.breakpoints[line]
+	d.pause = false
+	d.mu.Unlock()
+
+	switch {
+	case d.entry:
+		d.entry = false
+		return ReasonEntry
+	case pause:
+		return ReasonPause
+	case d.action == StepIn, d.action == StepOver && d.stack[len(d.stack)-1] == d.frame:
+		return ReasonStep
+	case breakpoint && line != prev:
+		return ReasonBreakpoint
+	}
+
+	return ""
+}
+
+func (d *Debugger) Call(fn *object.Function, env *object.Environment) {
+	if d.evaluating {
+		return
+	}
+
+	name := fn.Name
+	if name == "" {
+		name = fmt.Sprintf("<fn at %s>", fn.Body.Token.Pos)
+	}
+
+	d.stack = append(d.stack, &Frame{Name: name, Fn: fn, Env: env})
+}
+
+func (d *Debugger) Return(fn *object.Function, result object.Object) {
+	if d.evaluating || len(d.stack) <= 1 {
+		return
+	}
+
+	// Stepping over or out of a function that returns continues as a step
+	// over in its caller. A tail call counts as returning, so stepping
+	// over one does not stop in the function it calls.
+	top := d.stack[len(d.stack)-1]
+	if (d.action == StepOver || d.action == StepOut) && top == d.frame {
+		d.action = StepOver
+		d.frame = d.stack[len(d.stack)-2]
+	}
+
+	d.stack = d.stack[:len(d.stack)-1]
+}
+
+// Variable is a binding of an environment.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Scope is one environment of the chain a frame sees.
+type Scope struct {
+	Name      string
+	Variables []Variable
+}
+
+// Scopes returns the environments visible from frame, innermost first:
+// the locals of the call, those of the functions it is nested in, and the
+// globals.
+func (d *Debugger) Scopes(frame int) []Scope {
+	if frame < 0 || frame >= len(d.stack) {
+		return nil
+	}
+
+	var scopes []Scope
+	for env := d.stack[len(d.stack)-1-frame].Env; env != nil; env = env.Outer() {
+		name := "Closure"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case len(scopes) == 0:
+			name = "Locals"
+		}
+
+		scope := Scope{Name: name, Variables: []Variable{}}
+		env.Each(func(name string, val object.Object) {
+			scope.Variables = append(scope.Variables, Variable{name, val})
+		})
+
+		scopes = append(scopes, scope)
+	}
+
+	return scopes
+}
+
+func stmtPos(stmt ast.Statement) token.Position {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos
+	case *ast.BadStatement:
+		return stmt.Token.Pos
+	}
+
+	return token.Position{}
+}
@@ -0,0 +1,168 @@
+package debug_test
+
+import (
+	"fmt"
+	"io"
+	"monkey/internal/debug"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"strings"
+	"testing"
+)
+
+const program = `let double = fn(x) {
+    let y = x * 2;
+    y
+};
+let a = double(1);
+let b = double(a);
+puts(a + b);
+`
+
+// session runs program under a debugger that performs the given actions at
+// its stops, and returns where it stopped and the result.
+func session(t *testing.T, breakpoints []int, entry bool, actions ...debug.Action) ([]string, object.Object) {
+	t.Helper()
+
+	p := parser.New(lexer.New(program))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	resolver.New(eval.BuiltinNames()).Resolve(prog)
+
+	d := debug.New()
+	d.SetBreakpoints(breakpoints)
+	if entry {
+		d.StopOnEntry()
+	}
+
+	var stops []string
+	d.Stopped = func(d *debug.Debugger, stop debug.Stop) debug.Action {
+		var names []string
+		for _, frame := range d.Stack() {
+			names = append(names, frame.Name)
+		}
+		stops = append(stops, fmt.Sprintf("%s %d %s", stop.Reason, stop.Pos.Line, strings.Join(names, "<")))
+
+		if len(actions) == 0 {
+			return debug.Continue
+		}
+
+		action := actions[0]
+		actions = actions[1:]
+		return action
+	}
+
+	defer eval.SetOutput(eval.SetOutput(io.Discard))
+	return stops, d.Run(prog, object.NewEnv())
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		entry       bool
+		actions     []debug.Action
+		expected    []string
+	}{
+		{
+			"breakpoints",
+			[]int{2, 7}, false, nil,
+			[]string{"breakpoint 2 double<main", "breakpoint 2 double<main", "breakpoint 7 main"},
+		},
+		{
+			"step in",
+			nil, true, []debug.Action{debug.StepIn, debug.StepIn, debug.StepIn, debug.StepIn},
+			[]string{"entry 1 main", "step 5 main", "step 2 double<main", "step 3 double<main", "step 6 main"},
+		},
+		{
+			"step over",
+			nil, true, []debug.Action{debug.StepOver, debug.StepOver, debug.StepOver},
+			[]string{"entry 1 main", "step 5 main", "step 6 main", "step 7 main"},
+		},
+		{
+			"step out",
+			[]int{2}, false, []debug.Action{debug.StepOut},
+			[]string{"breakpoint 2 double<main", "step 6 main", "breakpoint 2 double<main"},
+		},
+		{
+			"one stop for a line of many statements",
+			[]int{5}, false, []debug.Action{debug.StepOver},
+			[]string{"breakpoint 5 main", "step 6 main"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stops, result := session(t, tt.breakpoints, tt.entry, tt.actions...)
+			if strings.Join(stops, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("stops are\n%s\nexpected\n%s", strings.Join(stops, "\n"), strings.Join(tt.expected, "\n"))
+			}
+
+			if result == nil || result.Type() != object.T_NULL {
+				t.Errorf("program returned %v, expected null", result)
+			}
+		})
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	_, result := session(t, []int{3}, false, debug.Terminate)
+
+	err, ok := result.(*object.Error)
+	if !ok || err.Message != debug.ErrTerminated.Error() {
+		t.Errorf("program returned %v, expected %q", result, debug.ErrTerminated)
+	}
+}
+
+func TestInspection(t *testing.T) {
+	p := parser.New(lexer.New(program))
+	prog := p.ParseProgram()
+	resolver.New(eval.BuiltinNames()).Resolve(prog)
+
+	d := debug.New()
+	d.SetBreakpoints([]int{3})
+
+	var got []string
+	d.Stopped = func(d *debug.Debugger, stop debug.Stop) debug.Action {
+		for _, scope := range d.Scopes(0) {
+			var vars []string
+			for _, v := range scope.Variables {
+				vars = append(vars, v.Name+"="+v.Value.Inspect())
+			}
+			got = append(got, scope.Name+": "+strings.Join(vars, " "))
+		}
+
+		for _, expr := range []string{"x + y", "double(10)", "a", "z"} {
+			result, err := d.Evaluate(expr, 0)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", expr, err)
+			}
+			got = append(got, expr+" = "+result.Inspect())
+		}
+
+		if _, err := d.Evaluate("x +", 0); err == nil {
+			t.Error("Evaluate of a syntax error succeeded")
+		}
+
+		return debug.Terminate
+	}
+
+	d.Run(prog, object.NewEnv())
+
+	expected := []string{
+		"Locals: x=1 y=2",
+		"Globals: double=fn(x) {\n{ let y = (x * 2);y }\n}",
+		"x + y = 3",
+		"double(10) = 20",
+		"a = ERROR: identifier not found: a",
+		"z = ERROR: identifier not found: z",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
@@ -0,0 +1,57 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"monkey/internal/token"
+)
+
+type Severity uint
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	}
+
+	return "unknown"
+}
+
+// Diagnostic is a problem found in source code before it is evaluated.
+type Diagnostic struct {
+	Pos      token.Position
+	Severity Severity
+	Message  string
+	Code     string // names the check that found the problem, if it has a name
+}
+
+func (d Diagnostic) String() string {
+	if d.Code != "" {
+		return fmt.Sprintf("%s: %s: %s [%s]", d.Pos, d.Severity, d.Message, d.Code)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+}
+
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+
+	return false
+}
+
+func Print(out io.Writer, diags []Diagnostic) {
+	for _, d := range diags {
+		io.WriteString(out, "\t"+d.String()+"\n")
+	}
+}
@@ -0,0 +1,145 @@
+// Package dot draws syntax trees and call graphs in the DOT language of
+// Graphviz, to be rendered with, for example, dot -Tsvg.
+package dot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/callgraph"
+	"strconv"
+	"strings"
+)
+
+// AST writes the tree below node as a graph with a box for every node,
+// labelled with its kind, its operator, name or value if it has one, and
+// its position. Children are drawn left to right in source order. Type
+// annotations have dashed boxes and the nodes standing for syntax errors
+// red ones.
+func AST(w io.Writer, node ast.Node) error {
+	var buf bytes.Buffer
+
+	buf.WriteString("digraph ast {\n")
+	buf.WriteString("\tordering=out;\n")
+	buf.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	var parents []int
+	count := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			parents = parents[:len(parents)-1]
+			return false
+		}
+
+		id := count
+		count++
+
+		fmt.Fprintf(&buf, "\tn%d [label=%s%s];\n", id, quote(label(n)), style(n))
+		if len(parents) > 0 {
+			fmt.Fprintf(&buf, "\tn%d -> n%d;\n", parents[len(parents)-1], id)
+		}
+
+		parents = append(parents, id)
+		return true
+	})
+
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func label(node ast.Node) string {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+
+	var detail string
+	switch n := node.(type) {
+	case *ast.Program:
+		return kind
+	case *ast.ID:
+		detail = n.Value
+	case *ast.IntegerLiteral:
+		detail = n.Token.Literal
+	case *ast.BooleanExpression:
+		detail = n.Token.Literal
+	case *ast.StringLiteral:
+		detail = strconv.Quote(n.Value)
+	case *ast.PrefixExpression:
+		detail = n.Operator
+	case *ast.InfixExpression:
+		detail = n.Operator
+	case *ast.FunctionLiteral:
+		detail = n.Name
+	case *ast.NamedType:
+		detail = n.Name
+	}
+
+	if detail != "" {
+		kind += " " + detail
+	}
+
+	return kind + "\n" + ast.TokenOf(node).Pos.String()
+}
+
+func style(node ast.Node) string {
+	switch node.(type) {
+	case ast.Type:
+		return ", style=dashed"
+	case *ast.BadStatement, *ast.BadExpression:
+		return ", color=red"
+	}
+
+	return ""
+}
+
+// CallGraph writes g with an ellipse for every function, labelled with its
+// name and position, and a box for the top level of the program if it
+// uses any of them. Calls are solid arrows, other uses of a function, like
+// passing it to another one, dashed ones.
+func CallGraph(w io.Writer, g *callgraph.Graph) error {
+	var buf bytes.Buffer
+
+	buf.WriteString("digraph callgraph {\n")
+	buf.WriteString("\tnode [fontname=\"monospace\"];\n")
+
+	ids := make(map[*callgraph.Function]string)
+	for i, f := range g.Functions {
+		ids[f] = "f" + strconv.Itoa(i)
+		fmt.Fprintf(&buf, "\t%s [label=%s];\n", ids[f], quote(f.Name+"\n"+f.Pos.String()))
+	}
+
+	// The edges from the top level come first.
+	if len(g.Edges) > 0 && g.Edges[0].Caller == nil {
+		buf.WriteString("\ttop [label=\"<top level>\", shape=box];\n")
+	}
+
+	for _, e := range g.Edges {
+		caller := "top"
+		if e.Caller != nil {
+			caller = ids[e.Caller]
+		}
+
+		attrs := ""
+		if !e.Call {
+			attrs = " [style=dashed]"
+		}
+
+		fmt.Fprintf(&buf, "\t%s -> %s%s;\n", caller, ids[e.Callee], attrs)
+	}
+
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// quote returns s as a DOT string. Backslashes are doubled because DOT
+// gives some of them a meaning in labels.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+
+	return `"` + s + `"`
+}
@@ -0,0 +1,74 @@
+package dot_test
+
+import (
+	"bytes"
+	"monkey/internal/callgraph"
+	"monkey/internal/dot"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"testing"
+)
+
+func TestAST(t *testing.T) {
+	program := parser.New(lexer.New(`let s: string = "a\"b\\c" + -1; let = 2;`)).ParseProgram()
+
+	var buf bytes.Buffer
+	if err := dot.AST(&buf, program); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `digraph ast {
+	ordering=out;
+	node [shape=box, fontname="monospace"];
+	n0 [label="Program"];
+	n1 [label="LetStatement\n1:1"];
+	n0 -> n1;
+	n2 [label="ID s\n1:5"];
+	n1 -> n2;
+	n3 [label="NamedType string\n1:8", style=dashed];
+	n2 -> n3;
+	n4 [label="InfixExpression +\n1:27"];
+	n1 -> n4;
+	n5 [label="StringLiteral \"a\\\"b\\\\c\"\n1:17"];
+	n4 -> n5;
+	n6 [label="PrefixExpression -\n1:29"];
+	n4 -> n6;
+	n7 [label="IntegerLiteral 1\n1:30"];
+	n6 -> n7;
+	n8 [label="BadStatement\n1:33", color=red];
+	n0 -> n8;
+}
+`
+
+	if buf.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestCallGraph(t *testing.T) {
+	program := parser.New(lexer.New(`let f = fn() { g() }; let g = fn() { map([], f) }; f();`)).ParseProgram()
+	r := resolver.New(eval.BuiltinNames())
+	r.Resolve(program)
+
+	var buf bytes.Buffer
+	if err := dot.CallGraph(&buf, callgraph.Build(program, r)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `digraph callgraph {
+	node [fontname="monospace"];
+	f0 [label="f\n1:5"];
+	f1 [label="g\n1:27"];
+	top [label="<top level>", shape=box];
+	top -> f0;
+	f0 -> f1;
+	f1 -> f0 [style=dashed];
+}
+`
+
+	if buf.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
@@ -0,0 +1,559 @@
+package eval
+
+import (
+	"monkey/internal/object"
+	"sort"
+)
+
+func init() {
+	for name, builtin := range arrayBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// The higher-order builtins call back into Monkey through callback. Element
+// callbacks may also declare a parameter for the index, so `map` accepts
+// both fn(x) and fn(x, i).
+var arrayBuiltins = map[string]*object.Builtin{
+	"map": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunc("map", args)
+			if err != nil {
+				return err
+			}
+
+			result := make([]object.Object, len(arr.Elements))
+			for i, e := range arr.Elements {
+				val := callback(fn, []object.Object{e}, &object.Integer{Value: int64(i)})
+				if isErr(val) {
+					return val
+				}
+
+				result[i] = val
+			}
+
+			return &object.Array{Elements: result}
+		},
+	},
+	"filter": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunc("filter", args)
+			if err != nil {
+				return err
+			}
+
+			result := []object.Object{}
+			for i, e := range arr.Elements {
+				val := callback(fn, []object.Object{e}, &object.Integer{Value: int64(i)})
+				if isErr(val) {
+					return val
+				}
+
+				if isTrue(val) {
+					result = append(result, e)
+				}
+			}
+
+			return &object.Array{Elements: result}
+		},
+	},
+	"reduce": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("reduce", args, 2, 3); err != nil {
+				return err
+			}
+
+			arr, fn, err := arrayAndFunc("reduce", args[:2])
+			if err != nil {
+				return err
+			}
+
+			elements := arr.Elements
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else if len(elements) > 0 {
+				acc, elements = elements[0], elements[1:]
+			} else {
+				return newError("`reduce` of an empty array with no initial value")
+			}
+
+			for _, e := range elements {
+				acc = callback(fn, []object.Object{acc, e})
+				if isErr(acc) {
+					return acc
+				}
+			}
+
+			return acc
+		},
+	},
+	"each": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("each", args, 2, 2); err != nil {
+				return err
+			}
+
+			if hashMap, ok := args[0].(*object.HashMap); ok {
+				return eachPair(hashMap, args[1])
+			}
+
+			arr, fn, err := arrayAndFunc("each", args)
+			if err != nil {
+				return err
+			}
+
+			for i, e := range arr.Elements {
+				if val := callback(fn, []object.Object{e}, &object.Integer{Value: int64(i)}); isErr(val) {
+					return val
+				}
+			}
+
+			return NULL
+		},
+	},
+	"find": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunc("find", args)
+			if err != nil {
+				return err
+			}
+
+			for i, e := range arr.Elements {
+				val := callback(fn, []object.Object{e}, &object.Integer{Value: int64(i)})
+				if isErr(val) {
+					return val
+				}
+
+				if isTrue(val) {
+					return e
+				}
+			}
+
+			return NULL
+		},
+	},
+	"any": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunc("any", args)
+			if err != nil {
+				return err
+			}
+
+			for _, e := range arr.Elements {
+				val := callback(fn, []object.Object{e})
+				if isErr(val) {
+					return val
+				}
+
+				if isTrue(val) {
+					return TRUE
+				}
+			}
+
+			return FALSE
+		},
+	},
+	"all": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunc("all", args)
+			if err != nil {
+				return err
+			}
+
+			for _, e := range arr.Elements {
+				val := callback(fn, []object.Object{e})
+				if isErr(val) {
+					return val
+				}
+
+				if !isTrue(val) {
+					return FALSE
+				}
+			}
+
+			return TRUE
+		},
+	},
+	"sort": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("sort", args, 1, 2); err != nil {
+				return err
+			}
+
+			arr, err := arrayArg("sort", args, 0)
+			if err != nil {
+				return err
+			}
+
+			less := compareObjects
+			if len(args) == 2 {
+				if err := funcArg("sort", args, 1); err != nil {
+					return err
+				}
+
+				less = func(a, b object.Object) (bool, *object.Error) {
+					return comparatorLess(args[1], a, b)
+				}
+			}
+
+			result := make([]object.Object, len(arr.Elements))
+			copy(result, arr.Elements)
+
+			var sortErr *object.Error
+			sort.SliceStable(result, func(i, j int) bool {
+				if sortErr != nil {
+					return false
+				}
+
+				isLess, err := less(result[i], result[j])
+				if err != nil {
+					sortErr = err
+				}
+
+				return isLess
+			})
+
+			if sortErr != nil {
+				return sortErr
+			}
+
+			return &object.Array{Elements: result}
+		},
+	},
+	"reverse": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("reverse", args, 1, 1); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				n := len(arg.Elements)
+				result := make([]object.Object, n)
+				for i, e := range arg.Elements {
+					result[n-1-i] = e
+				}
+
+				return &object.Array{Elements: result}
+			case *object.String:
+				runes := []rune(arg.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+
+				return &object.String{Value: string(runes)}
+			default:
+				return newError("unsupported argument passed to `reverse`. got=%s", arg.Type())
+			}
+		},
+	},
+	"zip": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("zip", args, 1, -1); err != nil {
+				return err
+			}
+
+			arrays := make([]*object.Array, len(args))
+			n := -1
+			for i := range args {
+				arr, err := arrayArg("zip", args, i)
+				if err != nil {
+					return err
+				}
+
+				arrays[i] = arr
+				if n < 0 || len(arr.Elements) < n {
+					n = len(arr.Elements)
+				}
+			}
+
+			result := make([]object.Object, n)
+			for i := range result {
+				tuple := make([]object.Object, len(arrays))
+				for j, arr := range arrays {
+					tuple[j] = arr.Elements[i]
+				}
+
+				result[i] = &object.Array{Elements: tuple}
+			}
+
+			return &object.Array{Elements: result}
+		},
+	},
+	"flatten": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("flatten", args, 1, 2); err != nil {
+				return err
+			}
+
+			arr, err := arrayArg("flatten", args, 0)
+			if err != nil {
+				return err
+			}
+
+			depth := int64(-1)
+			if len(args) == 2 {
+				if depth, err = integerArg("flatten", args, 1); err != nil {
+					return err
+				}
+			}
+
+			return &object.Array{Elements: flatten(arr, depth, nil)}
+		},
+	},
+	"range": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("range", args, 1, 3); err != nil {
+				return err
+			}
+
+			bounds := make([]int64, len(args))
+			for i := range args {
+				n, err := integerArg("range", args, i)
+				if err != nil {
+					return err
+				}
+
+				bounds[i] = n
+			}
+
+			start, end, step := int64(0), bounds[0], int64(1)
+			if len(bounds) > 1 {
+				start, end = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				step = bounds[2]
+			}
+
+			if step == 0 {
+				return newError("`range` step must not be zero")
+			}
+
+			// The distances are taken as unsigned so that they cannot
+			// overflow, even from math.MinInt64 to math.MaxInt64.
+			var count uint64
+			switch {
+			case step > 0 && start < end:
+				count = (uint64(end-start)-1)/uint64(step) + 1
+			case step < 0 && start > end:
+				count = (uint64(start-end)-1)/uint64(-step) + 1
+			}
+
+			if count > uint64(maxSize) {
+				return newError("result of `range` is too long")
+			}
+
+			result := make([]object.Object, count)
+			for k := range result {
+				result[k] = &object.Integer{Value: start + int64(k)*step}
+			}
+
+			return &object.Array{Elements: result}
+		},
+	},
+	"slice": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("slice", args, 2, 3); err != nil {
+				return err
+			}
+
+			arr, err := arrayArg("slice", args, 0)
+			if err != nil {
+				return err
+			}
+
+			n := int64(len(arr.Elements))
+
+			start, err := integerArg("slice", args, 1)
+			if err != nil {
+				return err
+			}
+
+			end := n
+			if len(args) == 3 {
+				if end, err = integerArg("slice", args, 2); err != nil {
+					return err
+				}
+			}
+
+			start, end = sliceBound(start, n), sliceBound(end, n)
+			if end < start {
+				end = start
+			}
+
+			return &object.Array{Elements: arr.Elements[start:end:end]}
+		},
+	},
+	"concat": {
+		Fn: func(args ...object.Object) object.Object {
+			result := []object.Object{}
+			for i := range args {
+				arr, err := arrayArg("concat", args, i)
+				if err != nil {
+					return err
+				}
+
+				result = append(result, arr.Elements...)
+			}
+
+			return &object.Array{Elements: result}
+		},
+	},
+	"unique": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("unique", args, 1, 1); err != nil {
+				return err
+			}
+
+			arr, err := arrayArg("unique", args, 0)
+			if err != nil {
+				return err
+			}
+
+			seen := object.NewHashMap()
+			result := []object.Object{}
+			for _, e := range arr.Elements {
+				if key, ok := object.AsHashable(e); ok {
+					if _, dup := seen.Get(key); dup {
+						continue
+					}
+
+					seen.Set(key, TRUE)
+				} else if containsEqual(result, e) {
+					continue
+				}
+
+				result = append(result, e)
+			}
+
+			return &object.Array{Elements: result}
+		},
+	},
+}
+
+// callback applies fn to args. A Monkey function that declares more
+// parameters than that also gets the optional arguments, as many as it takes.
+func callback(fn object.Object, args []object.Object, optional ...object.Object) object.Object {
+	if f, ok := fn.(*object.Function); ok && len(f.Params) != len(args) {
+		if len(f.Params) < len(args) || len(f.Params) > len(args)+len(optional) {
+			return newError("callback takes %d parameters, but %d to %d are passed",
+				len(f.Params), len(args), len(args)+len(optional))
+		}
+
+		args = append(args, optional[:len(f.Params)-len(args)]...)
+	}
+
+	return applyFunc(fn, args)
+}
+
+func arrayAndFunc(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if err := checkArgCount(name, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+
+	arr, err := arrayArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := funcArg(name, args, 1); err != nil {
+		return nil, nil, err
+	}
+
+	return arr, args[1], nil
+}
+
+func arrayArg(name string, args []object.Object, i int) (*object.Array, *object.Error) {
+	arr, ok := args[i].(*object.Array)
+	if !ok {
+		return nil, newError("argument %d to `%s` must be ARRAY, got %s", i+1, name, args[i].Type())
+	}
+
+	return arr, nil
+}
+
+func funcArg(name string, args []object.Object, i int) *object.Error {
+	switch args[i].(type) {
+	case *object.Function, *object.Builtin:
+		return nil
+	}
+
+	return newError("argument %d to `%s` must be FUNCTION, got %s", i+1, name, args[i].Type())
+}
+
+// compareObjects orders integers and strings by value.
+func compareObjects(a, b object.Object) (bool, *object.Error) {
+	if isNumber(a) && isNumber(b) && (a.Type() == object.T_FLOAT || b.Type() == object.T_FLOAT) {
+		return toFloat(a) < toFloat(b), nil
+	}
+
+	switch a := a.(type) {
+	case *object.Integer:
+		if b, ok := b.(*object.Integer); ok {
+			return a.Value < b.Value, nil
+		}
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return a.Value < b.Value, nil
+		}
+	}
+
+	return false, newError("cannot compare %s with %s, pass a comparator to `sort`", a.Type(), b.Type())
+}
+
+// comparatorLess calls a sort comparator, which returns either whether a
+// goes before b or an integer that is negative when it does.
+func comparatorLess(fn, a, b object.Object) (bool, *object.Error) {
+	switch val := applyFunc(fn, []object.Object{a, b}).(type) {
+	case *object.Error:
+		return false, val
+	case *object.Boolean:
+		return val.Value, nil
+	case *object.Integer:
+		return val.Value < 0, nil
+	default:
+		return false, newError("comparator passed to `sort` must return BOOL or INTEGER, got %s", val.Type())
+	}
+}
+
+// flatten splices nested arrays into one, down to depth levels; a negative
+// depth flattens all of them. Arrays that contain themselves are kept as is.
+func flatten(arr *object.Array, depth int64, seen map[*object.Array]bool) []object.Object {
+	if seen == nil {
+		seen = make(map[*object.Array]bool)
+	}
+	seen[arr] = true
+	defer delete(seen, arr)
+
+	result := []object.Object{}
+	for _, e := range arr.Elements {
+		if nested, ok := e.(*object.Array); ok && depth != 0 && !seen[nested] {
+			result = append(result, flatten(nested, depth-1, seen)...)
+			continue
+		}
+
+		result = append(result, e)
+	}
+
+	return result
+}
+
+// sliceBound turns a possibly negative index into one within [0, n].
+func sliceBound(i, n int64) int64 {
+	if i < 0 {
+		i += n
+	}
+
+	return clamp(i, 0, n)
+}
+
+func containsEqual(elements []object.Object, obj object.Object) bool {
+	for _, e := range elements {
+		if object.Equal(e, obj) {
+			return true
+		}
+	}
+
+	return false
+}
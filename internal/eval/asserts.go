@@ -0,0 +1,170 @@
+package eval
+
+import (
+	"monkey/internal/object"
+	"strings"
+)
+
+func init() {
+	for name, builtin := range assertBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// The assertions fail by returning an error that starts with "assertion
+// failed", followed by the optional message passed to them.
+var assertBuiltins = map[string]*object.Builtin{
+	"assert": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("assert", args, 1, 2); err != nil {
+				return err
+			}
+
+			failed, err := assertionFailed("assert", args, 1)
+			if err != nil {
+				return err
+			}
+
+			if !isTrue(args[0]) {
+				return newError("%s", failed)
+			}
+
+			return NULL
+		},
+	},
+	"assert_eq": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("assert_eq", args, 2, 3); err != nil {
+				return err
+			}
+
+			failed, err := assertionFailed("assert_eq", args, 2)
+			if err != nil {
+				return err
+			}
+
+			if !object.Equal(args[0], args[1]) {
+				return newError("%s: %s != %s%s", failed, args[0].Inspect(), args[1].Inspect(), diff(args[0], args[1]))
+			}
+
+			return NULL
+		},
+	},
+	"assert_error": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("assert_error", args, 1, 2); err != nil {
+				return err
+			}
+
+			if err := funcArg("assert_error", args, 0); err != nil {
+				return err
+			}
+			if fn, ok := args[0].(*object.Function); ok && len(fn.Params) != 0 {
+				return newError("argument 1 to `assert_error` must take no parameters, it takes %d", len(fn.Params))
+			}
+
+			want := ""
+			if len(args) == 2 {
+				s, err := stringArg("assert_error", args, 1)
+				if err != nil {
+					return err
+				}
+				want = s
+			}
+
+			result, ok := applyFunc(args[0], nil).(*object.Error)
+			switch {
+			case !ok:
+				return newError("assertion failed: expected an error")
+			case !strings.Contains(result.Message, want):
+				return newError("assertion failed: error %q does not contain %q", result.Message, want)
+			}
+
+			return &object.String{Value: result.Message}
+		},
+	},
+}
+
+// assertionFailed returns the start of the error message of an assertion,
+// which includes the optional message argument i.
+func assertionFailed(name string, args []object.Object, i int) (string, *object.Error) {
+	if len(args) <= i {
+		return "assertion failed", nil
+	}
+
+	msg, err := stringArg(name, args, i)
+	if err != nil {
+		return "", err
+	}
+
+	return "assertion failed: " + msg, nil
+}
+
+// diff shows how two arrays or hash maps differ, line by line, or returns
+// the empty string for other values, whose inspection is short enough to
+// compare by eye.
+func diff(got, want object.Object) string {
+	if !isCollection(got) && !isCollection(want) {
+		return ""
+	}
+
+	a := strings.Split(object.InspectIndented(got), "\n")
+	b := strings.Split(object.InspectIndented(want), "\n")
+
+	var out strings.Builder
+	out.WriteString("\n--- got\n+++ want\n")
+	for _, line := range diffLines(a, b) {
+		out.WriteString(line + "\n")
+	}
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+func isCollection(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Array, *object.HashMap:
+		return true
+	}
+
+	return false
+}
+
+// diffLines returns the lines of a and b prefixed with "  " if they are in
+// both, "- " if only in a and "+ " if only in b, using a longest common
+// subsequence of the lines.
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of a longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+
+	return lines
+}
@@ -3,8 +3,21 @@ package eval
 import (
 	"fmt"
 	"monkey/internal/object"
+	"sort"
+	"unicode/utf8"
 )
 
+// BuiltinNames returns the names of all builtin functions in sorted order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
@@ -14,9 +27,11 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.HashMap:
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
 				return newError("argument to `len` not supported, got %s", arg.Type().String())
 			}
@@ -67,9 +82,7 @@ var builtins = map[string]*object.Builtin{
 			switch arg := args[0].(type) {
 			case *object.Array:
 				if len(arg.Elements) > 0 {
-					newArray := make([]object.Object, len(arg.Elements)-1, len(arg.Elements)-1)
-					copy(newArray, arg.Elements[1:])
-					return &object.Array{Elements: newArray}
+					return &object.Array{Elements: arg.Elements[1:]}
 				}
 
 				return NULL
@@ -86,21 +99,27 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.Array:
-				arrLen := len(arg.Elements)
-				argsLen := len(args) - 1
-				newArrayLen := arrLen + argsLen
-				newArray := make([]object.Object, newArrayLen, newArrayLen)
-				copy(newArray, arg.Elements)
-				for i := arrLen; i < newArrayLen; i++ {
-					newArray[i] = args[i-arrLen+1]
-				}
-
-				return &object.Array{Elements: newArray}
+				return arg.Push(args[1:]...)
 			default:
 				return NULL
 			}
 		},
 	},
+	"push_mut": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError("wrong number of arguments passed to `push_mut`. got=%d, expected >= %d", len(args), 2)
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				*arg = *arg.Push(args[1:]...)
+				return arg
+			default:
+				return newError("unsupported argument passed to `push_mut`. got=%s", args[0].Type().String())
+			}
+		},
+	},
 	"set": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 3 {
@@ -109,30 +128,44 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.HashMap:
-				pairs := arg.Pairs
-				key, ok := args[1].(object.Hashable)
+				key, ok := object.AsHashable(args[1])
 				if !ok {
 					return newError("object unusable as hash: %s", args[1].Type().String())
 				}
 
-				hashKey := key.HashKey()
-				pairs[hashKey] = object.HashPair{
-					Key:   args[1],
-					Value: args[2],
-				}
-				return &object.HashMap{Pairs: pairs}
+				return arg.With(key, args[2])
 			default:
 				return NULL
 			}
 		},
 	},
+	"set_mut": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments passed to `set_mut`. got=%d, expected=%d", len(args), 3)
+			}
+
+			switch arg := args[0].(type) {
+			case *object.HashMap:
+				key, ok := object.AsHashable(args[1])
+				if !ok {
+					return newError("object unusable as hash: %s", args[1].Type().String())
+				}
+
+				arg.Set(key, args[2])
+				return arg
+			default:
+				return newError("unsupported argument passed to `set_mut`. got=%s", args[0].Type().String())
+			}
+		},
+	},
 	"print": {
 		Fn: func(args ...object.Object) object.Object {
 			printable := ""
 			for i := 0; i < len(args); i++ {
 				printable += args[i].Inspect() + " "
 			}
-			fmt.Println(printable)
+			fmt.Fprintln(output, printable)
 
 			return NULL
 		},
@@ -140,7 +173,7 @@ var builtins = map[string]*object.Builtin{
 	"puts": {
 		Fn: func(args ...object.Object) object.Object {
 			for i := 0; i < len(args); i++ {
-				fmt.Println(args[i].Inspect())
+				fmt.Fprintln(output, args[i].Inspect())
 			}
 
 			return NULL
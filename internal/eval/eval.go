@@ -7,6 +7,14 @@ import (
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if tracer != nil {
+		return traceNode(node, env, evalNode)
+	}
+
+	return evalNode(node, env)
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -36,7 +44,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Params
 		body := node.Body
-		return &object.Function{Params: params, Body: body, Env: env}
+		return &object.Function{Name: node.Name, Params: params, Body: body, Locals: node.Locals, Env: env}
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isErr(function) {
@@ -74,17 +82,23 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 
 		return evalIndexExpr(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpr(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return evalIfExpression(node, env, Eval)
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isErr(val) {
 			return val
 		}
 
-		env.Set(node.Name.Value, val)
+		if node.Name.Scope == ast.Local {
+			env.SetAt(node.Name.Slot, node.Name.Value, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := evalTail(node.ReturnValue, env)
 		if isErr(val) {
 			return val
 		}
@@ -94,21 +108,63 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalID(node, env)
 	case *ast.HashMapLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.BadStatement:
+		return newError("syntax error at %s", node.Token.Pos)
+	case *ast.BadExpression:
+		return newError("syntax error at %s", node.Token.Pos)
 	}
 
 	return nil
 }
 
+// evalTail evaluates a node in tail position. A call found there is not
+// applied but returned as an *object.TailCall, which applyFunc runs in its
+// own loop instead of nesting another Eval frame.
+func evalTail(node ast.Node, env *object.Environment) object.Object {
+	if tracer != nil {
+		return traceNode(node, env, evalTailNode)
+	}
+
+	return evalTailNode(node, env)
+}
+
+func evalTailNode(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalTailBlockStmt(node, env)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env, evalTail)
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isErr(function) {
+			return function
+		}
+
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isErr(args[0]) {
+			return args[0]
+		}
+
+		return &object.TailCall{Fn: function, Args: args}
+	}
+
+	return evalNode(node, env)
+}
+
 func evalInfixExpression(op string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.T_INTEGER && right.Type() == object.T_INTEGER:
 		return evalIntegerInfixExpression(op, left, right)
 	case left.Type() == object.T_STRING && right.Type() == object.T_STRING:
 		return evalStringInfixExpression(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(op, toFloat(left), toFloat(right))
 	case op == "==":
-		return boolToObj(left == right)
+		return boolToObj(object.Equal(left, right))
 	case op == "!=":
-		return boolToObj(left != right)
+		return boolToObj(!object.Equal(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
 	}
@@ -117,20 +173,20 @@ func evalInfixExpression(op string, left, right object.Object) object.Object {
 }
 
 func evalStringInfixExpression(op string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
 	switch op {
 	case "+":
-		leftVal := left.(*object.String).Value
-		rightVal := right.(*object.String).Value
-
 		return &object.String{Value: leftVal + rightVal}
 	case "==":
-		leftVal := left.(*object.String).Value
-		rightVal := right.(*object.String).Value
-		return &object.Boolean{Value: leftVal == rightVal}
+		return boolToObj(leftVal == rightVal)
 	case "!=":
-		leftVal := left.(*object.String).Value
-		rightVal := right.(*object.String).Value
-		return &object.Boolean{Value: leftVal != rightVal}
+		return boolToObj(leftVal != rightVal)
+	case "<":
+		return boolToObj(leftVal < rightVal)
+	case ">":
+		return boolToObj(leftVal > rightVal)
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), op, right.Type())
@@ -149,6 +205,9 @@ func evalIntegerInfixExpression(op string, left, right object.Object) object.Obj
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return boolToObj(leftVal < rightVal)
@@ -167,6 +226,43 @@ func evalIntegerInfixExpression(op string, left, right object.Object) object.Obj
 	return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 }
 
+// evalFloatInfixExpression handles arithmetic where at least one operand is
+// a float; integer operands are converted first.
+func evalFloatInfixExpression(op string, leftVal, rightVal float64) object.Object {
+	switch op {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return boolToObj(leftVal < rightVal)
+	case ">":
+		return boolToObj(leftVal > rightVal)
+	case "==":
+		return boolToObj(leftVal == rightVal)
+	case "!=":
+		return boolToObj(leftVal != rightVal)
+	}
+
+	return newError("unknown operator: %s %s %s", object.T_FLOAT, op, object.T_FLOAT)
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.T_INTEGER || obj.Type() == object.T_FLOAT
+}
+
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+
+	return obj.(*object.Float).Value
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
@@ -179,6 +275,10 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 }
 
 func evalMinusPrefixOpExpression(right object.Object) object.Object {
+	if float, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -float.Value}
+	}
+
 	if right.Type() != object.T_INTEGER {
 		return newError("unknown operator: -%s", right.Type())
 	}
@@ -204,26 +304,37 @@ func evalStatements(stmts []ast.Statement, env *object.Environment) object.Objec
 	var result object.Object
 
 	for _, stmt := range stmts {
+		if err := statementHook(stmt, env); err != nil {
+			return err
+		}
+
 		result = Eval(stmt, env)
 
 		if ret, ok := result.(*object.ReturnValue); ok {
-			return ret.Value
+			return resolveTailCall(ret.Value)
 		}
 	}
 
 	return result
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment, evalBranch func(ast.Node, *object.Environment) object.Object) object.Object {
 	cond := Eval(ie.Condition, env)
 	if isErr(cond) {
 		return cond
 	}
 
-	if isTrue(cond) {
-		return Eval(ie.Consequence, env)
-	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+	branch := ie.Consequence
+	if !isTrue(cond) {
+		branch = ie.Alternative
+	}
+	if branch == nil {
+		return NULL
+	}
+
+	// A branch that is empty or ends in a let has no value of its own.
+	if result := evalBranch(branch, env); result != nil {
+		return result
 	}
 
 	return NULL
@@ -233,13 +344,17 @@ func evalProgram(p *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range p.Statements {
+		if err := statementHook(stmt, env); err != nil {
+			return err
+		}
+
 		result = Eval(stmt, env)
 
 		switch result := result.(type) {
 		case *object.Error:
 			return result
 		case *object.ReturnValue:
-			return result.Value
+			return resolveTailCall(result.Value)
 		}
 	}
 
@@ -250,6 +365,10 @@ func evalBlockStmt(b *ast.BlockStatement, env *object.Environment) object.Object
 	var result object.Object
 
 	for _, stmt := range b.Statements {
+		if err := statementHook(stmt, env); err != nil {
+			return err
+		}
+
 		result = Eval(stmt, env)
 
 		if result != nil {
@@ -263,11 +382,47 @@ func evalBlockStmt(b *ast.BlockStatement, env *object.Environment) object.Object
 	return result
 }
 
+func evalTailBlockStmt(b *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	last := len(b.Statements) - 1
+	for i, stmt := range b.Statements {
+		if err := statementHook(stmt, env); err != nil {
+			return err
+		}
+
+		if i == last {
+			return evalTail(stmt, env)
+		}
+
+		result = Eval(stmt, env)
+
+		if result != nil {
+			if rt := result.Type(); rt == object.T_RETURN_VALUE || rt == object.T_ERROR {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
 func newError(format string, args ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, args...)}
 }
 
 func evalID(node *ast.ID, env *object.Environment) object.Object {
+	switch node.Scope {
+	case ast.Local:
+		if val, ok := env.GetAt(node.Depth, node.Slot); ok {
+			return val
+		}
+
+		return newError("identifier not found: " + node.Value)
+	case ast.Builtin:
+		return builtins[node.Value]
+	}
+
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
@@ -298,6 +453,8 @@ func evalIndexExpr(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.T_ARRAY && index.Type() == object.T_INTEGER:
 		return evalArrayIndexExpr(left, index)
+	case left.Type() == object.T_STRING && index.Type() == object.T_INTEGER:
+		return evalStringIndexExpr(left, index)
 	case left.Type() == object.T_HASHMAP:
 		return evalHashMapIndexExpr(left, index)
 	default:
@@ -305,27 +462,154 @@ func evalIndexExpr(left, index object.Object) object.Object {
 	}
 }
 
+// Negative indexes count from the end, so -1 is the last element.
 func evalArrayIndexExpr(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	n := int64(len(arrayObject.Elements))
+
+	if idx < 0 {
+		idx += n
+	}
 
-	if idx < 0 || idx > max {
+	if idx < 0 || idx >= n {
 		return NULL
 	}
 
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpr returns the rune at index as a string of its own.
+func evalStringIndexExpr(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	n := int64(len(runes))
+
+	if idx < 0 {
+		idx += n
+	}
+
+	if idx < 0 || idx >= n {
+		return NULL
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+// evalSliceExpr slices arrays and strings the way Python does: bounds
+// default to the whole sequence in the direction of the step, negative
+// bounds count from the end and out of range bounds are clamped.
+func evalSliceExpr(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isErr(left) {
+		return left
+	}
+
+	var bounds [3]*int64
+	for i, expr := range []ast.Expression{node.Start, node.End, node.Step} {
+		if expr == nil {
+			continue
+		}
+
+		val := Eval(expr, env)
+		if isErr(val) {
+			return val
+		}
+
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newError("slice bounds must be INTEGER, got %s", val.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+
+	step := int64(1)
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+
+	if step == 0 {
+		return newError("slice step must not be zero")
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		n := int64(len(left.Elements))
+		start, end := sliceRange(bounds[0], bounds[1], step, n)
+		if step == 1 {
+			return &object.Array{Elements: left.Elements[start:end:end]}
+		}
+
+		elements := []object.Object{}
+		for i := start; step > 0 && i < end || step < 0 && i > end; i = sliceNext(i, end, step) {
+			elements = append(elements, left.Elements[i])
+		}
+
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		start, end := sliceRange(bounds[0], bounds[1], step, int64(len(runes)))
+		if step == 1 {
+			return &object.String{Value: string(runes[start:end])}
+		}
+
+		result := []rune{}
+		for i := start; step > 0 && i < end || step < 0 && i > end; i = sliceNext(i, end, step) {
+			result = append(result, runes[i])
+		}
+
+		return &object.String{Value: string(result)}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// sliceRange resolves the bounds of a slice of a sequence of length n. With a
+// positive step the result satisfies 0 <= start <= end <= n, with a negative
+// one n > start >= end >= -1.
+func sliceRange(start, end *int64, step, n int64) (int64, int64) {
+	resolve := func(bound *int64, def, lo, hi int64) int64 {
+		if bound == nil {
+			return def
+		}
+
+		i := *bound
+		if i < 0 {
+			i += n
+		}
+
+		return clamp(i, lo, hi)
+	}
+
+	if step > 0 {
+		s := resolve(start, 0, 0, n)
+		return s, max(s, resolve(end, n, 0, n))
+	}
+
+	s := resolve(start, n-1, -1, n-1)
+	return s, min(s, resolve(end, -1, -1, n-1))
+}
+
+// sliceNext advances the index i of a slice by step, clamping it to end once
+// it would pass it. Comparing against the remaining distance rather than
+// adding first keeps a huge step from overflowing.
+func sliceNext(i, end, step int64) int64 {
+	if step > 0 && step >= end-i || step < 0 && step <= end-i {
+		return end
+	}
+
+	return i + step
+}
+
 func evalHashMapIndexExpr(hashMap, index object.Object) object.Object {
 	hm := hashMap.(*object.HashMap)
 
-	k, ok := index.(object.Hashable)
+	k, ok := object.AsHashable(index)
 	if !ok {
 		return newError("unusable as hash keys: %s", index.Type())
 	}
 
-	pair, ok := hm.Pairs[k.HashKey()]
+	pair, ok := hm.Get(k)
 	if !ok {
 		return NULL
 	}
@@ -334,27 +618,26 @@ func evalHashMapIndexExpr(hashMap, index object.Object) object.Object {
 }
 
 func evalHashLiteral(node *ast.HashMapLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hashMap := object.NewHashMap()
 
-	for kNode, vNode := range node.Pairs {
-		key := Eval(kNode, env)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isErr(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
 			return newError("object unusable as hash: %s", key.Type().String())
 		}
 
-		value := Eval(vNode, env)
+		value := Eval(pair.Value, env)
 		if isErr(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hashMap.Set(hashKey, value)
 	}
 
-	return &object.HashMap{Pairs: pairs}
+	return hashMap
 }
@@ -1,10 +1,16 @@
 package eval_test
 
 import (
+	"errors"
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
 	"monkey/internal/eval"
 	"monkey/internal/lexer"
 	"monkey/internal/object"
 	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"runtime/debug"
 	"testing"
 )
 
@@ -57,6 +63,16 @@ func TestEval(t *testing.T) {
 			{"(1 < 2) == false", false},
 			{"(1 > 2) == true", false},
 			{"(1 > 2) == false", true},
+			{"[1, 2] == [1, 2]", true},
+			{"[1, 2] != [1, 2]", false},
+			{"[1, 2] == [2, 1]", false},
+			{"[1, [2, 3]] == [1, [2, 3]]", true},
+			{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+			{`{"a": 1} == {"a": 2}`, false},
+			{"[1] == 1", false},
+			{`"1" == 1`, false},
+			{"fn(x) { x } == fn(x) { x }", false},
+			{"let f = fn(x) { x }; f == f", true},
 		}
 
 		for _, tt := range tests {
@@ -164,6 +180,71 @@ func TestEval(t *testing.T) {
 			testIntegerObject(t, testEval(tt.input), tt.expected)
 		}
 	})
+	t.Run("test tail calls", func(t *testing.T) {
+		// Without tail-call elimination these inputs nest hundreds of
+		// thousands of Go frames and overflow the reduced stack limit.
+		defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+
+		type tailCallTest struct {
+			input    string
+			expected int64
+		}
+
+		tests := []tailCallTest{
+			{
+				`let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } };
+				count(200000, 0);`,
+				200000,
+			},
+			{
+				`let count = fn(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); };
+				count(200000, 0);`,
+				200000,
+			},
+			{
+				`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+				let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+				if (even(200001)) { 1 } else { 0 };`,
+				0,
+			},
+			{
+				`let iter = fn(arr, acc) { if (len(arr) == 0) { acc } else { iter(rest(arr), acc + first(arr)) } };
+				iter([1, 2, 3, 4, 5], 0);`,
+				15,
+			},
+			{
+				`let count = fn(n) { if (n == 0) { 0 } else { return count(n - 1); } };
+				return count(200000);`,
+				0,
+			},
+		}
+
+		for _, tt := range tests {
+			testIntegerObject(t, testEval(tt.input), tt.expected)
+		}
+	})
+	t.Run("test resolved programs", func(t *testing.T) {
+		type resolvedTest struct {
+			input    string
+			expected int64
+		}
+
+		tests := []resolvedTest{
+			{"let a = 5; let b = a * 2; b;", 10},
+			{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+			{"let newAdder = fn(x) { fn(y) { x + y }; }; let addTwo = newAdder(2); addTwo(2);", 4},
+			{"let f = fn(x) { if (x > 0) { let y = x * 2; } y }; f(3);", 6},
+			{"let x = 1; let f = fn(x) { let x = x + 10; x }; f(5) + x;", 16},
+			{"let f = fn() { g() }; let g = fn() { 7 }; f();", 7},
+			{"let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(5);", 120},
+			{"let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(10000);", 0},
+			{`let f = fn(arr) { len(arr) + first(arr) }; f([4, 5]);`, 6},
+		}
+
+		for _, tt := range tests {
+			testIntegerObject(t, testEvalResolved(t, tt.input), tt.expected)
+		}
+	})
 	t.Run("test closures", func(t *testing.T) {
 		input := `
 		let newAdder = fn(x) {
@@ -224,6 +305,26 @@ func TestEval(t *testing.T) {
 				"foobar",
 				"identifier not found: foobar",
 			},
+			{
+				`{[1, fn(x) { x }]: 1}`,
+				"object unusable as hash: ARRAY",
+			},
+			{
+				"1 / 0",
+				"division by zero",
+			},
+			{
+				"let f = fn(a, b) { a }; f(1)",
+				"wrong number of arguments passed to `f`. got=1, expected=2",
+			},
+			{
+				"fn(a) { a }(1, 2)",
+				"wrong number of arguments passed to function. got=2, expected=1",
+			},
+			{
+				"let f = fn() {}; f()()",
+				"not a function: NULL",
+			},
 		}
 
 		for _, tt := range tests {
@@ -345,6 +446,14 @@ func TestEval(t *testing.T) {
 			},
 			{
 				"[1, 2, 3][-1]",
+				3,
+			},
+			{
+				"[1, 2, 3][-3]",
+				1,
+			},
+			{
+				"[1, 2, 3][-4]",
 				nil,
 			},
 		}
@@ -376,26 +485,36 @@ func TestEval(t *testing.T) {
 			t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 		}
 
-		expected := map[object.HashKey]int64{
-			(&object.String{Value: "one"}).HashKey():   1,
-			(&object.String{Value: "two"}).HashKey():   2,
-			(&object.String{Value: "three"}).HashKey(): 3,
-			(&object.Integer{Value: 4}).HashKey():      4,
-			eval.TRUE.HashKey():                        5,
-			eval.FALSE.HashKey():                       6,
+		type expectedPair struct {
+			key   object.Hashable
+			value int64
+		}
+
+		expected := []expectedPair{
+			{&object.String{Value: "one"}, 1},
+			{&object.String{Value: "two"}, 2},
+			{&object.String{Value: "three"}, 3},
+			{&object.Integer{Value: 4}, 4},
+			{eval.TRUE, 5},
+			{eval.FALSE, 6},
 		}
 
-		if len(result.Pairs) != len(expected) {
-			t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+		if result.Len() != len(expected) {
+			t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 		}
 
-		for expectedKey, expectedValue := range expected {
-			pair, ok := result.Pairs[expectedKey]
+		for i, expected := range expected {
+			pair, ok := result.Get(expected.key)
 			if !ok {
 				t.Errorf("no pair for given key in Pairs")
+				continue
 			}
 
-			testIntegerObject(t, pair.Value, expectedValue)
+			testIntegerObject(t, pair.Value, expected.value)
+
+			if key := result.Pairs()[i].Key; key.Inspect() != expected.key.Inspect() {
+				t.Errorf("pairs are not in insertion order. got=%s at %d, want=%s", key.Inspect(), i, expected.key.Inspect())
+			}
 		}
 	})
 	t.Run("test hash-map index expression", func(t *testing.T) {
@@ -429,6 +548,22 @@ func TestEval(t *testing.T) {
 				`{false: 5}[false]`,
 				5,
 			},
+			{
+				`{[1, "a"]: 5}[[1, "a"]]`,
+				5,
+			},
+			{
+				`{[1, [2, 3]]: 5}[[1, [2, 3]]]`,
+				5,
+			},
+			{
+				`{[1, "a"]: 5}[[1, "b"]]`,
+				nil,
+			},
+			{
+				`{[1]: 5}[1]`,
+				nil,
+			},
 		}
 
 		for _, tt := range tests {
@@ -455,6 +590,7 @@ func TestBuiltinFunctions(t *testing.T) {
 			{`len("")`, 0},
 			{`len("four")`, 4},
 			{`len("hello world")`, 11},
+			{`len("héllo, 世界")`, 9},
 			{`len(1)`, "argument to `len` not supported, got INTEGER"},
 			{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		}
@@ -480,6 +616,363 @@ func TestBuiltinFunctions(t *testing.T) {
 	})
 }
 
+func TestHashMapOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"z": 1, "a": 2, 3: 3, true: 4}`, `{"z": 1, "a": 2, 3: 3, true: 4}`},
+		{`keys({"z": 1, "a": 2, "m": 3})`, `["z", "a", "m"]`},
+		{`values({"z": 1, "a": 2, "m": 3})`, `[1, 2, 3]`},
+		{`set({"z": 1, "a": 2}, "z", 5)`, `{"z": 5, "a": 2}`},
+		{`set({"z": 1, "a": 2}, "b", 5)`, `{"z": 1, "a": 2, "b": 5}`},
+		{`{"a": 1, "b": 2, "a": 3}`, `{"a": 3, "b": 2}`},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 5; i++ {
+			if got := testEval(tt.input).Inspect(); got != tt.expected {
+				t.Fatalf("wrong result for %s. got=%s, want=%s", tt.input, got, tt.expected)
+			}
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a,b,,c", ",")`, `["a", "b", "", "c"]`},
+		{`split("abc", "")`, `["a", "b", "c"]`},
+		{`join(["a", "b", "c"], "-")`, `"a-b-c"`},
+		{`join([], "-")`, `""`},
+		{`join(["a", 1], "-")`, "ERROR: `join` expects an array of strings, got INTEGER at index 1"},
+		{`trim("  hi 
+")`, `"hi"`},
+		{`trim("xxhixx", "x")`, `"hi"`},
+		{`upper("héllo")`, `"HÉLLO"`},
+		{`lower("HeLLo")`, `"hello"`},
+		{`contains("monkey", "key")`, `true`},
+		{`contains("monkey", "donkey")`, `false`},
+		{`index_of("héllo", "l")`, `2`},
+		{`index_of("hello", "z")`, `-1`},
+		{`replace("a-b-c", "-", "+")`, `"a+b+c"`},
+		{`replace("a-b-c", "-", "+", 1)`, `"a+b-c"`},
+		{`starts_with("monkey", "mon")`, `true`},
+		{`ends_with("monkey", "mon")`, `false`},
+		{`substr("héllo", 1, 3)`, `"éll"`},
+		{`substr("hello", 2)`, `"llo"`},
+		{`substr("hello", -3, 2)`, `"ll"`},
+		{`substr("hello", 3, 10)`, `"lo"`},
+		{`chars("héj")`, `["h", "é", "j"]`},
+		{`repeat("ab", 3)`, `"ababab"`},
+		{`repeat("ab", -1)`, "ERROR: negative count passed to `repeat`: -1"},
+		{`repeat("ab", 9223372036854775807)`, "ERROR: result of `repeat` is too long"},
+		{`repeat("a", 1099511627776)`, "ERROR: result of `repeat` is too long"},
+		{`pad_left("7", 3, "0")`, `"007"`},
+		{`pad_right("ab", 5)`, `"ab   "`},
+		{`pad_left("abc", 2)`, `"abc"`},
+		{`pad_right("a", 4, "xy")`, `"axyx"`},
+		{`pad_left("a", 9223372036854775807, "xy")`, "ERROR: result of `pad_left` is too long"},
+		{`pad_right("a", 1099511627776)`, "ERROR: result of `pad_right` is too long"},
+		{`format("%s is %d years old", "Bob", 42)`, `"Bob is 42 years old"`},
+		{`format("%5.1s|%-4d|%03d|%x|%q|%t|%v|%%", "xyz", 7, 5, 255, "hi", true, [1, "a"])`, `"    x|7   |005|ff|"hi"|true|[1, "a"]|%"`},
+		{`format("%d", "a")`, "ERROR: %d in `format` expects INTEGER, got STRING"},
+		{`format("%d %d", 1)`, "ERROR: missing argument for %d in `format`"},
+		{`format("%d", 1, 2)`, "ERROR: too many arguments to `format`. got=2, used=1"},
+		{`format("%z", 1)`, "ERROR: unknown verb %z in `format`"},
+		{`upper(1)`, "ERROR: argument 1 to `upper` must be STRING, got INTEGER"},
+		{`split("a")`, "ERROR: wrong number of arguments passed to `split`. got=1, expected=2"},
+		{`"apple" < "banana"`, `true`},
+		{`"apple" > "banana"`, `false`},
+		{`!("a" == "a")`, `false`},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestArrayBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, `[2, 4, 6]`},
+		{`map([1, 2, 3], fn(x, i) { x * i })`, `[0, 2, 6]`},
+		{`map(["a", "bb"], len)`, `[1, 2]`},
+		{`map([1, 2], fn(x) { if (x > 1) { let y = x } })`, `[null, null]`},
+		{`map([1], fn(a, b, c) { a })`, "ERROR: callback takes 3 parameters, but 1 to 2 are passed"},
+		{`map([1, 2], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOL"},
+		{`map(1, len)`, "ERROR: argument 1 to `map` must be ARRAY, got INTEGER"},
+		{`map([1], 1)`, "ERROR: argument 2 to `map` must be FUNCTION, got INTEGER"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, `[3, 4]`},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, `10`},
+		{`reduce([1, 2, 3], fn(acc, x) { push(acc, x * x) }, [])`, `[1, 4, 9]`},
+		{`reduce([], fn(acc, x) { acc + x })`, "ERROR: `reduce` of an empty array with no initial value"},
+		{`let total = {"n": 0}; each([1, 2, 3], fn(x) { set_mut(total, "n", total["n"] + x) }); total["n"]`, `6`},
+		{`find([1, 2, 3, 4], fn(x) { x > 2 })`, `3`},
+		{`find([1, 2], fn(x) { x > 2 })`, `null`},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, `true`},
+		{`any([], fn(x) { true })`, `false`},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, `true`},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, `false`},
+		{`sort([3, 1, 2])`, `[1, 2, 3]`},
+		{`sort(["b", "c", "a"])`, `["a", "b", "c"]`},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, `[3, 2, 1]`},
+		{`sort([[2, "b"], [1, "a"], [2, "a"]], fn(a, b) { a[0] - b[0] })`, `[[1, "a"], [2, "b"], [2, "a"]]`},
+		{`sort([1, "a"])`, "ERROR: cannot compare STRING with INTEGER, pass a comparator to `sort`"},
+		{`let a = [3, 1]; sort(a); a`, `[3, 1]`},
+		{`reverse([1, 2, 3])`, `[3, 2, 1]`},
+		{`reverse("héllo")`, `"olléh"`},
+		{`zip([1, 2, 3], ["a", "b"])`, `[[1, "a"], [2, "b"]]`},
+		{`flatten([1, [2, [3, [4]]], 5])`, `[1, 2, 3, 4, 5]`},
+		{`flatten([1, [2, [3, [4]]]], 1)`, `[1, 2, [3, [4]]]`},
+		{`range(4)`, `[0, 1, 2, 3]`},
+		{`range(2, 5)`, `[2, 3, 4]`},
+		{`range(10, 0, -3)`, `[10, 7, 4, 1]`},
+		{`range(0, 5, 0)`, "ERROR: `range` step must not be zero"},
+		{`range(9223372036854775806, 9223372036854775807, 2)`, `[9223372036854775806]`},
+		{`range(-9223372036854775807, 9223372036854775807, 9223372036854775807)`, `[-9223372036854775807, 0]`},
+		{`range(1099511627776)`, "ERROR: result of `range` is too long"},
+		{`range(0, -1099511627776, -1)`, "ERROR: result of `range` is too long"},
+		{`slice([1, 2, 3, 4], 1, 3)`, `[2, 3]`},
+		{`slice([1, 2, 3, 4], -2)`, `[3, 4]`},
+		{`slice([1, 2, 3, 4], 3, 1)`, `[]`},
+		{`let a = [1, 2, 3]; let s = slice(a, 0, 2); push(s, 9); a`, `[1, 2, 3]`},
+		{`concat([1], [], [2, 3])`, `[1, 2, 3]`},
+		{`unique([1, 2, 1, [1], [1], "a", "a", fn(x) { x }])`, `[1, 2, [1], "a", fn(x) {
+{ x }
+}]`},
+		{`rest([1, 2, 3])`, `[2, 3]`},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4, 5][1:3]`, `[2, 3]`},
+		{`[1, 2, 3, 4, 5][:2]`, `[1, 2]`},
+		{`[1, 2, 3, 4, 5][3:]`, `[4, 5]`},
+		{`[1, 2, 3, 4, 5][:]`, `[1, 2, 3, 4, 5]`},
+		{`[1, 2, 3, 4, 5][-2:]`, `[4, 5]`},
+		{`[1, 2, 3, 4, 5][:-2]`, `[1, 2, 3]`},
+		{`[1, 2, 3, 4, 5][::2]`, `[1, 3, 5]`},
+		{`[1, 2, 3, 4, 5][::-1]`, `[5, 4, 3, 2, 1]`},
+		{`[1, 2, 3, 4, 5][3:0:-1]`, `[4, 3, 2]`},
+		{`[1, 2, 3, 4, 5][-1:-4:-2]`, `[5, 3]`},
+		{`[1, 2, 3, 4, 5][10:20]`, `[]`},
+		{`[1, 2, 3, 4, 5][3:1]`, `[]`},
+		{`[1, 2, 3, 4, 5][-10:2]`, `[1, 2]`},
+		{`[][::-1]`, `[]`},
+		{`let a = [1, 2, 3]; let b = a[:2]; push(b, 9); a`, `[1, 2, 3]`},
+		{`[1, 2][::0]`, "ERROR: slice step must not be zero"},
+		{`[1, 2]["a":]`, "ERROR: slice bounds must be INTEGER, got STRING"},
+		{`{}[1:]`, "ERROR: slice operator not supported: HASHMAP"},
+		{`"héllo"[1:4]`, `"éll"`},
+		{`"héllo"[::-1]`, `"olléh"`},
+		{`"héllo"[-3:]`, `"llo"`},
+		{`"héllo"[1]`, `"é"`},
+		{`"héllo"[-1]`, `"o"`},
+		{`"héllo"[5]`, `null`},
+		{`[1, 2, 3][1::9223372036854775807]`, `[2]`},
+		{`[1, 2, 3][1::-9223372036854775807]`, `[2]`},
+		{`"abc"[1::9223372036854775807]`, `"b"`},
+		{`"abc"[1::-9223372036854775807]`, `"b"`},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestHashMapBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`entries({"a": 1, 2: "b"})`, `[["a", 1], [2, "b"]]`},
+		{`entries({})`, `[]`},
+		{`has({"a": 1}, "a")`, `true`},
+		{`has({"a": 1}, "b")`, `false`},
+		{`has({[1, 2]: 1}, [1, 2])`, `true`},
+		{`has({"a": 1}, [])`, `false`},
+		{`has({"a": 1}, fn() {})`, "ERROR: object unusable as hash: FUNCTION"},
+		{`has([1], 1)`, "ERROR: argument 1 to `has` must be HASHMAP, got ARRAY"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, `{"a": 1, "c": 3}`},
+		{`delete({"a": 1}, "z")`, `{"a": 1}`},
+		{`let m = {"a": 1, "b": 2}; let n = delete(m, "a"); [m, n]`, `[{"a": 1, "b": 2}, {"b": 2}]`},
+		{`let m = {"a": 1, "b": 2}; delete_mut(m, "a"); m`, `{"b": 2}`},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4}, {"d": 5})`, `{"a": 1, "b": 3, "c": 4, "d": 5}`},
+		{`let m = {"a": 1}; merge(m, {"b": 2}); m`, `{"a": 1}`},
+		{`merge({}, 1)`, "ERROR: argument 2 to `merge` must be HASHMAP, got INTEGER"},
+		{`len({"a": 1, "b": 2})`, `2`},
+		{`len(delete({"a": 1}, "a"))`, `0`},
+		{`map_from_entries([["a", 1], [[1], 2], ["a", 3]])`, `{"a": 3, [1]: 2}`},
+		{`map_from_entries(entries({"x": 1, "y": 2})) == {"x": 1, "y": 2}`, `true`},
+		{`map_from_entries([["a"]])`, "ERROR: entry 0 passed to `map_from_entries` is not a [key, value] array: [\"a\"]"},
+		{`map_from_entries([[{}, 1]])`, "ERROR: object unusable as hash: HASHMAP"},
+		{`let out = {"s": ""}; each({"a": 1, "b": 2}, fn(k, v) { set_mut(out, "s", out["s"] + k + format("%d", v)) }); out["s"]`, `"a1b2"`},
+		{`each({"a": 1}, 1)`, "ERROR: argument 2 to `each` must be FUNCTION, got INTEGER"},
+		{`each()`, "ERROR: wrong number of arguments passed to `each`. got=0, expected=2"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_parse("{\"b\": [1, 2.5, -3e2], \"a\": {\"x\": null, \"y\": true}, \"c\": \"\\u00e9\\t\"}")`, "{\"b\": [1, 2.5, -300.0], \"a\": {\"x\": null, \"y\": true}, \"c\": \"é\t\"}"},
+		{`json_parse("123456789012345678901234567890")`, `1.2345678901234568e+29`},
+		{`json_parse("{\"a\": 1, \"a\": 2}")`, `{"a": 2}`},
+		{`json_parse("[1, 2")`, "ERROR: invalid JSON: unexpected end of JSON input"},
+		{`json_parse("[1] 2")`, "ERROR: invalid JSON: unexpected data after the top-level value"},
+		{`json_parse("")`, "ERROR: invalid JSON: unexpected end of JSON input"},
+		{`json_stringify({"b": [1, json_parse("null"), true], "a": "<\"x\">\n", 3: false})`, `"{"b":[1,null,true],"a":"<\"x\">\n","3":false}"`},
+		{`json_stringify(json_parse("[1.0, 0.5]"))`, `"[1.0,0.5]"`},
+		{`json_stringify({"a": [1, 2], "b": {}}, 2)`, "\"{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}\""},
+		{`json_stringify([[]], "\t")`, "\"[\n\t[]\n]\""},
+		{`let s = "{\"z\":1,\"y\":[{\"x\":\"w\"}]}"; json_stringify(json_parse(s)) == s`, `true`},
+		{`let shared = [1]; json_stringify([shared, shared])`, `"[[1],[1]]"`},
+		{`json_stringify(fn(x) { x })`, "ERROR: cannot convert FUNCTION to JSON"},
+		{`json_stringify({"f": len})`, "ERROR: cannot convert BUILTIN to JSON"},
+		{`json_stringify({[1]: 1})`, "ERROR: cannot convert ARRAY key to JSON"},
+		{`let a = [1]; push_mut(a, a); json_stringify(a)`, "ERROR: cannot convert cyclic ARRAY to JSON"},
+		{`let m = {}; set_mut(m, "m", m); json_stringify(m)`, "ERROR: cannot convert cyclic HASHMAP to JSON"},
+		{`json_stringify(1, true)`, "ERROR: argument 2 to `json_stringify` must be INTEGER or STRING, got BOOL"},
+		{`json_parse("1.5") + 1`, `2.5`},
+		{`json_parse("0.5") * 4`, `2.0`},
+		{`-json_parse("0.5") < 0`, `true`},
+		{`json_parse("1.0") == 1`, `true`},
+		{`[1] == [json_parse("1.0")]`, `true`},
+		{`{"a": 1} == {"a": json_parse("1.0")}`, `true`},
+		{`[1] != [json_parse("1.5")]`, `true`},
+		{`sort([2, json_parse("1.5"), 1])`, `[1, 1.5, 2]`},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestAssertEq(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`assert_eq([1, {"a": 2}], [1, {"a": 2}])`, ""},
+		{`assert_eq(1, 2)`, "assertion failed: 1 != 2"},
+		{`assert_eq([1], ["1"])`, "assertion failed: [1] != [\"1\"]\n--- got\n+++ want\n  [\n-     1,\n+     \"1\",\n  ]"},
+		{`assert_eq(1)`, "wrong number of arguments passed to `assert_eq`. got=1, expected >= 2"},
+		{`assert_eq(1 + 1, 3, "sum")`, "assertion failed: sum: 2 != 3"},
+		{`assert_eq([1, 2, 3], [1, 3])`, "assertion failed: [1, 2, 3] != [1, 3]\n--- got\n+++ want\n  [\n      1,\n-     2,\n      3,\n  ]"},
+		{`assert_eq({"a": 1}, 1)`, "assertion failed: {\"a\": 1} != 1\n--- got\n+++ want\n- {\n-     \"a\": 1,\n- }\n+ 1"},
+		{`assert(1 < 2)`, ""},
+		{`assert(1 > 2)`, "assertion failed"},
+		{`assert(false, "not true")`, "assertion failed: not true"},
+		{`assert(true, 1)`, "argument 2 to `assert` must be STRING, got INTEGER"},
+		{`assert_error(fn() { 1 })`, "assertion failed: expected an error"},
+		{`assert_error(fn() { 1 / "a" }, "unknown")`, `assertion failed: error "type mismatch: INTEGER / STRING" does not contain "unknown"`},
+		{`assert_error(fn(x) { x })`, "argument 1 to `assert_error` must take no parameters, it takes 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if tt.expected == "" {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestAssertError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`assert_error(fn() { len(1, 2) })`, "wrong number of arguments. got=2, want=1"},
+		{`assert_error(fn() { -true }, "unknown operator")`, "unknown operator: -BOOL"},
+		{`assert_error(fn() { assert(false) })`, "assertion failed"},
+	}
+
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestCallNil(t *testing.T) {
+	errObj, ok := eval.Call(nil).(*object.Error)
+	if !ok {
+		t.Fatalf("calling nil did not return an error")
+	}
+
+	if expected := "not a function: nil"; errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
+
+func TestCollectionAliasing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1]; let b = push(a, 2); let c = push(a, 3); [a, b, c]`, `[[1], [1, 2], [1, 3]]`},
+		{`let a = push([], 1); let b = push(a, 2); let c = push(a, 3); [a, b, c]`, `[[1], [1, 2], [1, 3]]`},
+		{`let a = [1, 2, 3]; let r = rest(a); let b = push(r, 4); [a, r, b]`, `[[1, 2, 3], [2, 3], [2, 3, 4]]`},
+		{`let a = {"x": 1}; let b = set(a, "y", 2); [a, b]`, `[{"x": 1}, {"x": 1, "y": 2}]`},
+		{`let a = {"x": 1}; let b = set(a, "x", 2); [a, b]`, `[{"x": 1}, {"x": 2}]`},
+		{`let a = [1]; let b = a; push_mut(a, 2); [a, b]`, `[[1, 2], [1, 2]]`},
+		{`let a = [1]; let c = push(a, 5); push_mut(a, 6); [a, c]`, `[[1, 6], [1, 5]]`},
+		{`let a = {"x": 1}; let b = a; set_mut(a, "y", 2); [a, b]`, `[{"x": 1, "y": 2}, {"x": 1, "y": 2}]`},
+		{`let a = {"x": 1}; let c = set(a, "z", 3); set_mut(a, "y", 2); [a, c]`, `[{"x": 1, "y": 2}, {"x": 1, "z": 3}]`},
+		{`let a = {"x": 1}; let b = delete(a, "z"); set_mut(b, "y", 2); [a, b]`, `[{"x": 1}, {"x": 1, "y": 2}]`},
+		{`let a = {"x": 1}; let b = delete(a, "z"); delete_mut(b, "x"); [a, b]`, `[{"x": 1}, {}]`},
+		{`let a = {"x": 1}; let b = merge(a); set_mut(b, "y", 2); [a, b]`, `[{"x": 1}, {"x": 1, "y": 2}]`},
+		{`let a = {"x": 1}; let b = merge(a, {}); delete_mut(b, "x"); [a, b]`, `[{"x": 1}, {}]`},
+		{`let a = {}; let b = merge(a, {}); set_mut(b, "y", 2); [a, b]`, `[{}, {"y": 2}]`},
+		{`let k = [1]; let m = {k: "one"}; push_mut(k, 2); [m[[1]], m[[1, 2]]]`, `["one", null]`},
+		{`let a = [1]; push_mut(a, a); a`, `[1, [...]]`},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -489,6 +982,20 @@ func testEval(input string) object.Object {
 	return eval.Eval(program, env)
 }
 
+func testEvalResolved(t *testing.T, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnv()
+
+	diags := resolver.New(eval.BuiltinNames()).Resolve(program)
+	if diagnostic.HasErrors(diags) {
+		t.Fatalf("resolver errors for %q: %v", input, diags)
+	}
+
+	return eval.Eval(program, env)
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -527,3 +1034,109 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 
 	return true
 }
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("obj is not *object.String. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("obj has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+// FuzzEval runs every program that parses, both unresolved and resolved,
+// and checks that it ends in a value or an error object and not a panic.
+func FuzzEval(f *testing.F) {
+	for _, seed := range []string{
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)",
+		`let h = {"a": [1, 2, 3]}; puts(h["a"][1:], len(h), keys(h)); h["b"]`,
+		`map(range(5), fn(x, i) { x * i }); reduce([1, 2], fn(a, b) { a + b }, 0)`,
+		`format("%d %s %v", 1, "a", [true]); repeat("ab", 3); pad_left("7", 3, "0")`,
+		`let a = []; push_mut(a, a); json_stringify({"a": a})`,
+		"1 / 0; -true; [1][5]; {}[fn() {}]; f(); fn(a) { a }()",
+		"let loop = fn(n) { loop(n + 1) }; loop(0)",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+
+		defer eval.SetOutput(eval.SetOutput(io.Discard))
+		defer eval.SetTracer(eval.SetTracer(nil))
+		defer eval.SetMaxSize(eval.SetMaxSize(maxLength))
+
+		evalWithinBudget(program)
+		if !diagnostic.HasErrors(resolver.New(eval.BuiltinNames()).Resolve(program)) {
+			evalWithinBudget(program)
+		}
+	})
+}
+
+func evalWithinBudget(program *ast.Program) {
+	eval.SetTracer(&budget{})
+	defer func() {
+		if r := recover(); r != nil && r != errBudget {
+			panic(r)
+		}
+	}()
+
+	if obj := eval.Eval(program, object.NewEnv()); obj != nil {
+		obj.Inspect()
+	}
+}
+
+// budget is a tracer that stops programs that run too long or build values
+// too large by panicking with errBudget.
+type budget struct {
+	nodes int
+}
+
+var errBudget = errors.New("budget exhausted")
+
+const (
+	maxNodes  = 20000
+	maxLength = 1 << 12
+)
+
+func (b *budget) EnterNode(node ast.Node) {
+	if b.nodes++; b.nodes > maxNodes {
+		panic(errBudget)
+	}
+}
+
+func (b *budget) ExitNode(node ast.Node, result object.Object) {
+	checkLength(result)
+}
+
+func (b *budget) EnterCall(fn object.Object, args []object.Object) {}
+
+func (b *budget) ExitCall(fn object.Object, result object.Object) {
+	checkLength(result)
+}
+
+func checkLength(obj object.Object) {
+	length := 0
+	switch obj := obj.(type) {
+	case *object.String:
+		length = len(obj.Value)
+	case *object.Array:
+		length = len(obj.Elements)
+	case *object.HashMap:
+		length = obj.Len()
+	}
+
+	if length > maxLength {
+		panic(errBudget)
+	}
+}
@@ -29,18 +29,75 @@ func isErr(obj object.Object) bool {
 	return false
 }
 
+// Call calls fn, a function or a builtin, with args and returns the result.
+func Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunc(fn, args)
+}
+
+// applyFunc calls fn with args. The body is evaluated in tail position, and
+// any tail call it hands back is applied by the loop here, so a chain of tail
+// calls reuses this Go frame instead of growing the stack.
 func applyFunc(fn object.Object, args []object.Object) object.Object {
-	switch fn := fn.(type) {
-	case *object.Function:
-		extEnv := extendFunctionEnv(fn, args)
-		eval := Eval(fn.Body, extEnv)
-		return unwrapReturnValue(eval)
-	case *object.Builtin:
-		return fn.Fn(args...)
-	default:
-		return newError("not a function: %s", fn.Type())
+	for {
+		switch f := fn.(type) {
+		case *object.Function:
+			if len(args) != len(f.Params) {
+				return wrongArgCount(f, args)
+			}
+
+			if tracer != nil {
+				tracer.EnterCall(f, args)
+			}
+
+			extEnv := extendFunctionEnv(f, args)
+			if hooks != nil {
+				hooks.Call(f, extEnv)
+			}
+
+			eval := unwrapReturnValue(evalTail(f.Body, extEnv))
+			if eval == nil {
+				// The body is empty or ends in a let.
+				eval = NULL
+			}
+			if hooks != nil {
+				hooks.Return(f, eval)
+			}
+
+			if tracer != nil {
+				tracer.ExitCall(f, eval)
+			}
+
+			tc, ok := eval.(*object.TailCall)
+			if !ok {
+				return eval
+			}
+
+			fn, args = tc.Fn, tc.Args
+		case *object.Builtin:
+			if tracer == nil {
+				return f.Fn(args...)
+			}
+
+			tracer.EnterCall(f, args)
+			result := f.Fn(args...)
+			tracer.ExitCall(f, result)
+			return result
+		case nil:
+			return newError("not a function: nil")
+		default:
+			return newError("not a function: %s", fn.Type())
+		}
 	}
+}
 
+// resolveTailCall runs a tail call that escaped to the top level, e.g. from a
+// return statement outside of any function.
+func resolveTailCall(obj object.Object) object.Object {
+	if tc, ok := obj.(*object.TailCall); ok {
+		return applyFunc(tc.Fn, tc.Args)
+	}
+
+	return obj
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -52,6 +109,15 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	if fn.Locals != nil {
+		env := object.NewSlotEnv(fn.Env, fn.Locals)
+		for i := range fn.Params {
+			env.SetAt(fn.Params[i].Slot, fn.Params[i].Value, args[i])
+		}
+
+		return env
+	}
+
 	env := object.NewEnclosedEnv(fn.Env)
 
 	for i := range fn.Params {
@@ -60,3 +126,71 @@ func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Enviro
 
 	return env
 }
+
+func wrongArgCount(fn *object.Function, args []object.Object) *object.Error {
+	name := "function"
+	if fn.Name != "" {
+		name = "`" + fn.Name + "`"
+	}
+
+	return newError("wrong number of arguments passed to %s. got=%d, expected=%d", name, len(args), len(fn.Params))
+}
+
+// checkArgCount reports an error unless the builtin name got between min and
+// max arguments. A negative max means there is no upper bound.
+func checkArgCount(name string, args []object.Object, min, max int) *object.Error {
+	switch {
+	case min == max && len(args) != min:
+		return newError("wrong number of arguments passed to `%s`. got=%d, expected=%d", name, len(args), min)
+	case len(args) < min:
+		return newError("wrong number of arguments passed to `%s`. got=%d, expected >= %d", name, len(args), min)
+	case max >= 0 && len(args) > max:
+		return newError("wrong number of arguments passed to `%s`. got=%d, expected <= %d", name, len(args), max)
+	}
+
+	return nil
+}
+
+func stringArg(name string, args []object.Object, i int) (string, *object.Error) {
+	str, ok := args[i].(*object.String)
+	if !ok {
+		return "", newError("argument %d to `%s` must be STRING, got %s", i+1, name, args[i].Type())
+	}
+
+	return str.Value, nil
+}
+
+func twoStringArgs(name string, args []object.Object) (string, string, *object.Error) {
+	a, err := stringArg(name, args, 0)
+	if err != nil {
+		return "", "", err
+	}
+
+	b, err := stringArg(name, args, 1)
+	if err != nil {
+		return "", "", err
+	}
+
+	return a, b, nil
+}
+
+// maxSize is the longest string, in bytes, or array, in elements, that a
+// builtin builds from a count it is passed, as repeat and range do.
+var maxSize int64 = 1 << 24
+
+// SetMaxSize limits the strings and arrays builtins build from counts to n
+// bytes or elements and returns the limit it replaces.
+func SetMaxSize(n int64) int64 {
+	old := maxSize
+	maxSize = n
+	return old
+}
+
+func integerArg(name string, args []object.Object, i int) (int64, *object.Error) {
+	integer, ok := args[i].(*object.Integer)
+	if !ok {
+		return 0, newError("argument %d to `%s` must be INTEGER, got %s", i+1, name, args[i].Type())
+	}
+
+	return integer.Value, nil
+}
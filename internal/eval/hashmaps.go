@@ -0,0 +1,199 @@
+package eval
+
+import "monkey/internal/object"
+
+func init() {
+	for name, builtin := range hashMapBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// The hash map builtins return new maps and leave their arguments unchanged,
+// except for the ones ending in _mut. All of them keep the insertion order.
+var hashMapBuiltins = map[string]*object.Builtin{
+	"keys": {
+		Fn: func(args ...object.Object) object.Object {
+			hashMap, err := singleHashMapArg("keys", args)
+			if err != nil {
+				return err
+			}
+
+			pairs := hashMap.Pairs()
+			keys := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				keys[i] = pair.Key
+			}
+
+			return &object.Array{Elements: keys}
+		},
+	},
+	"values": {
+		Fn: func(args ...object.Object) object.Object {
+			hashMap, err := singleHashMapArg("values", args)
+			if err != nil {
+				return err
+			}
+
+			pairs := hashMap.Pairs()
+			values := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				values[i] = pair.Value
+			}
+
+			return &object.Array{Elements: values}
+		},
+	},
+	"entries": {
+		Fn: func(args ...object.Object) object.Object {
+			hashMap, err := singleHashMapArg("entries", args)
+			if err != nil {
+				return err
+			}
+
+			pairs := hashMap.Pairs()
+			entries := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				entries[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+
+			return &object.Array{Elements: entries}
+		},
+	},
+	"has": {
+		Fn: func(args ...object.Object) object.Object {
+			hashMap, key, err := hashMapAndKey("has", args)
+			if err != nil {
+				return err
+			}
+
+			_, ok := hashMap.Get(key)
+			return boolToObj(ok)
+		},
+	},
+	"delete": {
+		Fn: func(args ...object.Object) object.Object {
+			hashMap, key, err := hashMapAndKey("delete", args)
+			if err != nil {
+				return err
+			}
+
+			return hashMap.Without(key)
+		},
+	},
+	"delete_mut": {
+		Fn: func(args ...object.Object) object.Object {
+			hashMap, key, err := hashMapAndKey("delete_mut", args)
+			if err != nil {
+				return err
+			}
+
+			hashMap.Delete(key)
+			return hashMap
+		},
+	},
+	"merge": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("merge", args, 1, -1); err != nil {
+				return err
+			}
+
+			var result *object.HashMap
+			for i := range args {
+				hashMap, err := hashMapArg("merge", args, i)
+				if err != nil {
+					return err
+				}
+
+				if result == nil {
+					result = hashMap.Copy()
+					continue
+				}
+
+				for _, pair := range hashMap.Pairs() {
+					result = result.With(pair.Key.(object.Hashable), pair.Value)
+				}
+			}
+
+			return result
+		},
+	},
+	"map_from_entries": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("map_from_entries", args, 1, 1); err != nil {
+				return err
+			}
+
+			arr, err := arrayArg("map_from_entries", args, 0)
+			if err != nil {
+				return err
+			}
+
+			hashMap := object.NewHashMap()
+			for i, e := range arr.Elements {
+				entry, ok := e.(*object.Array)
+				if !ok || len(entry.Elements) != 2 {
+					return newError("entry %d passed to `map_from_entries` is not a [key, value] array: %s", i, e.Inspect())
+				}
+
+				key, ok := object.AsHashable(entry.Elements[0])
+				if !ok {
+					return newError("object unusable as hash: %s", entry.Elements[0].Type())
+				}
+
+				hashMap.Set(key, entry.Elements[1])
+			}
+
+			return hashMap
+		},
+	},
+}
+
+// eachPair calls fn with the key and the value of every pair of hashMap.
+func eachPair(hashMap *object.HashMap, fn object.Object) object.Object {
+	if err := funcArg("each", []object.Object{hashMap, fn}, 1); err != nil {
+		return err
+	}
+
+	for _, pair := range hashMap.Pairs() {
+		if val := callback(fn, []object.Object{pair.Key}, pair.Value); isErr(val) {
+			return val
+		}
+	}
+
+	return NULL
+}
+
+func hashMapArg(name string, args []object.Object, i int) (*object.HashMap, *object.Error) {
+	hashMap, ok := args[i].(*object.HashMap)
+	if !ok {
+		return nil, newError("argument %d to `%s` must be HASHMAP, got %s", i+1, name, args[i].Type())
+	}
+
+	return hashMap, nil
+}
+
+func singleHashMapArg(name string, args []object.Object) (*object.HashMap, *object.Error) {
+	if err := checkArgCount(name, args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	return hashMapArg(name, args, 0)
+}
+
+func hashMapAndKey(name string, args []object.Object) (*object.HashMap, object.Hashable, *object.Error) {
+	if err := checkArgCount(name, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+
+	hashMap, err := hashMapArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, ok := object.AsHashable(args[1])
+	if !ok {
+		return nil, nil, newError("object unusable as hash: %s", args[1].Type())
+	}
+
+	return hashMap, key, nil
+}
@@ -0,0 +1,107 @@
+package eval
+
+import (
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/object"
+	"os"
+	"sync"
+)
+
+// Hooks let tools such as debuggers follow evaluation. They are called on
+// the goroutine running Eval, which waits for them to return.
+type Hooks interface {
+	// Statement is called before stmt is evaluated in env. Returning an
+	// error stops evaluation, which then results in that error.
+	Statement(stmt ast.Statement, env *object.Environment) error
+
+	// Call is called when fn starts running in env, the environment of the
+	// call, and Return when it has produced result. A tail call returns
+	// from the caller before calling the callee.
+	Call(fn *object.Function, env *object.Environment)
+	Return(fn *object.Function, result object.Object)
+}
+
+var hooks Hooks
+
+// SetHooks installs h, or removes the hooks if h is nil, and returns the
+// hooks it replaces.
+func SetHooks(h Hooks) Hooks {
+	old := hooks
+	hooks = h
+	return old
+}
+
+// output is where print and puts write.
+var output io.Writer = os.Stdout
+
+// SetOutput makes print and puts write to w and returns the writer they
+// used before.
+func SetOutput(w io.Writer) io.Writer {
+	old := output
+	output = w
+	return old
+}
+
+// statementHook runs the Statement hook for stmt, if there is one, and
+// turns the error it returns into an object.
+func statementHook(stmt ast.Statement, env *object.Environment) *object.Error {
+	if hooks == nil {
+		return nil
+	}
+
+	if err := hooks.Statement(stmt, env); err != nil {
+		return newError("%s", err)
+	}
+
+	return nil
+}
+
+// Tracer follows evaluation in more detail than Hooks, for tools such as
+// profilers that observe a program without steering it. Its methods are
+// called in nested pairs on the goroutine running Eval.
+type Tracer interface {
+	// EnterNode and ExitNode surround the evaluation of every node.
+	EnterNode(node ast.Node)
+	ExitNode(node ast.Node, result object.Object)
+
+	// EnterCall and ExitCall surround every call of a function or a
+	// builtin. A tail call exits the caller before entering the callee.
+	EnterCall(fn object.Object, args []object.Object)
+	ExitCall(fn object.Object, result object.Object)
+}
+
+var tracer Tracer
+
+// SetTracer installs t, or removes the tracer if t is nil, and returns the
+// tracer it replaces.
+func SetTracer(t Tracer) Tracer {
+	old := tracer
+	tracer = t
+	return old
+}
+
+func traceNode(node ast.Node, env *object.Environment, eval func(ast.Node, *object.Environment) object.Object) object.Object {
+	tracer.EnterNode(node)
+	result := eval(node, env)
+	tracer.ExitNode(node, result)
+
+	return result
+}
+
+var (
+	builtinNamesOnce sync.Once
+	builtinNames     map[*object.Builtin]string
+)
+
+// BuiltinName returns the name of a builtin function.
+func BuiltinName(fn *object.Builtin) string {
+	builtinNamesOnce.Do(func() {
+		builtinNames = make(map[*object.Builtin]string, len(builtins))
+		for name, b := range builtins {
+			builtinNames[b] = name
+		}
+	})
+
+	return builtinNames[fn]
+}
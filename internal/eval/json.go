@@ -0,0 +1,244 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"monkey/internal/object"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	for name, builtin := range jsonBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+var jsonBuiltins = map[string]*object.Builtin{
+	"json_parse": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("json_parse", args, 1, 1); err != nil {
+				return err
+			}
+
+			s, err := stringArg("json_parse", args, 0)
+			if err != nil {
+				return err
+			}
+
+			return parseJSON(s)
+		},
+	},
+	"json_stringify": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("json_stringify", args, 1, 2); err != nil {
+				return err
+			}
+
+			indent := ""
+			if len(args) == 2 {
+				switch arg := args[1].(type) {
+				case *object.Integer:
+					indent = strings.Repeat(" ", int(clamp(arg.Value, 0, 16)))
+				case *object.String:
+					indent = arg.Value
+				default:
+					return newError("argument 2 to `json_stringify` must be INTEGER or STRING, got %s", arg.Type())
+				}
+			}
+
+			var out bytes.Buffer
+			if err := writeJSON(&out, args[0], nil); err != nil {
+				return err
+			}
+
+			if indent == "" {
+				return &object.String{Value: out.String()}
+			}
+
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, out.Bytes(), "", indent); err != nil {
+				return newError("invalid indent passed to `json_stringify`: %q", indent)
+			}
+
+			return &object.String{Value: indented.String()}
+		},
+	},
+}
+
+// parseJSON decodes s into Monkey objects. Objects become hash maps that
+// keep the keys in document order; numbers become integers when they have
+// no fraction or exponent and fit in 64 bits, and floats otherwise.
+func parseJSON(s string) object.Object {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	val, err := readJSON(dec)
+	if err != nil {
+		return newError("invalid JSON: %s", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return newError("invalid JSON: unexpected data after the top-level value")
+	}
+
+	return val
+}
+
+func readJSON(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, errors.New("unexpected end of JSON input")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			elements := []object.Object{}
+			for dec.More() {
+				e, err := readJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+
+				elements = append(elements, e)
+			}
+
+			_, err := dec.Token()
+			return &object.Array{Elements: elements}, err
+		}
+
+		hashMap := object.NewHashMap()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			val, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			hashMap.Set(&object.String{Value: key.(string)}, val)
+		}
+
+		_, err := dec.Token()
+		return hashMap, err
+	case json.Number:
+		if !strings.ContainsAny(tok.String(), ".eE") {
+			if i, err := strconv.ParseInt(tok.String(), 10, 64); err == nil {
+				return &object.Integer{Value: i}, nil
+			}
+		}
+
+		f, err := strconv.ParseFloat(tok.String(), 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return nil, err
+		}
+
+		return &object.Float{Value: f}, nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case bool:
+		return boolToObj(tok), nil
+	default:
+		return NULL, nil
+	}
+}
+
+// writeJSON encodes obj compactly. Hash map keys are written in insertion
+// order; integer and boolean keys are turned into strings, other keys are
+// rejected. seen holds the arrays and hash maps being written, to report
+// cycles instead of recursing forever.
+func writeJSON(out *bytes.Buffer, obj object.Object, seen map[object.Object]bool) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		out.WriteString("null")
+	case *object.Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return newError("cannot convert %s to JSON", obj.Inspect())
+		}
+
+		out.WriteString(obj.Inspect())
+	case *object.String:
+		writeJSONString(out, obj.Value)
+	case *object.Array:
+		if seen[obj] {
+			return newError("cannot convert cyclic ARRAY to JSON")
+		}
+		seen = markSeen(seen, obj)
+		defer delete(seen, obj)
+
+		out.WriteByte('[')
+		for i, e := range obj.Elements {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+
+			if err := writeJSON(out, e, seen); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+	case *object.HashMap:
+		if seen[obj] {
+			return newError("cannot convert cyclic HASHMAP to JSON")
+		}
+		seen = markSeen(seen, obj)
+		defer delete(seen, obj)
+
+		out.WriteByte('{')
+		for i, pair := range obj.Pairs() {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+
+			switch key := pair.Key.(type) {
+			case *object.String:
+				writeJSONString(out, key.Value)
+			case *object.Integer, *object.Boolean:
+				writeJSONString(out, key.Inspect())
+			default:
+				return newError("cannot convert %s key to JSON", key.Type())
+			}
+
+			out.WriteByte(':')
+			if err := writeJSON(out, pair.Value, seen); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	default:
+		return newError("cannot convert %s to JSON", obj.Type())
+	}
+
+	return nil
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	out.Truncate(out.Len() - 1) // Encode ends with a newline
+}
+
+func markSeen(seen map[object.Object]bool, obj object.Object) map[object.Object]bool {
+	if seen == nil {
+		seen = make(map[object.Object]bool)
+	}
+	seen[obj] = true
+
+	return seen
+}
@@ -0,0 +1,404 @@
+package eval
+
+import (
+	"fmt"
+	"monkey/internal/object"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	for name, builtin := range stringBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// Indexes taken and returned by the string builtins count runes, not bytes.
+var stringBuiltins = map[string]*object.Builtin{
+	"split": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("split", args, 2, 2); err != nil {
+				return err
+			}
+
+			s, sep, err := twoStringArgs("split", args)
+			if err != nil {
+				return err
+			}
+
+			return stringsToArray(strings.Split(s, sep))
+		},
+	},
+	"join": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("join", args, 2, 2); err != nil {
+				return err
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `join` must be ARRAY, got %s", args[0].Type())
+			}
+
+			sep, err := stringArg("join", args, 1)
+			if err != nil {
+				return err
+			}
+
+			parts := make([]string, len(arr.Elements))
+			for i, e := range arr.Elements {
+				str, ok := e.(*object.String)
+				if !ok {
+					return newError("`join` expects an array of strings, got %s at index %d", e.Type(), i)
+				}
+
+				parts[i] = str.Value
+			}
+
+			return &object.String{Value: strings.Join(parts, sep)}
+		},
+	},
+	"trim": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("trim", args, 1, 2); err != nil {
+				return err
+			}
+
+			s, err := stringArg("trim", args, 0)
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 1 {
+				return &object.String{Value: strings.TrimSpace(s)}
+			}
+
+			cutset, err := stringArg("trim", args, 1)
+			if err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.Trim(s, cutset)}
+		},
+	},
+	"upper":       stringMapper("upper", strings.ToUpper),
+	"lower":       stringMapper("lower", strings.ToLower),
+	"contains":    stringPredicate("contains", strings.Contains),
+	"starts_with": stringPredicate("starts_with", strings.HasPrefix),
+	"ends_with":   stringPredicate("ends_with", strings.HasSuffix),
+	"index_of": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("index_of", args, 2, 2); err != nil {
+				return err
+			}
+
+			s, sub, err := twoStringArgs("index_of", args)
+			if err != nil {
+				return err
+			}
+
+			i := strings.Index(s, sub)
+			if i < 0 {
+				return &object.Integer{Value: -1}
+			}
+
+			return &object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+		},
+	},
+	"replace": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("replace", args, 3, 4); err != nil {
+				return err
+			}
+
+			s, old, err := twoStringArgs("replace", args)
+			if err != nil {
+				return err
+			}
+
+			replacement, err := stringArg("replace", args, 2)
+			if err != nil {
+				return err
+			}
+
+			n := int64(-1)
+			if len(args) == 4 {
+				if n, err = integerArg("replace", args, 3); err != nil {
+					return err
+				}
+			}
+
+			return &object.String{Value: strings.Replace(s, old, replacement, int(n))}
+		},
+	},
+	"substr": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("substr", args, 2, 3); err != nil {
+				return err
+			}
+
+			s, err := stringArg("substr", args, 0)
+			if err != nil {
+				return err
+			}
+
+			runes := []rune(s)
+
+			start, err := integerArg("substr", args, 1)
+			if err != nil {
+				return err
+			}
+
+			length := int64(len(runes))
+			if len(args) == 3 {
+				if length, err = integerArg("substr", args, 2); err != nil {
+					return err
+				}
+			}
+
+			if start < 0 {
+				start += int64(len(runes))
+			}
+			start = clamp(start, 0, int64(len(runes)))
+			end := clamp(start+max(length, 0), start, int64(len(runes)))
+
+			return &object.String{Value: string(runes[start:end])}
+		},
+	},
+	"chars": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("chars", args, 1, 1); err != nil {
+				return err
+			}
+
+			s, err := stringArg("chars", args, 0)
+			if err != nil {
+				return err
+			}
+
+			return stringsToArray(strings.Split(s, ""))
+		},
+	},
+	"repeat": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("repeat", args, 2, 2); err != nil {
+				return err
+			}
+
+			s, err := stringArg("repeat", args, 0)
+			if err != nil {
+				return err
+			}
+
+			n, err := integerArg("repeat", args, 1)
+			if err != nil {
+				return err
+			}
+
+			if n < 0 {
+				return newError("negative count passed to `repeat`: %d", n)
+			}
+
+			if tooLong(s, n) {
+				return newError("result of `repeat` is too long")
+			}
+
+			return &object.String{Value: strings.Repeat(s, int(n))}
+		},
+	},
+	"pad_left":  stringPadder("pad_left", true),
+	"pad_right": stringPadder("pad_right", false),
+	"format": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount("format", args, 1, -1); err != nil {
+				return err
+			}
+
+			f, err := stringArg("format", args, 0)
+			if err != nil {
+				return err
+			}
+
+			return format(f, args[1:])
+		},
+	},
+}
+
+func stringMapper(name string, fn func(string) string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(name, args, 1, 1); err != nil {
+				return err
+			}
+
+			s, err := stringArg(name, args, 0)
+			if err != nil {
+				return err
+			}
+
+			return &object.String{Value: fn(s)}
+		},
+	}
+}
+
+func stringPredicate(name string, fn func(string, string) bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(name, args, 2, 2); err != nil {
+				return err
+			}
+
+			a, b, err := twoStringArgs(name, args)
+			if err != nil {
+				return err
+			}
+
+			return boolToObj(fn(a, b))
+		},
+	}
+}
+
+// tooLong reports whether n copies of s are longer than maxSize.
+func tooLong(s string, n int64) bool {
+	return n > 0 && int64(len(s)) > maxSize/n
+}
+
+// stringPadder pads a string to a width in runes with a fill string that
+// defaults to a single space.
+func stringPadder(name string, left bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(name, args, 2, 3); err != nil {
+				return err
+			}
+
+			s, err := stringArg(name, args, 0)
+			if err != nil {
+				return err
+			}
+
+			width, err := integerArg(name, args, 1)
+			if err != nil {
+				return err
+			}
+
+			fill := " "
+			if len(args) == 3 {
+				if fill, err = stringArg(name, args, 2); err != nil {
+					return err
+				}
+			}
+
+			if fill == "" {
+				return newError("empty fill string passed to `%s`", name)
+			}
+
+			missing := int(width) - utf8.RuneCountInString(s)
+			if missing <= 0 {
+				return &object.String{Value: s}
+			}
+
+			runes := utf8.RuneCountInString(fill)
+			copies := int64(missing/runes + 1)
+			if tooLong(fill, copies) {
+				return newError("result of `%s` is too long", name)
+			}
+
+			padding := []rune(strings.Repeat(fill, int(copies)))[:missing]
+			if left {
+				return &object.String{Value: string(padding) + s}
+			}
+
+			return &object.String{Value: s + string(padding)}
+		},
+	}
+}
+
+// format implements printf-style formatting with the verbs %s, %v, %q, %d,
+// %x, %b, %o, %c and %t, the usual flags, width and precision, and %% for a
+// literal percent sign. %s and %v print strings without quotes and other
+// objects as Inspect does.
+func format(f string, args []object.Object) object.Object {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			out.WriteByte(f[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(f) && strings.IndexByte("+-# 0123456789.", f[i]) >= 0 {
+			i++
+		}
+
+		if i >= len(f) {
+			return newError("`format` string ends in the middle of a verb: %q", f[start:])
+		}
+
+		verb := f[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		if next >= len(args) {
+			return newError("missing argument for %s in `format`", f[start:i+1])
+		}
+		arg := args[next]
+		next++
+
+		var value interface{}
+		switch verb {
+		case 's', 'v':
+			if str, ok := arg.(*object.String); ok {
+				value = str.Value
+			} else {
+				value = arg.Inspect()
+			}
+		case 'q':
+			str, ok := arg.(*object.String)
+			if !ok {
+				return newError("%%%c in `format` expects STRING, got %s", verb, arg.Type())
+			}
+			value = str.Value
+		case 'd', 'x', 'X', 'b', 'o', 'c':
+			integer, ok := arg.(*object.Integer)
+			if !ok {
+				return newError("%%%c in `format` expects INTEGER, got %s", verb, arg.Type())
+			}
+			value = integer.Value
+		case 't':
+			boolean, ok := arg.(*object.Boolean)
+			if !ok {
+				return newError("%%%c in `format` expects BOOL, got %s", verb, arg.Type())
+			}
+			value = boolean.Value
+		default:
+			return newError("unknown verb %%%c in `format`", verb)
+		}
+
+		out.WriteString(fmt.Sprintf(f[start:i+1], value))
+	}
+
+	if next < len(args) {
+		return newError("too many arguments to `format`. got=%d, used=%d", len(args), next)
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func stringsToArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func clamp(n, lo, hi int64) int64 {
+	return min(max(n, lo), hi)
+}
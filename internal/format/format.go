@@ -0,0 +1,494 @@
+// Package format prints Monkey programs in their canonical form.
+package format
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Width is the line width past which calls, arrays and hash maps are
+// wrapped, one element per line.
+const Width = 80
+
+const indentUnit = "    "
+
+// SyntaxError is returned when the source to format does not parse.
+type SyntaxError struct {
+	Diagnostics []diagnostic.Diagnostic
+}
+
+func (e *SyntaxError) Error() string {
+	msgs := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		msgs[i] = d.String()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Source formats a Monkey source file. Comments are kept, and so are blank
+// lines between statements, squashed to one. Formatting the result again
+// does not change it.
+func Source(src string) (string, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		return "", &SyntaxError{Diagnostics: diags}
+	}
+
+	pr := &printer{lines: strings.Split(src, "\n")}
+	pr.assignComments(program, l.Comments())
+
+	return pr.program(program), nil
+}
+
+// Node formats a single node without comments.
+func Node(node ast.Node) string {
+	pr := &printer{}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		return pr.program(node)
+	case ast.Statement:
+		return pr.statement(node, 0)
+	case ast.Expression:
+		return pr.expr(node, 0)
+	}
+
+	return ""
+}
+
+// printer renders nodes as text whose first line is not indented and whose
+// other lines are indented absolutely, so a rendering can be placed after
+// anything already on the current line.
+type printer struct {
+	lines    []string
+	comments map[*ast.BlockStatement][]token.Comment // nil holds the top level
+	indent   int
+}
+
+// assignComments gives every comment to the innermost block that contains
+// it, or to the top level.
+func (p *printer) assignComments(program *ast.Program, comments []token.Comment) {
+	var blocks []*ast.BlockStatement
+	for _, stmt := range program.Statements {
+		collectBlocks(stmt, &blocks)
+	}
+
+	p.comments = make(map[*ast.BlockStatement][]token.Comment)
+	for _, c := range comments {
+		var owner *ast.BlockStatement
+		for _, b := range blocks {
+			inside := b.Token.Pos.Before(c.Pos) && c.Pos.Before(b.End)
+			if inside && (owner == nil || owner.Token.Pos.Before(b.Token.Pos)) {
+				owner = b
+			}
+		}
+
+		p.comments[owner] = append(p.comments[owner], c)
+	}
+}
+
+func collectBlocks(node ast.Node, blocks *[]*ast.BlockStatement) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		collectBlocks(node.Value, blocks)
+	case *ast.ReturnStatement:
+		collectBlocks(node.ReturnValue, blocks)
+	case *ast.ExpressionStatement:
+		collectBlocks(node.Expression, blocks)
+	case *ast.BlockStatement:
+		*blocks = append(*blocks, node)
+		for _, stmt := range node.Statements {
+			collectBlocks(stmt, blocks)
+		}
+	case *ast.PrefixExpression:
+		collectBlocks(node.Right, blocks)
+	case *ast.InfixExpression:
+		collectBlocks(node.Left, blocks)
+		collectBlocks(node.Right, blocks)
+	case *ast.IfExpression:
+		collectBlocks(node.Condition, blocks)
+		collectBlocks(node.Consequence, blocks)
+		if node.Alternative != nil {
+			collectBlocks(node.Alternative, blocks)
+		}
+	case *ast.FunctionLiteral:
+		collectBlocks(node.Body, blocks)
+	case *ast.CallExpression:
+		collectBlocks(node.Function, blocks)
+		for _, arg := range node.Arguments {
+			collectBlocks(arg, blocks)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			collectBlocks(e, blocks)
+		}
+	case *ast.IndexExpression:
+		collectBlocks(node.Left, blocks)
+		collectBlocks(node.Index, blocks)
+	case *ast.SliceExpression:
+		collectBlocks(node.Left, blocks)
+		collectBlocks(node.Start, blocks)
+		collectBlocks(node.End, blocks)
+		collectBlocks(node.Step, blocks)
+	case *ast.HashMapLiteral:
+		for _, pair := range node.Pairs {
+			collectBlocks(pair.Key, blocks)
+			collectBlocks(pair.Value, blocks)
+		}
+	}
+}
+
+func (p *printer) program(program *ast.Program) string {
+	lines := p.statements(program.Statements, p.comments[nil], false)
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// statements renders a list of statements with their comments, one entry
+// per statement or comment. Every statement ends with a semicolon, except
+// for the last one in a block where the closing brace follows.
+func (p *printer) statements(stmts []ast.Statement, comments []token.Comment, inBlock bool) []string {
+	pad := strings.Repeat(indentUnit, p.indent)
+	lines := []string{}
+	next := 0
+	prevLine := 0
+
+	// newLine adds a line for something starting at line, preceded by an
+	// empty one if the source has a blank line right above it.
+	newLine := func(line int, text string) {
+		if len(lines) > 0 && line > prevLine+1 && p.blankLine(line-1) {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, text)
+		prevLine = line
+	}
+
+	// flush emits the comments that come before pos. Trailing comments stay
+	// at the end of the line before; the others get lines of their own.
+	flush := func(pos token.Position, last bool) {
+		for next < len(comments) && (last || comments[next].Pos.Before(pos)) {
+			c := comments[next]
+			next++
+
+			if c.Trailing && len(lines) > 0 {
+				lines[len(lines)-1] += " " + c.Text
+				prevLine = c.Pos.Line
+				continue
+			}
+
+			newLine(c.Pos.Line, pad+c.Text)
+		}
+	}
+
+	for i, stmt := range stmts {
+		flush(stmtPos(stmt), false)
+
+		text := pad + p.statement(stmt, len(pad))
+		if !inBlock || i < len(stmts)-1 {
+			text += ";"
+		}
+
+		newLine(stmtPos(stmt).Line, text)
+	}
+
+	flush(token.Position{}, true)
+
+	return lines
+}
+
+// blankLine reports whether line of the source is empty. Without source it
+// reports false.
+func (p *printer) blankLine(line int) bool {
+	return line >= 1 && line <= len(p.lines) && strings.TrimSpace(p.lines[line-1]) == ""
+}
+
+func stmtPos(stmt ast.Statement) token.Position {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos
+	}
+
+	return token.Position{}
+}
+
+// statement renders stmt without its semicolon. col is the column it
+// starts at.
+func (p *printer) statement(stmt ast.Statement, col int) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		prefix := "let " + declared(stmt.Name) + " = "
+		return prefix + p.expr(stmt.Value, col+width(prefix))
+	case *ast.ReturnStatement:
+		return "return " + p.expr(stmt.ReturnValue, col+width("return "))
+	case *ast.ExpressionStatement:
+		return p.expr(stmt.Expression, col)
+	case *ast.BlockStatement:
+		return p.block(stmt, col, false)
+	}
+
+	return ""
+}
+
+// atom is the precedence of expressions that never need parentheses.
+const atom = parser.INDEX + 1
+
+var precedences = map[string]parser.Precedence{
+	"==": parser.EQUALS,
+	"!=": parser.EQUALS,
+	"<":  parser.LESSGREATER,
+	">":  parser.LESSGREATER,
+	"+":  parser.SUM,
+	"-":  parser.SUM,
+	"*":  parser.PRODUCT,
+	"/":  parser.PRODUCT,
+}
+
+func precedence(e ast.Expression) parser.Precedence {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return precedences[e.Operator]
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	}
+
+	return atom
+}
+
+// operand renders e, in parentheses if it binds looser than min.
+func (p *printer) operand(e ast.Expression, min parser.Precedence, col int) string {
+	if precedence(e) < min {
+		return "(" + p.expr(e, col+1) + ")"
+	}
+
+	return p.expr(e, col)
+}
+
+// expr renders e starting at column col.
+func (p *printer) expr(e ast.Expression, col int) string {
+	switch e := e.(type) {
+	case *ast.ID:
+		return e.Value
+	case *ast.IntegerLiteral:
+		return strconv.FormatInt(e.Value, 10)
+	case *ast.BooleanExpression:
+		return strconv.FormatBool(e.Value)
+	case *ast.StringLiteral:
+		return Quote(e.Value)
+	case *ast.PrefixExpression:
+		return e.Operator + p.operand(e.Right, parser.PREFIX, col+width(e.Operator))
+	case *ast.InfixExpression:
+		prec := precedences[e.Operator]
+		left := p.operand(e.Left, prec, col)
+		op := " " + e.Operator + " "
+		return left + op + p.operand(e.Right, prec+1, lastCol(col, left)+width(op))
+	case *ast.CallExpression:
+		callee := p.operand(e.Function, parser.CALL, col)
+		return callee + p.list("(", ")", e.Arguments, lastCol(col, callee), true)
+	case *ast.IndexExpression:
+		left := p.operand(e.Left, parser.INDEX, col)
+		return left + "[" + p.expr(e.Index, lastCol(col, left)+1) + "]"
+	case *ast.SliceExpression:
+		left := p.operand(e.Left, parser.INDEX, col)
+		out := left + "[" + p.bound(e.Start, lastCol(col, left)+1) + ":"
+		out += p.bound(e.End, lastCol(col, out))
+		if e.Step != nil {
+			out += ":" + p.expr(e.Step, lastCol(col, out)+1)
+		}
+
+		return out + "]"
+	case *ast.ArrayLiteral:
+		return p.list("[", "]", e.Elements, col, false)
+	case *ast.HashMapLiteral:
+		items := make([]func(int) string, len(e.Pairs))
+		for i, pair := range e.Pairs {
+			pair := pair
+			items[i] = func(col int) string {
+				key := p.expr(pair.Key, col)
+				return key + ": " + p.expr(pair.Value, lastCol(col, key)+2)
+			}
+		}
+
+		return p.items("{", "}", items, col, false)
+	case *ast.IfExpression:
+		return p.ifExpr(e, col, false)
+	case *ast.FunctionLiteral:
+		params := make([]string, len(e.Params))
+		for i, param := range e.Params {
+			params[i] = declared(param)
+		}
+
+		out := "fn(" + strings.Join(params, ", ") + ") "
+		if e.ReturnType != nil {
+			out += "-> " + e.ReturnType.String() + " "
+		}
+		return out + p.block(e.Body, lastCol(col, out), false)
+	}
+
+	return ""
+}
+
+// declared renders a name being declared with its type annotation, if any.
+func declared(id *ast.ID) string {
+	if id.Type == nil {
+		return id.Value
+	}
+
+	return id.Value + ": " + id.Type.String()
+}
+
+// ifExpr renders both branches of an if expression on several lines if one
+// of them needs to.
+func (p *printer) ifExpr(e *ast.IfExpression, col int, split bool) string {
+	out := "if (" + p.expr(e.Condition, col+4) + ") "
+	out += p.block(e.Consequence, lastCol(col, out), split)
+	if e.Alternative != nil {
+		out += " else "
+		out += p.block(e.Alternative, lastCol(col, out), split)
+	}
+
+	if !split && e.Alternative != nil && strings.Contains(out, "\n") {
+		return p.ifExpr(e, col, true)
+	}
+
+	return out
+}
+
+func (p *printer) bound(e ast.Expression, col int) string {
+	if e == nil {
+		return ""
+	}
+
+	return p.expr(e, col)
+}
+
+func (p *printer) list(open, close string, exprs []ast.Expression, col int, hug bool) string {
+	items := make([]func(int) string, len(exprs))
+	for i, e := range exprs {
+		e := e
+		items[i] = func(col int) string { return p.expr(e, col) }
+	}
+
+	return p.items(open, close, items, col, hug)
+}
+
+// items renders a bracketed, comma separated list on one line if it fits in
+// Width, and one item per line otherwise. With hug the last item may span
+// several lines, so a function passed last to a call still starts on the
+// line of the call.
+func (p *printer) items(open, close string, items []func(int) string, col int, hug bool) string {
+	flat := open
+	fits := true
+	for i, item := range items {
+		if i > 0 {
+			flat += ", "
+		}
+
+		text := item(lastCol(col, flat))
+		if (i < len(items)-1 || !hug) && strings.Contains(text, "\n") {
+			fits = false
+		}
+		flat += text
+	}
+	flat += close
+
+	firstLine, _, _ := strings.Cut(flat, "\n")
+	if len(items) == 0 || fits && col+width(firstLine) <= Width {
+		return flat
+	}
+
+	p.indent++
+	pad := strings.Repeat(indentUnit, p.indent)
+	out := open + "\n"
+	for i, item := range items {
+		out += pad + item(width(pad))
+		if i < len(items)-1 {
+			out += ","
+		}
+		out += "\n"
+	}
+	p.indent--
+
+	return out + strings.Repeat(indentUnit, p.indent) + close
+}
+
+// block renders b on one line if it holds a single expression or return
+// statement, has no comments and fits in Width, unless split is set.
+func (p *printer) block(b *ast.BlockStatement, col int, split bool) string {
+	comments := p.comments[b]
+
+	if len(b.Statements) == 0 && len(comments) == 0 {
+		return "{}"
+	}
+
+	if len(b.Statements) == 1 && len(comments) == 0 && !split {
+		switch stmt := b.Statements[0].(type) {
+		case *ast.ExpressionStatement, *ast.ReturnStatement:
+			inline := "{ " + p.statement(stmt, col+2) + " }"
+			if !strings.Contains(inline, "\n") && col+width(inline) <= Width {
+				return inline
+			}
+		}
+	}
+
+	p.indent++
+	lines := p.statements(b.Statements, comments, true)
+	p.indent--
+
+	return "{\n" + strings.Join(lines, "\n") + "\n" + strings.Repeat(indentUnit, p.indent) + "}"
+}
+
+// Quote returns s as a Monkey string literal.
+func Quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
+
+// lastCol returns the column after text, placed at column col.
+func lastCol(col int, text string) int {
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return width(text[i+1:])
+	}
+
+	return col + width(text)
+}
+
+func width(s string) int {
+	return utf8.RuneCountInString(s)
+}
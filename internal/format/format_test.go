@@ -0,0 +1,174 @@
+package format_test
+
+import (
+	"errors"
+	"monkey/internal/format"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"spacing and semicolons",
+			"let a=1\nlet b = a+2*3;a",
+			"let a = 1;\nlet b = a + 2 * 3;\na;\n",
+		},
+		{
+			"parentheses",
+			"(1 + 2) * 3 - -(4 - 5) / (6 * 7); 1 - (2 - 3); (1 - 2) - 3; (-f)(x); -a[0]",
+			"(1 + 2) * 3 - -(4 - 5) / (6 * 7);\n1 - (2 - 3);\n1 - 2 - 3;\n(-f)(x);\n-a[0];\n",
+		},
+		{
+			"strings and slices",
+			`"a\"b\\c\n"; s[1:2]; s[::-1]; s[:]`,
+			"\"a\\\"b\\\\c\\n\";\ns[1:2];\ns[::-1];\ns[:];\n",
+		},
+		{
+			"blocks",
+			"let f = fn(x) { x }; let g = fn() { let y = 1; y }; if (a) { 1 } else { if (b) { let c = 2; c } }",
+			`let f = fn(x) { x };
+let g = fn() {
+    let y = 1;
+    y
+};
+if (a) {
+    1
+} else {
+    if (b) {
+        let c = 2;
+        c
+    }
+};
+`,
+		},
+		{
+			"annotations",
+			"let n:int=1; let f=fn(x:[int],g : fn(int)->bool)->{string:int} { {} }",
+			"let n: int = 1;\nlet f = fn(x: [int], g: fn(int) -> bool) -> {string: int} { {} };\n",
+		},
+		{
+			"wrapping",
+			`someFunction(firstArgument, secondArgument, [1, 2, 3, 4, 5, 6, 7, 8, 9], thirdArgument);
+map([1, 2, 3], fn(x) { let y = x * 2; y });
+{"add": fn(a, b) { a + b }, "sub": fn(a, b) { let d = a - b; d }}`,
+			`someFunction(
+    firstArgument,
+    secondArgument,
+    [1, 2, 3, 4, 5, 6, 7, 8, 9],
+    thirdArgument
+);
+map([1, 2, 3], fn(x) {
+    let y = x * 2;
+    y
+});
+{
+    "add": fn(a, b) { a + b },
+    "sub": fn(a, b) {
+        let d = a - b;
+        d
+    }
+};
+`,
+		},
+		{
+			"comments and blank lines",
+			`// header
+let a = 1; // one
+
+
+let f = fn() { // start
+  let x = 1;
+
+  // before x
+
+  x // last
+}; // after f
+// end
+`,
+			`// header
+let a = 1; // one
+
+let f = fn() {
+    // start
+    let x = 1;
+
+    // before x
+
+    x // last
+}; // after f
+// end
+`,
+		},
+		{
+			"empty",
+			"  \n",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format.Source(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got != tt.expected {
+				t.Fatalf("wrong output.\ngot:\n%s\nwant:\n%s", got, tt.expected)
+			}
+
+			checkStable(t, tt.input, got)
+		})
+	}
+}
+
+// checkStable checks that formatted is a fixed point of the formatter and
+// parses to the same program as src.
+func checkStable(t *testing.T, src, formatted string) {
+	t.Helper()
+
+	again, err := format.Source(formatted)
+	if err != nil {
+		t.Fatalf("formatted source does not parse: %s", err)
+	}
+
+	if again != formatted {
+		t.Errorf("formatting is not idempotent.\nfirst:\n%s\nsecond:\n%s", formatted, again)
+	}
+
+	if before, after := parse(t, src), parse(t, formatted); before != after {
+		t.Errorf("formatting changed the program.\nbefore: %s\nafter:  %s", before, after)
+	}
+}
+
+func parse(t *testing.T, src string) string {
+	t.Helper()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program.String()
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := format.Source("let = 1;")
+
+	var syntaxErr *format.SyntaxError
+	if err == nil || !strings.Contains(err.Error(), "1:5: error:") {
+		t.Fatalf("expected a positioned syntax error. got=%v", err)
+	}
+
+	if !errors.As(err, &syntaxErr) || len(syntaxErr.Diagnostics) != 1 {
+		t.Errorf("error is not a SyntaxError with one diagnostic. got=%#v", err)
+	}
+}
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"monkey/internal/token"
+	"strings"
 	"unicode"
 )
 
@@ -10,11 +11,17 @@ type Lexer struct {
 	pos     int
 	readPos int
 	ch      rune
+	line    int
+	col     int
+
+	comments []token.Comment
+	lastLine int // line of the last token returned
 }
 
 func New(input string) *Lexer {
 	l := &Lexer{
 		input: []rune(input),
+		line:  1,
 	}
 	l.readChar()
 
@@ -24,9 +31,10 @@ func New(input string) *Lexer {
 func (l *Lexer) NextToken() token.Token {
 	var t token.Token
 
-	for unicode.IsSpace(l.ch) {
-		l.readChar()
-	}
+	l.skipWhitespaceAndComments()
+
+	pos := token.Position{Line: l.line, Column: l.col}
+	l.lastLine = pos.Line
 
 	switch l.ch {
 	case '=':
@@ -66,7 +74,13 @@ func (l *Lexer) NextToken() token.Token {
 	case '+':
 		t = token.New(token.PLUS, l.ch)
 	case '-':
-		t = token.New(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			t = token.Token{Type: token.ARROW, Literal: string(ch) + string(l.ch)}
+		} else {
+			t = token.New(token.MINUS, l.ch)
+		}
 	case '/':
 		t = token.New(token.SLASH, l.ch)
 	case '*':
@@ -76,14 +90,20 @@ func (l *Lexer) NextToken() token.Token {
 	case '<':
 		t = token.New(token.LT, l.ch)
 	case '"':
+		start := l.pos
 		t.Type = token.STRING
 		t.Literal = l.readString()
+		if l.ch != '"' {
+			// An unterminated string is the rest of the input as written.
+			t = token.Token{Type: token.INVALID, Literal: string(l.input[start:l.pos]), Pos: pos}
+			return t
+		}
 	case 0:
 		t.Type = token.EOF
 		t.Literal = ""
 	default:
 		switch {
-		case unicode.IsLetter(l.ch):
+		case isIDStart(l.ch):
 			t.Literal = l.readID()
 			t.Type = token.LookupID(t.Literal)
 		case unicode.IsDigit(l.ch):
@@ -91,29 +111,75 @@ func (l *Lexer) NextToken() token.Token {
 			t.Type = token.INT
 		default:
 			t = token.New(token.INVALID, l.ch)
+			l.readChar()
 		}
+		t.Pos = pos
 		return t
 	}
 
 	l.readChar()
+	t.Pos = pos
 	return t
 }
 
+// Comments returns the comments skipped so far, in source order.
+func (l *Lexer) Comments() []token.Comment {
+	return l.comments
+}
+
+func (l *Lexer) skipWhitespaceAndComments() {
+	for {
+		switch {
+		case unicode.IsSpace(l.ch):
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) readComment() {
+	pos := token.Position{Line: l.line, Column: l.col}
+	start := l.pos
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	l.comments = append(l.comments, token.Comment{
+		Pos:      pos,
+		Text:     strings.TrimRightFunc(string(l.input[start:l.pos]), unicode.IsSpace),
+		Trailing: l.lastLine == pos.Line,
+	})
+}
+
 func (l *Lexer) readID() string {
 	pos := l.pos
 
-	if unicode.IsLetter(l.ch) {
+	if isIDStart(l.ch) {
 		l.readChar()
 	}
 
-	for unicode.IsDigit(l.ch) || unicode.IsLetter(l.ch) {
+	for unicode.IsDigit(l.ch) || isIDStart(l.ch) {
 		l.readChar()
 	}
 
 	return string(l.input[pos:l.pos])
 }
 
+func isIDStart(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.col = 0
+	}
+	l.col++
+
 	if l.readPos >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -134,16 +200,37 @@ func (l *Lexer) readNumber() string {
 	return string(l.input[pos:l.pos])
 }
 
+// readString returns the contents of a string literal with the escape
+// sequences \", \\, \n, \t and \r decoded. Any other backslash is kept as is.
+// It stops at the closing quote or, if there is none, the end of the input.
 func (l *Lexer) readString() string {
-	pos := l.pos + 1
+	var out []rune
 	for {
 		l.readChar()
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
+
+		if l.ch == '\\' {
+			if escaped, ok := escapes[l.peekChar()]; ok {
+				l.readChar()
+				out = append(out, escaped)
+				continue
+			}
+		}
+
+		out = append(out, l.ch)
 	}
 
-	return string(l.input[pos:l.pos])
+	return string(out)
+}
+
+var escapes = map[rune]rune{
+	'"':  '"',
+	'\\': '\\',
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
 }
 
 func (l *Lexer) peekChar() rune {
@@ -3,7 +3,10 @@ package lexer_test
 import (
 	"monkey/internal/lexer"
 	"monkey/internal/token"
+	"strings"
 	"testing"
+	"unicode"
+	"unicode/utf8"
 )
 
 type nextTokenExpectedValue struct {
@@ -36,6 +39,8 @@ func TestNextToken(t *testing.T) {
 			"foo bar"
 			[1, 2];
 			{"foo": "bar"}
+			assert_eq _x1
+			-> - >
 			`,
 		expected: []nextTokenExpectedValue{
 			{token.LET, "let"},
@@ -137,6 +142,11 @@ func TestNextToken(t *testing.T) {
 			{token.COLON, ":"},
 			{token.STRING, "bar"},
 			{token.RBRACE, "}"},
+			{token.ID, "assert_eq"},
+			{token.ID, "_x1"},
+			{token.ARROW, "->"},
+			{token.MINUS, "-"},
+			{token.GT, ">"},
 			{token.EOF, ""},
 		},
 	}
@@ -157,3 +167,215 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + "héllo";`
+
+	expected := []token.Position{
+		{Line: 1, Column: 1},
+		{Line: 1, Column: 5},
+		{Line: 1, Column: 7},
+		{Line: 1, Column: 9},
+		{Line: 1, Column: 10},
+		{Line: 2, Column: 3},
+		{Line: 2, Column: 5},
+		{Line: 2, Column: 7},
+		{Line: 2, Column: 14},
+		{Line: 2, Column: 15},
+	}
+
+	l := lexer.New(input)
+
+	for i, pos := range expected {
+		tok := l.NextToken()
+		if tok.Pos != pos {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%s, got=%s", i, tok.Literal, pos, tok.Pos)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\"b"`, `a"b`},
+		{`"a\\b"`, `a\b`},
+		{`"a\nb\tc\rd"`, "a\nb\tc\rd"},
+		{`"a\qb"`, `a\qb`},
+		{`""`, ``},
+	}
+
+	for _, tt := range tests {
+		tok := lexer.New(tt.input).NextToken()
+		if tok.Type != token.STRING || tok.Literal != tt.expected {
+			t.Errorf("wrong token for %s. got=%s %q, want=STRING %q", tt.input, tok.Type, tok.Literal, tt.expected)
+		}
+	}
+}
+
+func TestInvalidTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"a @ b", []token.Token{
+			{Type: token.ID, Literal: "a", Pos: token.Position{Line: 1, Column: 1}},
+			{Type: token.INVALID, Literal: "@", Pos: token.Position{Line: 1, Column: 3}},
+			{Type: token.ID, Literal: "b", Pos: token.Position{Line: 1, Column: 5}},
+		}},
+		{"2.5", []token.Token{
+			{Type: token.INT, Literal: "2", Pos: token.Position{Line: 1, Column: 1}},
+			{Type: token.INVALID, Literal: ".", Pos: token.Position{Line: 1, Column: 2}},
+			{Type: token.INT, Literal: "5", Pos: token.Position{Line: 1, Column: 3}},
+		}},
+		{`x = "ab\"c`, []token.Token{
+			{Type: token.ID, Literal: "x", Pos: token.Position{Line: 1, Column: 1}},
+			{Type: token.ASSIGN, Literal: "=", Pos: token.Position{Line: 1, Column: 3}},
+			{Type: token.INVALID, Literal: `"ab\"c`, Pos: token.Position{Line: 1, Column: 5}},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		for i, expected := range tt.expected {
+			if tok := l.NextToken(); tok != expected {
+				t.Errorf("token %d of %q wrong. got=%+v, want=%+v", i, tt.input, tok, expected)
+			}
+		}
+
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%q not at EOF. got=%+v", tt.input, tok)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// first
+let x = 5; // second
+/ 2 // third`
+
+	l := lexer.New(input)
+	types := []token.TokenType{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+
+	expectedTypes := []token.TokenType{token.LET, token.ID, token.ASSIGN, token.INT, token.SEMICOLON, token.SLASH, token.INT}
+	if len(types) != len(expectedTypes) {
+		t.Fatalf("wrong tokens. got=%v", types)
+	}
+
+	for i, typ := range expectedTypes {
+		if types[i] != typ {
+			t.Errorf("tokens[%d] wrong. got=%s, want=%s", i, types[i], typ)
+		}
+	}
+
+	expected := []token.Comment{
+		{Pos: token.Position{Line: 1, Column: 1}, Text: "// first"},
+		{Pos: token.Position{Line: 2, Column: 12}, Text: "// second", Trailing: true},
+		{Pos: token.Position{Line: 3, Column: 5}, Text: "// third", Trailing: true},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. got=%v", comments)
+	}
+
+	for i, c := range expected {
+		if comments[i] != c {
+			t.Errorf("comments[%d] wrong. got=%+v, want=%+v", i, comments[i], c)
+		}
+	}
+}
+
+// FuzzLexer checks that the lexer gets through any input, one token at a
+// time, and that every token is what the source says at its position.
+func FuzzLexer(f *testing.F) {
+	for _, seed := range []string{
+		"let x = 5; x + 10 != -2",
+		`puts("a\"b\\c\n", "héllo")`,
+		"fn(a: int, b) -> [string] { a[1:2:3] }",
+		"// comment\n{\"k\": true} // trailing",
+		"a @ b # 2.5",
+		`"unterminated`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		runes, lines := []rune(input), lineStarts(input)
+
+		l := lexer.New(input)
+		var prev token.Token
+		for i := 0; ; i++ {
+			tok := l.NextToken()
+			if i > len(runes) {
+				t.Fatalf("more tokens than characters in %q", input)
+			}
+			if i > 0 && !prev.Pos.Before(tok.Pos) {
+				t.Fatalf("%+v comes after %+v in %q", tok, prev, input)
+			}
+			prev = tok
+
+			if tok.Type == token.EOF {
+				if tok.Literal != "" {
+					t.Fatalf("EOF has the literal %q in %q", tok.Literal, input)
+				}
+				return
+			}
+
+			if tok.Pos.Line < 1 || tok.Pos.Line > len(lines) || tok.Pos.Column < 1 {
+				t.Fatalf("%+v is outside of %q", tok, input)
+			}
+			offset := lines[tok.Pos.Line-1] + tok.Pos.Column - 1
+			if offset >= len(runes) || tok.Pos.Line < len(lines) && offset >= lines[tok.Pos.Line] {
+				t.Fatalf("%+v is outside of %q", tok, input)
+			}
+			source := string(runes[offset:])
+
+			switch tok.Type {
+			case token.STRING:
+				// The literal is decoded, so only the quote is in the source.
+				if !strings.HasPrefix(source, `"`) {
+					t.Fatalf("%+v does not start with a quote in %q", tok, input)
+				}
+				continue
+			case token.ID:
+				if tok.Literal == "" || token.LookupID(tok.Literal) != token.ID {
+					t.Fatalf("%+v is not an identifier in %q", tok, input)
+				}
+			case token.INT:
+				if tok.Literal == "" || strings.IndexFunc(tok.Literal, func(r rune) bool { return !unicode.IsDigit(r) }) >= 0 {
+					t.Fatalf("%+v is not a number in %q", tok, input)
+				}
+			case token.INVALID:
+				if utf8.RuneCountInString(tok.Literal) != 1 && !strings.HasPrefix(tok.Literal, `"`) {
+					t.Fatalf("%+v is neither a character nor an unterminated string in %q", tok, input)
+				}
+			default:
+				if tok.Literal != tok.Type.String() {
+					t.Fatalf("%+v is not spelled as its type in %q", tok, input)
+				}
+			}
+
+			if !strings.HasPrefix(source, tok.Literal) {
+				t.Fatalf("%+v is not at its position in %q", tok, input)
+			}
+		}
+	})
+}
+
+// lineStarts returns the offsets in characters of the lines of input.
+func lineStarts(input string) []int {
+	starts := []int{0}
+	for i, r := range []rune(input) {
+		if r == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+
+	return starts
+}
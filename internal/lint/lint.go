@@ -0,0 +1,243 @@
+// Package lint finds mistakes in Monkey programs that would otherwise only
+// show up when they run, or never. Every kind of mistake is found by a rule
+// that can be turned off, for a whole run or with comments in the source:
+//
+//	let x = 1; // lint:ignore unused
+//
+//	// lint:ignore arity, not-callable
+//	f(1, 2);
+//
+//	// lint:file-ignore shadow-builtin
+//
+// lint:ignore applies to the line it ends, or to the next line if it is
+// on a line of its own, and lint:file-ignore to the whole file. Without
+// rule names they apply to all rules.
+package lint
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"monkey/internal/token"
+	"sort"
+	"strings"
+)
+
+// Rule is a kind of mistake the linter looks for.
+type Rule struct {
+	Name string
+	Doc  string
+
+	check func(p *pass)
+}
+
+// Rules are all rules, by name.
+var Rules = []*Rule{
+	{Name: "arity", Doc: "calls of functions with the wrong number of arguments", check: checkArity},
+	{Name: "not-callable", Doc: "calls of values that are not functions", check: checkNotCallable},
+	{Name: "shadow-builtin", Doc: "variables and parameters named like a builtin function", check: checkShadowBuiltin},
+	{Name: "unreachable", Doc: "statements after a return", check: checkUnreachable},
+	{Name: "unused", Doc: "let bindings that are never used", check: checkUnused},
+}
+
+// Linter checks programs with a set of rules.
+type Linter struct {
+	enabled map[string]bool
+}
+
+// New returns a linter with all rules enabled.
+func New() *Linter {
+	l := &Linter{enabled: make(map[string]bool)}
+	for _, r := range Rules {
+		l.enabled[r.Name] = true
+	}
+
+	return l
+}
+
+// Enable enables exactly the rules with the given names.
+func (l *Linter) Enable(names ...string) error {
+	if err := checkNames(names); err != nil {
+		return err
+	}
+
+	l.enabled = make(map[string]bool)
+	for _, name := range names {
+		l.enabled[name] = true
+	}
+
+	return nil
+}
+
+// Disable disables the rules with the given names.
+func (l *Linter) Disable(names ...string) error {
+	if err := checkNames(names); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		delete(l.enabled, name)
+	}
+
+	return nil
+}
+
+func checkNames(names []string) error {
+	for _, name := range names {
+		if findRule(name) == nil {
+			return fmt.Errorf("unknown rule %q", name)
+		}
+	}
+
+	return nil
+}
+
+func findRule(name string) *Rule {
+	for _, r := range Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// Lint checks src and returns what it finds, in source order. Syntax errors
+// and undefined variables are reported as errors and cannot be suppressed,
+// and the findings of the rules as warnings whose Code is the rule's name.
+func (l *Linter) Lint(src string) []diagnostic.Diagnostic {
+	lex := lexer.New(src)
+	p := parser.New(lex)
+	program := p.ParseProgram()
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		return diags
+	}
+
+	r := resolver.New(eval.BuiltinNames())
+
+	// The resolver's warnings are about shadowing, which the rules cover.
+	var diags []diagnostic.Diagnostic
+	for _, d := range r.Resolve(program) {
+		if d.Severity == diagnostic.Error {
+			diags = append(diags, d)
+		}
+	}
+
+	pass := &pass{program: program, resolver: r, builtins: make(map[string]bool)}
+	for _, name := range eval.BuiltinNames() {
+		pass.builtins[name] = true
+	}
+
+	sup := suppressions(lex.Comments())
+	for _, rule := range Rules {
+		if !l.enabled[rule.Name] {
+			continue
+		}
+
+		pass.rule = rule.Name
+		pass.diags = nil
+		rule.check(pass)
+
+		for _, d := range pass.diags {
+			if !sup.ignores(d.Pos.Line, rule.Name) {
+				diags = append(diags, d)
+			}
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Pos.Before(diags[j].Pos)
+	})
+
+	return diags
+}
+
+// pass is a run of a rule over a program.
+type pass struct {
+	program  *ast.Program
+	resolver *resolver.Resolver
+	builtins map[string]bool
+
+	rule  string
+	diags []diagnostic.Diagnostic
+}
+
+func (p *pass) reportf(pos token.Position, format string, args ...interface{}) {
+	p.diags = append(p.diags, diagnostic.Diagnostic{
+		Pos:      pos,
+		Severity: diagnostic.Warning,
+		Message:  fmt.Sprintf(format, args...),
+		Code:     p.rule,
+	})
+}
+
+// suppression holds the rules that comments turn off. A nil set of rules
+// means all of them.
+type suppression struct {
+	file  map[string]bool
+	lines map[int]map[string]bool
+
+	allFile  bool
+	allLines map[int]bool
+}
+
+func suppressions(comments []token.Comment) *suppression {
+	s := &suppression{file: make(map[string]bool), lines: make(map[int]map[string]bool), allLines: make(map[int]bool)}
+
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		directive, args, _ := strings.Cut(text, " ")
+
+		var rules []string
+		for _, name := range strings.Split(args, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				rules = append(rules, name)
+			}
+		}
+
+		switch directive {
+		case "lint:file-ignore":
+			if len(rules) == 0 {
+				s.allFile = true
+			}
+			for _, name := range rules {
+				s.file[name] = true
+			}
+		case "lint:ignore":
+			line := c.Pos.Line
+			if !c.Trailing {
+				line++
+			}
+
+			if len(rules) == 0 {
+				s.allLines[line] = true
+			}
+			if s.lines[line] == nil {
+				s.lines[line] = make(map[string]bool)
+			}
+			for _, name := range rules {
+				s.lines[line][name] = true
+			}
+		}
+	}
+
+	return s
+}
+
+func (s *suppression) ignores(line int, rule string) bool {
+	return s.allFile || s.file[rule] || s.allLines[line] || s.lines[line][rule]
+}
+
+// walk calls fn for node and all nodes below it, in source order.
+func walk(node ast.Node, fn func(ast.Node)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			fn(n)
+		}
+		return true
+	})
+}
@@ -0,0 +1,164 @@
+package lint_test
+
+import (
+	"monkey/internal/lint"
+	"reflect"
+	"testing"
+)
+
+func lintStrings(l *lint.Linter, src string) []string {
+	var got []string
+	for _, d := range l.Lint(src) {
+		got = append(got, d.String())
+	}
+
+	return got
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected []string
+	}{
+		{
+			"unused",
+			"let a = 1; let b = 2; let _c = 3; let f = fn(x) { let y = x; 1 }; let test_f = fn() { f(b) };",
+			[]string{
+				"1:5: warning: a is declared but never used [unused]",
+				"1:55: warning: y is declared but never used [unused]",
+			},
+		},
+		{
+			"unused redeclaration",
+			"let a = 1; let a = 2; puts(a);",
+			nil,
+		},
+		{
+			"shadow builtin",
+			"let len = 1; let f = fn(map, x) { x }; let len = 2; f(len, 1);",
+			[]string{
+				"1:5: warning: len shadows the builtin function of the same name [shadow-builtin]",
+				"1:25: warning: map shadows the builtin function of the same name [shadow-builtin]",
+			},
+		},
+		{
+			"not callable",
+			`let n = 1; let s = "s"; let m = 2; let m = fn() { 1 }; n(); s(); m(); [1](); true();`,
+			[]string{
+				"1:56: warning: n is INTEGER, not a function [not-callable]",
+				"1:61: warning: s is STRING, not a function [not-callable]",
+				"1:71: warning: not a function: ARRAY [not-callable]",
+				"1:78: warning: not a function: BOOL [not-callable]",
+			},
+		},
+		{
+			"arity",
+			"let f = fn(a, b) { a }; f(1); f(1, 2); fn() { 1 }(2); let g = fn(h) { h(1, 2) }; g(f);",
+			[]string{
+				"1:25: warning: f takes 2 arguments, but is called with 1 [arity]",
+				"1:40: warning: the function takes 0 arguments, but is called with 1 [arity]",
+			},
+		},
+		{
+			"unreachable",
+			"let f = fn() { return 1; puts(2); puts(3) }; f(); return 1; f();",
+			[]string{
+				"1:26: warning: unreachable statement after return [unreachable]",
+				"1:61: warning: unreachable statement after return [unreachable]",
+			},
+		},
+		{
+			"undefined variables are errors",
+			"let a = b;",
+			[]string{
+				"1:5: warning: a is declared but never used [unused]",
+				"1:9: error: undefined variable: b",
+			},
+		},
+		{
+			"syntax errors stop linting",
+			"let = 1; let unused = 2;",
+			[]string{"1:5: error: expected next token to be ID, but got = instead"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lintStrings(lint.New(), tt.src)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got\n%q\nexpected\n%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected []string
+	}{
+		{
+			"same line",
+			"let a = 1; // lint:ignore unused\nlet b = 2; // lint:ignore arity\n",
+			[]string{"2:5: warning: b is declared but never used [unused]"},
+		},
+		{
+			"next line",
+			"// lint:ignore unused, shadow-builtin\nlet len = 1;\nlet b = 2;\n",
+			[]string{"3:5: warning: b is declared but never used [unused]"},
+		},
+		{
+			"all rules",
+			"// lint:ignore\nlet len = 1;\n",
+			nil,
+		},
+		{
+			"file",
+			"let a = 1;\nlet len = 2;\n// lint:file-ignore unused\n",
+			[]string{"2:5: warning: len shadows the builtin function of the same name [shadow-builtin]"},
+		},
+		{
+			"errors",
+			"let a = b; // lint:ignore\n",
+			[]string{"1:9: error: undefined variable: b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lintStrings(lint.New(), tt.src)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got\n%q\nexpected\n%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfiguration(t *testing.T) {
+	src := "let len = 1; let f = fn(x) { x }; f();"
+
+	l := lint.New()
+	if err := l.Disable("shadow-builtin"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"1:5: warning: len is declared but never used [unused]",
+		"1:35: warning: f takes 1 argument, but is called with 0 [arity]",
+	}
+	if got := lintStrings(l, src); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got\n%q\nexpected\n%q", got, expected)
+	}
+
+	if err := l.Enable("arity"); err != nil {
+		t.Fatal(err)
+	}
+	if got := lintStrings(l, src); !reflect.DeepEqual(got, expected[1:]) {
+		t.Errorf("got\n%q\nexpected\n%q", got, expected[1:])
+	}
+
+	if err := l.Enable("arity", "nonsense"); err == nil || err.Error() != `unknown rule "nonsense"` {
+		t.Errorf("Enable returned %v", err)
+	}
+}
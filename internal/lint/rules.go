@@ -0,0 +1,229 @@
+package lint
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/token"
+	"strings"
+)
+
+// bindings returns the values bound by let to every variable, keyed by the
+// identifier that declared it. Parameters are not included.
+func (p *pass) bindings() map[*ast.ID][]ast.Expression {
+	bindings := make(map[*ast.ID][]ast.Expression)
+	walk(p.program, func(node ast.Node) {
+		if let, ok := node.(*ast.LetStatement); ok {
+			if decl := p.resolver.Declaration(let.Name); decl != nil {
+				bindings[decl] = append(bindings[decl], let.Value)
+			}
+		}
+	})
+
+	return bindings
+}
+
+// value returns the only value ever bound to the variable fn refers to, if
+// fn is a variable that is bound once by let.
+func (p *pass) value(fn ast.Expression, bindings map[*ast.ID][]ast.Expression) (ast.Expression, bool) {
+	id, ok := fn.(*ast.ID)
+	if !ok {
+		return nil, false
+	}
+
+	values := bindings[p.resolver.Declaration(id)]
+	if len(values) != 1 {
+		return nil, false
+	}
+
+	return values[0], true
+}
+
+func checkArity(p *pass) {
+	bindings := p.bindings()
+
+	walk(p.program, func(node ast.Node) {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return
+		}
+
+		name := "the function"
+		fn, ok := call.Function.(*ast.FunctionLiteral)
+		if !ok {
+			value, ok := p.value(call.Function, bindings)
+			if fn, ok = value.(*ast.FunctionLiteral); !ok {
+				return
+			}
+			name = call.Function.(*ast.ID).Value
+		}
+
+		if len(call.Arguments) != len(fn.Params) {
+			p.reportf(calleePos(call), "%s takes %s, but is called with %d", name, arguments(len(fn.Params)), len(call.Arguments))
+		}
+	})
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+
+	return fmt.Sprintf("%d arguments", n)
+}
+
+func checkNotCallable(p *pass) {
+	bindings := p.bindings()
+
+	walk(p.program, func(node ast.Node) {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return
+		}
+
+		if typ := literalType(call.Function); typ != "" {
+			p.reportf(calleePos(call), "not a function: %s", typ)
+			return
+		}
+
+		if value, ok := p.value(call.Function, bindings); ok {
+			if typ := literalType(value); typ != "" {
+				p.reportf(calleePos(call), "%s is %s, not a function", call.Function.(*ast.ID).Value, typ)
+			}
+		}
+	})
+}
+
+// literalType returns the type of the value of a literal that is not a
+// function, or the empty string for other expressions.
+func literalType(e ast.Expression) string {
+	switch e.(type) {
+	case *ast.IntegerLiteral:
+		return "INTEGER"
+	case *ast.StringLiteral:
+		return "STRING"
+	case *ast.BooleanExpression:
+		return "BOOL"
+	case *ast.ArrayLiteral:
+		return "ARRAY"
+	case *ast.HashMapLiteral:
+		return "HASHMAP"
+	}
+
+	return ""
+}
+
+func checkShadowBuiltin(p *pass) {
+	walk(p.program, func(node ast.Node) {
+		var ids []*ast.ID
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			// Only the first let of a name declares it.
+			if p.resolver.Declaration(node.Name) == node.Name {
+				ids = append(ids, node.Name)
+			}
+		case *ast.FunctionLiteral:
+			ids = node.Params
+		}
+
+		for _, id := range ids {
+			if p.builtins[id.Value] {
+				p.reportf(id.Token.Pos, "%s shadows the builtin function of the same name", id.Value)
+			}
+		}
+	})
+}
+
+func checkUnreachable(p *pass) {
+	check := func(stmts []ast.Statement) {
+		for i, stmt := range stmts[:max(len(stmts)-1, 0)] {
+			if _, ok := stmt.(*ast.ReturnStatement); ok {
+				p.reportf(stmtPos(stmts[i+1]), "unreachable statement after return")
+				return
+			}
+		}
+	}
+
+	check(p.program.Statements)
+	walk(p.program, func(node ast.Node) {
+		if block, ok := node.(*ast.BlockStatement); ok {
+			check(block.Statements)
+		}
+	})
+}
+
+func checkUnused(p *pass) {
+	// Tests are used by the test runner.
+	tests := make(map[*ast.ID]bool)
+	for _, stmt := range p.program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && strings.HasPrefix(let.Name.Value, "test_") {
+			tests[let.Name] = true
+		}
+	}
+
+	defs := make(map[*ast.ID]bool)
+	var lets []*ast.ID
+	walk(p.program, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			defs[node.Name] = true
+			lets = append(lets, node.Name)
+		case *ast.FunctionLiteral:
+			for _, param := range node.Params {
+				defs[param] = true
+			}
+		}
+	})
+
+	used := make(map[*ast.ID]bool)
+	walk(p.program, func(node ast.Node) {
+		if id, ok := node.(*ast.ID); ok && !defs[id] {
+			used[p.resolver.Declaration(id)] = true
+		}
+	})
+
+	for _, id := range lets {
+		if p.resolver.Declaration(id) != id || used[id] || tests[id] || strings.HasPrefix(id.Value, "_") {
+			continue
+		}
+
+		p.reportf(id.Token.Pos, "%s is declared but never used", id.Value)
+	}
+}
+
+// calleePos returns where the function of call starts, if it is a name or
+// a literal, and where its arguments start otherwise.
+func calleePos(call *ast.CallExpression) token.Position {
+	switch fn := call.Function.(type) {
+	case *ast.ID:
+		return fn.Token.Pos
+	case *ast.FunctionLiteral:
+		return fn.Token.Pos
+	case *ast.IntegerLiteral:
+		return fn.Token.Pos
+	case *ast.StringLiteral:
+		return fn.Token.Pos
+	case *ast.BooleanExpression:
+		return fn.Token.Pos
+	case *ast.ArrayLiteral:
+		return fn.Token.Pos
+	case *ast.HashMapLiteral:
+		return fn.Token.Pos
+	}
+
+	return call.Token.Pos
+}
+
+func stmtPos(stmt ast.Statement) token.Position {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos
+	case *ast.BlockStatement:
+		return stmt.Token.Pos
+	}
+
+	return token.Position{}
+}
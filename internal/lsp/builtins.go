@@ -0,0 +1,67 @@
+package lsp
+
+// builtin describes a builtin function for hover and completion. Optional
+// parameters are in brackets and ... repeats the one before it.
+type builtin struct {
+	signature string
+	doc       string
+}
+
+var builtins = map[string]builtin{
+	"all":              {"all(arr, fn) -> BOOL", "Reports whether fn returns true for every element of arr."},
+	"any":              {"any(arr, fn) -> BOOL", "Reports whether fn returns true for some element of arr."},
+	"assert":           {"assert(cond, [message]) -> NULL", "Fails with an error unless cond is truthy."},
+	"assert_eq":        {"assert_eq(got, want, [message]) -> NULL", "Fails with an error unless got equals want, showing how arrays and hashes differ."},
+	"assert_error":     {"assert_error(fn, [substring]) -> STRING", "Calls fn and fails unless it results in an error containing substring, whose message it returns."},
+	"chars":            {"chars(s) -> ARRAY", "Returns the characters of s as one-rune strings."},
+	"concat":           {"concat(arr, ...) -> ARRAY", "Returns the elements of all its arguments in one array."},
+	"contains":         {"contains(s, sub) -> BOOL", "Reports whether sub occurs in s."},
+	"delete":           {"delete(hash, key) -> HASHMAP", "Returns a copy of hash without key."},
+	"delete_mut":       {"delete_mut(hash, key) -> HASHMAP", "Removes key from hash in place and returns it."},
+	"each":             {"each(coll, fn) -> NULL", "Calls fn with every element of an array, or every key and value of a hash."},
+	"ends_with":        {"ends_with(s, suffix) -> BOOL", "Reports whether s ends with suffix."},
+	"entries":          {"entries(hash) -> ARRAY", "Returns the [key, value] pairs of hash in insertion order."},
+	"filter":           {"filter(arr, fn) -> ARRAY", "Returns the elements of arr for which fn returns true."},
+	"find":             {"find(arr, fn) -> ANY", "Returns the first element of arr for which fn returns true, or null."},
+	"first":            {"first(arr) -> ANY", "Returns the first element of arr, or null if it is empty."},
+	"flatten":          {"flatten(arr, [depth]) -> ARRAY", "Splices nested arrays into arr, at most depth levels deep."},
+	"format":           {"format(fmt, ...) -> STRING", "Formats its arguments printf-style."},
+	"has":              {"has(hash, key) -> BOOL", "Reports whether hash contains key."},
+	"index_of":         {"index_of(s, sub) -> INTEGER", "Returns the rune index of the first sub in s, or -1."},
+	"join":             {"join(arr, sep) -> STRING", "Joins an array of strings with sep."},
+	"json_parse":       {"json_parse(s) -> ANY", "Decodes the JSON document s."},
+	"json_stringify":   {"json_stringify(value, [indent]) -> STRING", "Encodes value as JSON, indented if indent is given."},
+	"keys":             {"keys(hash) -> ARRAY", "Returns the keys of hash in insertion order."},
+	"last":             {"last(arr) -> ANY", "Returns the last element of arr, or null if it is empty."},
+	"len":              {"len(value) -> INTEGER", "Returns the length of a string in runes, or the size of an array or hash."},
+	"lower":            {"lower(s) -> STRING", "Returns s in lower case."},
+	"map":              {"map(arr, fn) -> ARRAY", "Returns the results of calling fn with every element of arr."},
+	"map_from_entries": {"map_from_entries(arr) -> HASHMAP", "Builds a hash from an array of [key, value] pairs."},
+	"merge":            {"merge(hash, ...) -> HASHMAP", "Returns a hash with the pairs of all its arguments, later ones winning."},
+	"pad_left":         {"pad_left(s, width, [fill]) -> STRING", "Pads s on the left to width runes."},
+	"pad_right":        {"pad_right(s, width, [fill]) -> STRING", "Pads s on the right to width runes."},
+	"print":            {"print(value, ...) -> NULL", "Prints its arguments without a newline."},
+	"push":             {"push(arr, value, ...) -> ARRAY", "Returns a copy of arr with the values appended."},
+	"push_mut":         {"push_mut(arr, value, ...) -> ARRAY", "Appends the values to arr in place and returns it."},
+	"puts":             {"puts(value, ...) -> NULL", "Prints each argument on its own line."},
+	"range":            {"range([start], end, [step]) -> ARRAY", "Returns the integers from start up to but not including end."},
+	"reduce":           {"reduce(arr, fn, [initial]) -> ANY", "Folds arr into one value with fn(acc, element)."},
+	"repeat":           {"repeat(s, n) -> STRING", "Returns s repeated n times."},
+	"replace":          {"replace(s, old, new, [n]) -> STRING", "Replaces the first n occurrences of old in s, or all of them."},
+	"rest":             {"rest(arr) -> ARRAY", "Returns arr without its first element, or null if it is empty."},
+	"reverse":          {"reverse(value) -> ANY", "Returns the elements of an array, or the runes of a string, in reverse order."},
+	"set":              {"set(hash, key, value) -> HASHMAP", "Returns a copy of hash with key set to value, or null if hash is not a hash."},
+	"set_mut":          {"set_mut(hash, key, value) -> HASHMAP", "Sets key to value in hash in place and returns it."},
+	"slice":            {"slice(arr, start, [end]) -> ARRAY", "Returns the elements of arr from start up to end."},
+	"sort":             {"sort(arr, [less]) -> ARRAY", "Returns arr sorted, by less(a, b) if it is given."},
+	"split":            {"split(s, sep) -> ARRAY", "Splits s around every sep."},
+	"starts_with":      {"starts_with(s, prefix) -> BOOL", "Reports whether s starts with prefix."},
+	"substr":           {"substr(s, start, [length]) -> STRING", "Returns length runes of s from start."},
+	"trim":             {"trim(s, [cutset]) -> STRING", "Removes leading and trailing white space, or runes in cutset, from s."},
+	"unique":           {"unique(arr) -> ARRAY", "Returns arr without repeated elements."},
+	"upper":            {"upper(s) -> STRING", "Returns s in upper case."},
+	"values":           {"values(hash) -> ARRAY", "Returns the values of hash in insertion order."},
+	"zip":              {"zip(arr, ...) -> ARRAY", "Pairs up the elements of its arguments, stopping at the shortest."},
+}
+
+var keywords = []string{"fn", "let", "if", "else", "return", "true", "false"}
@@ -0,0 +1,273 @@
+package lsp
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"monkey/internal/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// document is an open file together with what the server knows about it.
+// It is analysed again from scratch on every change.
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	program  *ast.Program
+	comments []token.Comment
+	resolver *resolver.Resolver
+	diags    []diagnostic.Diagnostic
+
+	ids   []*ast.ID            // every identifier, in source order
+	decls map[*ast.ID]ast.Node // declaring identifiers to their let statement or function
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{
+		uri:      uri,
+		text:     text,
+		lines:    strings.Split(text, "\n"),
+		resolver: resolver.New(eval.BuiltinNames()),
+		decls:    make(map[*ast.ID]ast.Node),
+	}
+
+	l := lexer.New(text)
+	p := parser.New(l)
+	d.program = p.ParseProgram()
+	d.comments = l.Comments()
+	d.diags = append(d.diags, p.Diagnostics()...)
+	d.diags = append(d.diags, d.resolver.Resolve(d.program)...)
+
+	inspect(d.program, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.ID:
+			d.ids = append(d.ids, node)
+		case *ast.LetStatement:
+			if node.Name != nil {
+				d.decls[node.Name] = node
+			}
+		case *ast.FunctionLiteral:
+			for _, param := range node.Params {
+				d.decls[param] = node
+			}
+		}
+	})
+
+	return d
+}
+
+// idAt returns the identifier under pos, if any.
+func (d *document) idAt(pos Position) *ast.ID {
+	at := d.fromLSP(pos)
+	for _, id := range d.ids {
+		start := id.Token.Pos
+		end := start.Column + utf8.RuneCountInString(id.Value)
+		if start.Line == at.Line && start.Column <= at.Column && at.Column <= end {
+			return id
+		}
+	}
+
+	return nil
+}
+
+// references returns the identifiers referring to the same variable as id,
+// declaration included.
+func (d *document) references(id *ast.ID) []*ast.ID {
+	decl := d.resolver.Declaration(id)
+	if decl == nil {
+		return nil
+	}
+
+	var refs []*ast.ID
+	for _, other := range d.ids {
+		if d.resolver.Declaration(other) == decl {
+			refs = append(refs, other)
+		}
+	}
+
+	return refs
+}
+
+// idRange returns the range covered by id.
+func (d *document) idRange(id *ast.ID) Range {
+	start := id.Token.Pos
+	end := token.Position{Line: start.Line, Column: start.Column + utf8.RuneCountInString(id.Value)}
+	return Range{Start: d.toLSP(start), End: d.toLSP(end)}
+}
+
+// wordRange returns the range of the identifier or the single character
+// starting at pos, for diagnostics that only know where they start.
+func (d *document) wordRange(pos token.Position) Range {
+	end := pos
+	end.Column++
+
+	if pos.Line >= 1 && pos.Line <= len(d.lines) {
+		runes := []rune(d.lines[pos.Line-1])
+		i := pos.Column - 1
+		for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+			i++
+		}
+
+		if i > pos.Column-1 {
+			end.Column = i + 1
+		}
+	}
+
+	return Range{Start: d.toLSP(pos), End: d.toLSP(end)}
+}
+
+// statementEnd returns the position just after the last character of a
+// statement that is followed by the one at next, skipping back over the
+// white space, semicolons and comments in between.
+func (d *document) statementEnd(next token.Position) token.Position {
+	line, col := next.Line, next.Column-1
+	for line >= 1 && line <= len(d.lines) {
+		runes := []rune(d.lines[line-1])
+		col = min(col, len(runes))
+
+		for _, c := range d.comments {
+			if c.Pos.Line == line && c.Pos.Column <= col {
+				col = c.Pos.Column - 1
+			}
+		}
+
+		for col > 0 && (unicode.IsSpace(runes[col-1]) || runes[col-1] == ';') {
+			col--
+		}
+
+		if col > 0 {
+			return token.Position{Line: line, Column: col + 1}
+		}
+
+		line--
+		if line >= 1 && line <= len(d.lines) {
+			col = len([]rune(d.lines[line-1]))
+		}
+	}
+
+	return token.Position{Line: 1, Column: 1}
+}
+
+func stmtPos(stmt ast.Statement) token.Position {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos
+	case *ast.BadStatement:
+		return stmt.Token.Pos
+	}
+
+	return token.Position{}
+}
+
+// toLSP converts a 1-based rune position into a 0-based one counting
+// UTF-16 code units, as the protocol wants.
+func (d *document) toLSP(pos token.Position) Position {
+	line := pos.Line - 1
+	if line < 0 || line >= len(d.lines) {
+		return Position{Line: max(line, 0)}
+	}
+
+	character := 0
+	for i, r := range []rune(d.lines[line]) {
+		if i >= pos.Column-1 {
+			break
+		}
+		character += utf16Len(r)
+	}
+
+	return Position{Line: line, Character: character}
+}
+
+// utf16Len returns the number of UTF-16 code units that encode r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
+
+// fromLSP converts a protocol position back into a 1-based rune position.
+func (d *document) fromLSP(pos Position) token.Position {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return token.Position{Line: pos.Line + 1, Column: pos.Character + 1}
+	}
+
+	column, units := 1, 0
+	for _, r := range d.lines[pos.Line] {
+		if units >= pos.Character {
+			break
+		}
+		units += utf16Len(r)
+		column++
+	}
+
+	return token.Position{Line: pos.Line + 1, Column: column}
+}
+
+// visible returns the variables in scope at pos: the top-level ones and
+// those of every function whose body contains pos.
+func (d *document) visible(pos token.Position) []*ast.ID {
+	var ids []*ast.ID
+	ids = appendLets(ids, d.program.Statements)
+
+	inspect(d.program, func(node ast.Node) {
+		fn, ok := node.(*ast.FunctionLiteral)
+		if !ok || fn.Body == nil || !fn.Body.Token.Pos.Before(pos) || !pos.Before(fn.Body.End) {
+			return
+		}
+
+		ids = append(ids, fn.Params...)
+		ids = appendLets(ids, fn.Body.Statements)
+	})
+
+	return ids
+}
+
+// appendLets appends the names bound by stmts, including those in the
+// blocks of if expressions, which share the scope of their function.
+func appendLets(ids []*ast.ID, stmts []ast.Statement) []*ast.ID {
+	for _, stmt := range stmts {
+		inspectScope(stmt, func(node ast.Node) {
+			if let, ok := node.(*ast.LetStatement); ok && let.Name != nil {
+				ids = append(ids, let.Name)
+			}
+		})
+	}
+
+	return ids
+}
+
+// inspect calls fn for node and all nodes below it, in source order.
+func inspect(node ast.Node, fn func(ast.Node)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			fn(n)
+		}
+		return true
+	})
+}
+
+// inspectScope is like inspect but does not enter function literals.
+func inspectScope(node ast.Node, fn func(ast.Node)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+
+		fn(n)
+		_, function := n.(*ast.FunctionLiteral)
+		return !function
+	})
+}
@@ -0,0 +1,385 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/internal/eval"
+	"monkey/internal/lsp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///test.mk"
+
+const source = `let add = fn(a, b) {
+    let sum = a + b;
+    sum
+};
+let total = add(1, 2);
+let name = "monkey"; // shown below
+puts(len(name), total);
+`
+
+type response struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// session runs a server on the given requests, followed by shutdown and
+// exit, and returns what it sent back.
+func session(t *testing.T, requests ...string) []response {
+	t.Helper()
+
+	var in bytes.Buffer
+	write := func(body string) {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	for _, req := range requests {
+		write(req)
+	}
+	write(`{"jsonrpc":"2.0","id":999,"method":"shutdown"}`)
+	write(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	var out bytes.Buffer
+	if err := lsp.NewServer(&in, &out).Run(); err != nil {
+		t.Fatalf("Run() returned %v", err)
+	}
+
+	var responses []response
+	r := bufio.NewReader(&out)
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading header: %v", err)
+		}
+
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			t.Fatalf("reading body: %v", err)
+		}
+
+		var resp response
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("decoding %s: %v", body, err)
+		}
+		responses = append(responses, resp)
+	}
+
+	return responses
+}
+
+func open(text string) string {
+	body, _ := json.Marshal(text)
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":%q,"version":1,"text":%s}}}`, uri, body)
+}
+
+func request(id int, method string, params string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`, id, method, params)
+}
+
+func at(line, character int) string {
+	return fmt.Sprintf(`{"textDocument":{"uri":%q},"position":{"line":%d,"character":%d}}`, uri, line, character)
+}
+
+func result(t *testing.T, responses []response, id int, v interface{}) {
+	t.Helper()
+
+	for _, resp := range responses {
+		if resp.ID == nil || *resp.ID != id {
+			continue
+		}
+
+		if resp.Error != nil {
+			t.Fatalf("request %d failed: %s", id, resp.Error.Message)
+		}
+
+		if err := json.Unmarshal(resp.Result, v); err != nil {
+			t.Fatalf("decoding result of request %d: %v", id, err)
+		}
+		return
+	}
+
+	t.Fatalf("no response to request %d", id)
+}
+
+type location struct {
+	Range struct {
+		Start struct{ Line, Character int }
+	}
+}
+
+func (l location) String() string {
+	return fmt.Sprintf("%d:%d", l.Range.Start.Line, l.Range.Start.Character)
+}
+
+func TestNavigation(t *testing.T) {
+	tests := []struct {
+		name       string
+		line, char int
+		definition string
+		references string
+	}{
+		{"parameter", 1, 14, "0:13", "[0:13 1:14]"},
+		{"local", 2, 5, "1:8", "[1:8 2:4]"},
+		{"global", 6, 20, "4:4", "[4:4 6:16]"},
+		{"function", 4, 13, "0:4", "[0:4 4:12]"},
+		{"builtin", 6, 1, "", "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := session(t,
+				open(source),
+				request(1, "textDocument/definition", at(tt.line, tt.char)),
+				request(2, "textDocument/references", fmt.Sprintf(`{"textDocument":{"uri":%q},"position":{"line":%d,"character":%d},"context":{"includeDeclaration":true}}`, uri, tt.line, tt.char)),
+			)
+
+			var definition *location
+			result(t, responses, 1, &definition)
+			got := ""
+			if definition != nil {
+				got = definition.String()
+			}
+			if got != tt.definition {
+				t.Errorf("definition is %q, expected %q", got, tt.definition)
+			}
+
+			var references []location
+			result(t, responses, 2, &references)
+			if got := fmt.Sprint(references); got != tt.references {
+				t.Errorf("references are %s, expected %s", got, tt.references)
+			}
+		})
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		name       string
+		line, char int
+		expected   string
+	}{
+		{"builtin", 6, 5, "len(value) -> INTEGER"},
+		{"string", 6, 10, "let name: STRING"},
+		{"function", 0, 5, "let add: fn(a, b)"},
+		{"call of a function", 4, 5, "let total: ANY"},
+		{"parameter", 1, 18, "parameter b of fn(a, b)"},
+		{"nothing", 3, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := session(t, open(source), request(1, "textDocument/hover", at(tt.line, tt.char)))
+
+			var hover *struct {
+				Contents struct{ Value string }
+			}
+			result(t, responses, 1, &hover)
+
+			if hover == nil {
+				if tt.expected != "" {
+					t.Fatalf("no hover, expected %q", tt.expected)
+				}
+				return
+			}
+
+			if !strings.Contains(hover.Contents.Value, tt.expected) || tt.expected == "" {
+				t.Errorf("hover is %q, expected it to contain %q", hover.Contents.Value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	responses := session(t, open("let x = 1;\nlet y = x + ;\nputs(z);\n"))
+
+	var params struct {
+		Diagnostics []struct {
+			Range struct {
+				Start, End struct{ Line, Character int }
+			}
+			Severity int
+			Message  string
+		}
+	}
+
+	for _, resp := range responses {
+		if resp.Method == "textDocument/publishDiagnostics" {
+			if err := json.Unmarshal(resp.Params, &params); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var got []string
+	for _, d := range params.Diagnostics {
+		got = append(got, fmt.Sprintf("%d:%d-%d:%d %d %s",
+			d.Range.Start.Line, d.Range.Start.Character, d.Range.End.Line, d.Range.End.Character, d.Severity, d.Message))
+	}
+
+	expected := []string{
+		"1:12-1:13 1 no prefix parse function for ; found",
+		"2:5-2:6 1 undefined variable: z",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("diagnostics are\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	responses := session(t, open(source), request(1, "textDocument/documentSymbol", `{"textDocument":{"uri":"file:///test.mk"}}`))
+
+	type symbol struct {
+		Name     string
+		Kind     int
+		Range    struct{ Start, End struct{ Line, Character int } }
+		Children []symbol
+	}
+
+	var symbols []symbol
+	result(t, responses, 1, &symbols)
+
+	var describe func(symbols []symbol) string
+	describe = func(symbols []symbol) string {
+		var parts []string
+		for _, s := range symbols {
+			part := fmt.Sprintf("%s(%d) %d:%d-%d:%d", s.Name, s.Kind,
+				s.Range.Start.Line, s.Range.Start.Character, s.Range.End.Line, s.Range.End.Character)
+			if len(s.Children) > 0 {
+				part += " {" + describe(s.Children) + "}"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	expected := "add(12) 0:0-3:1 {sum(13) 1:4-1:19}, total(13) 4:0-4:21, name(13) 5:0-5:19"
+	if got := describe(symbols); got != expected {
+		t.Errorf("symbols are\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		name       string
+		line, char int
+		present    []string
+		absent     []string
+	}{
+		{"inside function", 2, 4, []string{"a", "b", "sum", "add", "total", "fn", "let"}, nil},
+		{"top level", 6, 0, []string{"add", "total", "name", "return"}, []string{"a", "sum"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := session(t, open(source), request(1, "textDocument/completion", at(tt.line, tt.char)))
+
+			var items []struct {
+				Label  string
+				Detail string
+			}
+			result(t, responses, 1, &items)
+
+			labels := make(map[string]string)
+			for _, item := range items {
+				labels[item.Label] = item.Detail
+			}
+
+			for _, name := range tt.present {
+				if _, ok := labels[name]; !ok {
+					t.Errorf("%s is not offered", name)
+				}
+			}
+			for _, name := range tt.absent {
+				if _, ok := labels[name]; ok {
+					t.Errorf("%s is offered", name)
+				}
+			}
+
+			for _, name := range eval.BuiltinNames() {
+				if !strings.HasPrefix(labels[name], name+"(") {
+					t.Errorf("builtin %s is offered with signature %q", name, labels[name])
+				}
+			}
+		})
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	responses := session(t,
+		open("let a=1\nputs( a )"),
+		request(1, "textDocument/formatting", `{"textDocument":{"uri":"file:///test.mk"},"options":{"tabSize":4,"insertSpaces":true}}`),
+	)
+
+	var edits []struct {
+		Range   struct{ Start, End struct{ Line, Character int } }
+		NewText string
+	}
+	result(t, responses, 1, &edits)
+
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, expected 1", len(edits))
+	}
+
+	if edits[0].Range.End.Line != 1 || edits[0].Range.End.Character != 9 {
+		t.Errorf("edit ends at %+v, expected 1:9", edits[0].Range.End)
+	}
+
+	if expected := "let a = 1;\nputs(a);\n"; edits[0].NewText != expected {
+		t.Errorf("new text is %q, expected %q", edits[0].NewText, expected)
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	t.Run("unknown method", func(t *testing.T) {
+		responses := session(t, request(1, "workspace/symbol", `{}`))
+		for _, resp := range responses {
+			if resp.ID != nil && *resp.ID == 1 {
+				if resp.Error == nil || resp.Error.Code != -32601 {
+					t.Errorf("expected a method not found error, got %+v", resp.Error)
+				}
+				return
+			}
+		}
+		t.Error("no response")
+	})
+
+	t.Run("exit without shutdown", func(t *testing.T) {
+		body := `{"jsonrpc":"2.0","method":"exit"}`
+		in := strings.NewReader(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body))
+		if err := lsp.NewServer(in, io.Discard).Run(); err != lsp.ErrNoShutdown {
+			t.Errorf("Run() returned %v, expected %v", err, lsp.ErrNoShutdown)
+		}
+	})
+
+	t.Run("utf-16 positions", func(t *testing.T) {
+		// The emoji takes two UTF-16 code units, so x starts at character 18
+		// rather than 17.
+		responses := session(t,
+			open("let s = \"😀\"; let x = 1;\nx"),
+			request(1, "textDocument/definition", at(1, 0)),
+		)
+
+		var definition location
+		result(t, responses, 1, &definition)
+		if got := definition.String(); got != "0:18" {
+			t.Errorf("definition is at %s, expected 0:18", got)
+		}
+	})
+}
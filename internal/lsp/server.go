@@ -0,0 +1,516 @@
+// Package lsp implements a Language Server Protocol server for Monkey. It
+// speaks JSON-RPC over a pair of streams, usually standard input and output,
+// and keeps every open document analysed with the parser and the resolver.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
+	"monkey/internal/format"
+	"monkey/internal/token"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoShutdown is returned by Run when the client sends exit without asking
+// the server to shut down first.
+var ErrNoShutdown = errors.New("exit without shutdown")
+
+// Server handles one client. Requests are served one at a time in the order
+// they arrive.
+type Server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]*document
+
+	shutdown bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+// Run serves requests until the client sends exit or closes the input.
+func (s *Server) Run() error {
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.reply(nil, nil, &responseError{codeParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+
+		result, rpcErr := s.handle(&msg)
+		if msg.ID == nil {
+			continue
+		}
+
+		if err := s.reply(msg.ID, result, rpcErr); err != nil {
+			return err
+		}
+	}
+}
+
+// read returns the body of the next message.
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func (s *Server) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+
+	_, err = s.out.Write(body)
+	return err
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rpcErr *responseError) error {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+
+	if rpcErr == nil && result == nil {
+		// A successful response must carry a result, even a null one.
+		result = json.RawMessage("null")
+	}
+
+	return s.write(&message{ID: id, Result: result, Error: rpcErr})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return s.write(&message{Method: method, Params: body})
+}
+
+// handle dispatches msg and returns the result to send back if it is a
+// request. Notifications the server does not know are ignored.
+func (s *Server) handle(msg *message) (interface{}, *responseError) {
+	if s.shutdown {
+		return nil, &responseError{codeInvalidRequest, "server is shut down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, []Diagnostic{})
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+	case "textDocument/references":
+		var params ReferenceParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.references(params), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/documentSymbol":
+		var params DocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.symbols(params), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+	case "textDocument/formatting":
+		var params DocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.formatting(params), nil
+	}
+
+	if msg.ID == nil {
+		return nil, nil
+	}
+
+	return nil, &responseError{codeMethodNotFound, "method not found: " + msg.Method}
+}
+
+func decode(msg *message, params interface{}) *responseError {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+
+	return nil
+}
+
+func (s *Server) initialize() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           1, // full
+			"definitionProvider":         true,
+			"referencesProvider":         true,
+			"hoverProvider":              true,
+			"documentSymbolProvider":     true,
+			"completionProvider":         map[string]interface{}{},
+			"documentFormattingProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "monkey"},
+	}
+}
+
+// update analyses the new text of a document and publishes its diagnostics.
+func (s *Server) update(uri, text string) *responseError {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+
+	diags := make([]Diagnostic, len(doc.diags))
+	for i, d := range doc.diags {
+		severity := severityError
+		if d.Severity == diagnostic.Warning {
+			severity = severityWarning
+		}
+
+		diags[i] = Diagnostic{
+			Range:    doc.wordRange(d.Pos),
+			Severity: severity,
+			Source:   "monkey",
+			Message:  d.Message,
+		}
+	}
+
+	return s.publish(uri, diags)
+}
+
+func (s *Server) publish(uri string, diags []Diagnostic) *responseError {
+	params := PublishDiagnosticsParams{URI: uri, Diagnostics: diags}
+	if err := s.notify("textDocument/publishDiagnostics", params); err != nil {
+		return &responseError{codeInvalidRequest, err.Error()}
+	}
+
+	return nil
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) interface{} {
+	doc, id := s.idAt(params)
+	if id == nil {
+		return nil
+	}
+
+	decl := doc.resolver.Declaration(id)
+	if decl == nil {
+		return nil
+	}
+
+	return Location{URI: doc.uri, Range: doc.idRange(decl)}
+}
+
+func (s *Server) references(params ReferenceParams) interface{} {
+	doc, id := s.idAt(params.TextDocumentPositionParams)
+	if id == nil {
+		return []Location{}
+	}
+
+	decl := doc.resolver.Declaration(id)
+	locations := []Location{}
+	for _, ref := range doc.references(id) {
+		if ref == decl && !params.Context.IncludeDeclaration {
+			continue
+		}
+
+		locations = append(locations, Location{URI: doc.uri, Range: doc.idRange(ref)})
+	}
+
+	return locations
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) interface{} {
+	doc, id := s.idAt(params)
+	if id == nil {
+		return nil
+	}
+
+	var text string
+	if id.Scope == ast.Builtin {
+		b, ok := builtins[id.Value]
+		if !ok {
+			return nil
+		}
+		text = fmt.Sprintf("```monkey\n%s\n```\n%s", b.signature, b.doc)
+	} else {
+		decl := doc.resolver.Declaration(id)
+		if decl == nil {
+			return nil
+		}
+
+		switch node := doc.decls[decl].(type) {
+		case *ast.LetStatement:
+			text = fmt.Sprintf("```monkey\nlet %s: %s\n```", decl.Value, kindOf(node.Value))
+		case *ast.FunctionLiteral:
+			text = fmt.Sprintf("```monkey\nparameter %s of %s\n```", decl.Value, signature(node))
+		default:
+			return nil
+		}
+	}
+
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: text},
+		Range:    doc.idRange(id),
+	}
+}
+
+// kindOf guesses the type of the value expr evaluates to from its syntax,
+// or returns ANY.
+func kindOf(expr ast.Expression) string {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return "INTEGER"
+	case *ast.StringLiteral:
+		return "STRING"
+	case *ast.BooleanExpression:
+		return "BOOL"
+	case *ast.ArrayLiteral, *ast.SliceExpression:
+		return "ARRAY"
+	case *ast.HashMapLiteral:
+		return "HASHMAP"
+	case *ast.FunctionLiteral:
+		return signature(expr)
+	case *ast.PrefixExpression:
+		if expr.Operator == "!" {
+			return "BOOL"
+		}
+		return kindOf(expr.Right)
+	case *ast.InfixExpression:
+		switch expr.Operator {
+		case "==", "!=", "<", ">":
+			return "BOOL"
+		}
+
+		left, right := kindOf(expr.Left), kindOf(expr.Right)
+		if left == right && (left == "INTEGER" || left == "STRING" && expr.Operator == "+") {
+			return left
+		}
+	case *ast.CallExpression:
+		id, ok := expr.Function.(*ast.ID)
+		if !ok || id.Scope != ast.Builtin {
+			break
+		}
+
+		if b, ok := builtins[id.Value]; ok {
+			return b.signature[strings.LastIndex(b.signature, " ")+1:]
+		}
+	}
+
+	return "ANY"
+}
+
+func signature(fn *ast.FunctionLiteral) string {
+	params := make([]string, len(fn.Params))
+	for i, param := range fn.Params {
+		params[i] = param.Value
+	}
+
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+func (s *Server) symbols(params DocumentParams) interface{} {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return []DocumentSymbol{}
+	}
+
+	return doc.symbols(doc.program.Statements, lastPosition(doc.lines))
+}
+
+// symbols returns the let bindings among stmts, with those made in the
+// bodies of functions they bind as children. end is where the list of
+// statements ends.
+func (d *document) symbols(stmts []ast.Statement, end token.Position) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for i, stmt := range stmts {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			continue
+		}
+
+		next := end
+		if i+1 < len(stmts) {
+			next = stmtPos(stmts[i+1])
+		}
+
+		symbol := DocumentSymbol{
+			Name:           let.Name.Value,
+			Detail:         kindOf(let.Value),
+			Kind:           symbolVariable,
+			Range:          Range{Start: d.toLSP(let.Token.Pos), End: d.toLSP(d.statementEnd(next))},
+			SelectionRange: d.idRange(let.Name),
+		}
+
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			symbol.Kind = symbolFunction
+			if fn.Body != nil {
+				symbol.Children = d.symbols(fn.Body.Statements, fn.Body.End)
+			}
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
+
+func (s *Server) completion(params TextDocumentPositionParams) interface{} {
+	items := []CompletionItem{}
+
+	if doc, ok := s.docs[params.TextDocument.URI]; ok {
+		seen := make(map[string]bool)
+		for _, id := range doc.visible(doc.fromLSP(params.Position)) {
+			if seen[id.Value] {
+				continue
+			}
+			seen[id.Value] = true
+
+			item := CompletionItem{Label: id.Value, Kind: completionVariable}
+			if let, ok := doc.decls[id].(*ast.LetStatement); ok {
+				item.Detail = kindOf(let.Value)
+				if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+					item.Kind = completionFunction
+				}
+			}
+
+			items = append(items, item)
+		}
+	}
+
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		items = append(items, CompletionItem{Label: name, Kind: completionFunction, Detail: builtins[name].signature})
+	}
+
+	for _, keyword := range keywords {
+		items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+
+	return items
+}
+
+func (s *Server) formatting(params DocumentParams) interface{} {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	formatted, err := format.Source(doc.text)
+	if err != nil || formatted == doc.text {
+		return []TextEdit{}
+	}
+
+	end := doc.toLSP(lastPosition(doc.lines))
+	return []TextEdit{{Range: Range{End: end}, NewText: formatted}}
+}
+
+// lastPosition returns the position just after the last character of a
+// document split into lines.
+func lastPosition(lines []string) token.Position {
+	last := lines[len(lines)-1]
+	return token.Position{Line: len(lines), Column: len([]rune(last)) + 1}
+}
+
+func (s *Server) idAt(params TextDocumentPositionParams) (*document, *ast.ID) {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	return doc, doc.idAt(params.Position)
+}
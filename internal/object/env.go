@@ -1,12 +1,19 @@
 package object
 
+import "sort"
+
+// Environment binds names to values. Resolved code addresses bindings by
+// slot; unresolved code looks them up by name in store. Slot names are kept
+// so that lookups by name also see slot bindings.
 type Environment struct {
 	store map[string]Object
+	names []string
+	slots []Object
 	outer *Environment
 }
 
 func NewEnv() *Environment {
-	return &Environment{make(map[string]Object), nil}
+	return &Environment{store: make(map[string]Object)}
 }
 
 func NewEnclosedEnv(outer *Environment) *Environment {
@@ -15,15 +22,94 @@ func NewEnclosedEnv(outer *Environment) *Environment {
 	return env
 }
 
+// NewSlotEnv creates an environment with one empty slot per name.
+func NewSlotEnv(outer *Environment, names []string) *Environment {
+	env := NewEnclosedEnv(outer)
+	env.names = names
+	env.slots = make([]Object, len(names))
+	return env
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
-	val, ok := e.store[name]
-	if !ok && e.outer != nil {
-		val, ok = e.outer.Get(name)
+	if val, ok := e.store[name]; ok {
+		return val, ok
+	}
+
+	for i := len(e.names) - 1; i >= 0; i-- {
+		if e.names[i] == name && e.slots[i] != nil {
+			return e.slots[i], true
+		}
+	}
+
+	if e.outer != nil {
+		return e.outer.Get(name)
 	}
-	return val, ok
+
+	return nil, false
 }
 
 func (e *Environment) Set(name string, obj Object) Object {
+	for i := range e.names {
+		if e.names[i] == name {
+			e.slots[i] = obj
+			return obj
+		}
+	}
+
 	e.store[name] = obj
 	return obj
 }
+
+// GetAt returns the value in slot of the environment depth levels out.
+func (e *Environment) GetAt(depth, slot int) (Object, bool) {
+	for ; depth > 0 && e != nil; depth-- {
+		e = e.outer
+	}
+
+	if e == nil || slot >= len(e.slots) || e.slots[slot] == nil {
+		return nil, false
+	}
+
+	return e.slots[slot], true
+}
+
+// SetAt binds obj to slot, growing the slots if needed. Growing is how the
+// global environment of a REPL session gains slots for new declarations.
+func (e *Environment) SetAt(slot int, name string, obj Object) Object {
+	for len(e.slots) <= slot {
+		e.slots = append(e.slots, nil)
+		e.names = append(e.names, "")
+	}
+
+	if e.names[slot] == "" {
+		e.names[slot] = name
+	}
+	e.slots[slot] = obj
+	return obj
+}
+
+// Outer returns the environment e is enclosed in, or nil for the global one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Each calls fn with the bindings of e itself, not those of the
+// environments it is enclosed in: slots in order, then named bindings in
+// sorted order.
+func (e *Environment) Each(fn func(name string, val Object)) {
+	for i, val := range e.slots {
+		if val != nil {
+			fn(e.names[i], val)
+		}
+	}
+
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fn(name, e.store[name])
+	}
+}
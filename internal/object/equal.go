@@ -0,0 +1,111 @@
+package object
+
+// Equal reports whether a and b are structurally equal:
+//
+//   - integers, floats, booleans and strings are equal when their values
+//     are, and an integer equals a float when converted to a float it is
+//     the same float, as with the == operator;
+//   - null equals null;
+//   - arrays are equal when they have equal elements in the same order;
+//   - hash maps are equal when they have the same keys bound to equal
+//     values, whatever order the keys were inserted in;
+//   - functions, builtins and everything else are equal only to themselves.
+//
+// Otherwise objects of different types are never equal. Cyclic arrays and
+// hash maps are compared coinductively: a pair already being compared
+// further up is assumed equal, so the comparison always terminates.
+func Equal(a, b Object) bool {
+	return equal(a, b, nil)
+}
+
+type comparison struct {
+	a, b Object
+}
+
+func equal(a, b Object, seen map[comparison]bool) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+
+		return false
+	case *Float:
+		switch b := b.(type) {
+		case *Float:
+			return a.Value == b.Value
+		case *Integer:
+			return a.Value == float64(b.Value)
+		}
+
+		return false
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+
+		if seen, ok = enter(seen, a, b); !ok {
+			return true
+		}
+
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], seen) {
+				return false
+			}
+		}
+
+		return true
+	case *HashMap:
+		b, ok := b.(*HashMap)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+
+		if seen, ok = enter(seen, a, b); !ok {
+			return true
+		}
+
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !equal(pair.Value, other.Value, seen) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// enter records that a and b are being compared. It returns false if they
+// already were.
+func enter(seen map[comparison]bool, a, b Object) (map[comparison]bool, bool) {
+	if seen == nil {
+		seen = make(map[comparison]bool)
+	}
+
+	if seen[comparison{a, b}] {
+		return seen, false
+	}
+
+	seen[comparison{a, b}] = true
+	return seen, true
+}
@@ -1,6 +1,9 @@
 package object
 
-import "hash/fnv"
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
 
 func (o ObjectType) String() string {
 	switch o {
@@ -10,6 +13,8 @@ func (o ObjectType) String() string {
 		return "BOOL"
 	case T_NULL:
 		return "NULL"
+	case T_FUNCTION:
+		return "FUNCTION"
 	case T_RETURN_VALUE:
 		return "RETURN VALUE"
 	case T_ERROR:
@@ -18,6 +23,14 @@ func (o ObjectType) String() string {
 		return "STRING"
 	case T_BUILTIN:
 		return "BUILTIN"
+	case T_ARRAY:
+		return "ARRAY"
+	case T_HASHMAP:
+		return "HASHMAP"
+	case T_TAIL_CALL:
+		return "TAIL CALL"
+	case T_FLOAT:
+		return "FLOAT"
 	}
 
 	return "NONE"
@@ -49,3 +62,56 @@ func (s *String) HashKey() HashKey {
 	h.Write([]byte(s.Value))
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
+
+// HashKey combines the HashKeys of the elements. It must only be called on
+// arrays accepted by AsHashable.
+func (a *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	buf := make([]byte, 16)
+	for _, e := range a.Elements {
+		key := e.(Hashable).HashKey()
+		binary.LittleEndian.PutUint64(buf, uint64(key.Type))
+		binary.LittleEndian.PutUint64(buf[8:], key.Value)
+		h.Write(buf)
+	}
+
+	return HashKey{Type: a.Type(), Value: h.Sum64()}
+}
+
+func (i *Integer) KeyEquals(other Hashable) bool { return Equal(i, other) }
+func (b *Boolean) KeyEquals(other Hashable) bool { return Equal(b, other) }
+func (s *String) KeyEquals(other Hashable) bool  { return Equal(s, other) }
+func (a *Array) KeyEquals(other Hashable) bool   { return Equal(a, other) }
+
+// AsHashable returns obj as a hash map key. Arrays are hashable when all of
+// their elements are, recursively; an array that contains itself is not.
+// Arrays are returned as a snapshot, so mutating the original with push_mut
+// cannot change a key already stored in a map.
+func AsHashable(obj Object) (Hashable, bool) {
+	return asHashable(obj, nil)
+}
+
+func asHashable(obj Object, seen map[Object]bool) (Hashable, bool) {
+	if arr, ok := obj.(*Array); ok {
+		if seen[arr] {
+			return nil, false
+		}
+		seen = mark(seen, arr)
+		defer delete(seen, arr)
+
+		elements := make([]Object, len(arr.Elements))
+		for i, e := range arr.Elements {
+			key, ok := asHashable(e, seen)
+			if !ok {
+				return nil, false
+			}
+
+			elements[i] = key
+		}
+
+		return &Array{Elements: elements}, true
+	}
+
+	h, ok := obj.(Hashable)
+	return h, ok
+}
@@ -0,0 +1,97 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inspect renders arrays and hash maps, printing a container that contains
+// itself as [...] or {...} instead of recursing forever.
+func inspect(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen = mark(seen, obj)
+		defer delete(seen, obj)
+
+		elements := make([]string, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elements[i] = inspect(e, seen)
+		}
+
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *HashMap:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen = mark(seen, obj)
+		defer delete(seen, obj)
+
+		pairs := obj.Pairs()
+		out := make([]string, len(pairs))
+		for i, pair := range pairs {
+			out[i] = fmt.Sprintf("%s: %s", inspect(pair.Key, seen), inspect(pair.Value, seen))
+		}
+
+		return "{" + strings.Join(out, ", ") + "}"
+	}
+
+	return obj.Inspect()
+}
+
+func mark(seen map[Object]bool, obj Object) map[Object]bool {
+	if seen == nil {
+		seen = make(map[Object]bool)
+	}
+
+	seen[obj] = true
+	return seen
+}
+
+// InspectIndented renders obj like Inspect, but with every element of an
+// array and every pair of a hash map on a line of its own, indented by
+// four spaces per level of nesting.
+func InspectIndented(obj Object) string {
+	var b strings.Builder
+	inspectIndented(&b, obj, nil, "")
+	return b.String()
+}
+
+func inspectIndented(b *strings.Builder, obj Object, seen map[Object]bool, indent string) {
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] || len(obj.Elements) == 0 {
+			b.WriteString(inspect(obj, seen))
+			return
+		}
+		seen = mark(seen, obj)
+		defer delete(seen, obj)
+
+		b.WriteString("[\n")
+		for _, e := range obj.Elements {
+			b.WriteString(indent + "    ")
+			inspectIndented(b, e, seen, indent+"    ")
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "]")
+	case *HashMap:
+		if seen[obj] || obj.Len() == 0 {
+			b.WriteString(inspect(obj, seen))
+			return
+		}
+		seen = mark(seen, obj)
+		defer delete(seen, obj)
+
+		b.WriteString("{\n")
+		for _, pair := range obj.Pairs() {
+			b.WriteString(indent + "    " + inspect(pair.Key, seen) + ": ")
+			inspectIndented(b, pair.Value, seen, indent+"    ")
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "}")
+	default:
+		b.WriteString(obj.Inspect())
+	}
+}
@@ -7,6 +7,11 @@ type Object interface {
 	Inspect() string
 }
 
+// Hashable objects can be used as hash map keys. HashKey only narrows down
+// the candidates; KeyEquals decides whether two keys are the same, so
+// colliding HashKeys never make distinct keys overwrite each other.
 type Hashable interface {
+	Object
 	HashKey() HashKey
+	KeyEquals(other Hashable) bool
 }
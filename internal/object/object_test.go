@@ -24,3 +24,280 @@ func TestHashMapKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestHashMapOrder(t *testing.T) {
+	h := object.NewHashMap()
+	h.Set(&object.String{Value: "b"}, &object.Integer{Value: 1})
+	h.Set(&object.Integer{Value: 7}, &object.Integer{Value: 2})
+	h.Set(&object.String{Value: "a"}, &object.Integer{Value: 3})
+	h.Set(&object.String{Value: "b"}, &object.Integer{Value: 4})
+
+	expected := `{"b": 4, 7: 2, "a": 3}`
+	for i := 0; i < 10; i++ {
+		if h.Inspect() != expected {
+			t.Fatalf("wrong inspect output. got=%s, want=%s", h.Inspect(), expected)
+		}
+	}
+
+	if h.Len() != 3 {
+		t.Errorf("wrong length. got=%d, want=%d", h.Len(), 3)
+	}
+}
+
+// collidingKey hashes every value to the same HashKey.
+type collidingKey struct {
+	value string
+}
+
+func (c *collidingKey) Type() object.ObjectType { return object.T_STRING }
+func (c *collidingKey) Inspect() string         { return c.value }
+func (c *collidingKey) HashKey() object.HashKey {
+	return object.HashKey{Type: object.T_STRING, Value: 42}
+}
+func (c *collidingKey) KeyEquals(other object.Hashable) bool {
+	o, ok := other.(*collidingKey)
+	return ok && c.value == o.value
+}
+
+func TestHashMapCollisions(t *testing.T) {
+	h := object.NewHashMap()
+	h.Set(&collidingKey{"a"}, &object.Integer{Value: 1})
+	h.Set(&collidingKey{"b"}, &object.Integer{Value: 2})
+	h.Set(&collidingKey{"a"}, &object.Integer{Value: 3})
+
+	if h.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. got=%s", h.Inspect())
+	}
+
+	expected := map[string]int64{"a": 3, "b": 2}
+	for k, v := range expected {
+		pair, ok := h.Get(&collidingKey{k})
+		if !ok {
+			t.Errorf("no pair for key %q", k)
+			continue
+		}
+
+		if pair.Value.(*object.Integer).Value != v {
+			t.Errorf("wrong value for key %q. got=%s, want=%d", k, pair.Value.Inspect(), v)
+		}
+	}
+
+	if _, ok := h.Get(&collidingKey{"c"}); ok {
+		t.Errorf("found a pair for a key that was never set")
+	}
+}
+
+func TestCompositeHashKeys(t *testing.T) {
+	k1 := &object.Array{Elements: []object.Object{
+		&object.Integer{Value: 1},
+		&object.Array{Elements: []object.Object{&object.String{Value: "x"}}},
+	}}
+	k2 := &object.Array{Elements: []object.Object{
+		&object.Integer{Value: 1},
+		&object.Array{Elements: []object.Object{&object.String{Value: "x"}}},
+	}}
+	d := &object.Array{Elements: []object.Object{
+		&object.Integer{Value: 1},
+		&object.Array{Elements: []object.Object{&object.String{Value: "y"}}},
+	}}
+
+	if k1.HashKey() != k2.HashKey() || !k1.KeyEquals(k2) {
+		t.Errorf("arrays with same content are different keys")
+	}
+
+	if k1.KeyEquals(d) {
+		t.Errorf("arrays with different content are the same key")
+	}
+
+	unhashable := &object.Array{Elements: []object.Object{
+		&object.Array{Elements: []object.Object{&object.Null{}}},
+	}}
+	if _, ok := object.AsHashable(unhashable); ok {
+		t.Errorf("array with an unhashable element is hashable")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	one := &object.Integer{Value: 1}
+	str := &object.String{Value: "a"}
+
+	hm1 := object.NewHashMap()
+	hm1.Set(str, one)
+	hm1.Set(one, str)
+	hm2 := object.NewHashMap()
+	hm2.Set(&object.Integer{Value: 1}, &object.String{Value: "a"})
+	hm2.Set(&object.String{Value: "a"}, &object.Integer{Value: 1})
+
+	cyclic1 := &object.Array{}
+	cyclic1.Elements = []object.Object{one, cyclic1}
+	cyclic2 := &object.Array{}
+	cyclic2.Elements = []object.Object{one, cyclic2}
+	cyclic3 := &object.Array{}
+	cyclic3.Elements = []object.Object{str, cyclic3}
+
+	tests := []struct {
+		a, b     object.Object
+		expected bool
+	}{
+		{one, &object.Integer{Value: 1}, true},
+		{one, &object.Integer{Value: 2}, false},
+		{one, str, false},
+		{one, &object.Float{Value: 1}, true},
+		{&object.Float{Value: 1}, one, true},
+		{one, &object.Float{Value: 1.5}, false},
+		{&object.Array{Elements: []object.Object{one}}, &object.Array{Elements: []object.Object{&object.Float{Value: 1}}}, true},
+		{&object.Null{}, &object.Null{}, true},
+		{&object.Array{Elements: []object.Object{one, str}}, &object.Array{Elements: []object.Object{one, str}}, true},
+		{&object.Array{Elements: []object.Object{one, str}}, &object.Array{Elements: []object.Object{str, one}}, false},
+		{&object.Array{Elements: []object.Object{one}}, &object.Array{}, false},
+		{hm1, hm2, true},
+		{hm1, object.NewHashMap(), false},
+		{cyclic1, cyclic2, true},
+		{cyclic1, cyclic3, false},
+		{&object.Function{}, &object.Function{}, false},
+	}
+
+	for i, tt := range tests {
+		if got := object.Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - wrong result. got=%t, want=%t", i, got, tt.expected)
+		}
+	}
+}
+
+func TestPersistentHashMap(t *testing.T) {
+	h := object.NewHashMap()
+	reference := map[int64]int64{}
+	order := []int64{}
+
+	for i := int64(0); i < 2000; i++ {
+		k := (i * 7919) % 1500
+		if _, ok := reference[k]; !ok {
+			order = append(order, k)
+		}
+		reference[k] = i
+		h.Set(&object.Integer{Value: k}, &object.Integer{Value: i})
+	}
+
+	snapshot := h.Copy()
+
+	for k := int64(0); k < 1500; k += 3 {
+		h.Delete(&object.Integer{Value: k})
+		delete(reference, k)
+	}
+
+	if h.Len() != len(reference) {
+		t.Fatalf("wrong length. got=%d, want=%d", h.Len(), len(reference))
+	}
+
+	for k, v := range reference {
+		pair, ok := h.Get(&object.Integer{Value: k})
+		if !ok || pair.Value.(*object.Integer).Value != v {
+			t.Fatalf("wrong pair for %d. got=%v (%t), want=%d", k, pair.Value, ok, v)
+		}
+	}
+
+	pairs := h.Pairs()
+	i := 0
+	for _, k := range order {
+		if _, ok := reference[k]; !ok {
+			continue
+		}
+
+		if got := pairs[i].Key.(*object.Integer).Value; got != k {
+			t.Fatalf("pairs[%d] out of order. got=%d, want=%d", i, got, k)
+		}
+		i++
+	}
+
+	if snapshot.Len() != 1500 {
+		t.Errorf("deleting from a map changed its copy. got len=%d", snapshot.Len())
+	}
+
+	if _, ok := snapshot.Get(&object.Integer{Value: 3}); !ok {
+		t.Errorf("deleting from a map changed its copy")
+	}
+
+	// A key removed and inserted again moves to the end.
+	h.Set(&object.Integer{Value: 0}, &object.Integer{Value: 1})
+	pairs = h.Pairs()
+	if last := pairs[len(pairs)-1].Key.(*object.Integer).Value; last != 0 {
+		t.Errorf("reinserted key is not last. got=%d", last)
+	}
+}
+
+func TestPersistentHashMapCollisions(t *testing.T) {
+	base := object.NewHashMap().With(&collidingKey{"a"}, &object.Integer{Value: 1})
+	withB := base.With(&collidingKey{"b"}, &object.Integer{Value: 2})
+	withoutA := withB.Without(&collidingKey{"a"})
+
+	if base.Len() != 1 || withB.Len() != 2 || withoutA.Len() != 1 {
+		t.Fatalf("wrong lengths. got=%d, %d, %d", base.Len(), withB.Len(), withoutA.Len())
+	}
+
+	if _, ok := withoutA.Get(&collidingKey{"a"}); ok {
+		t.Errorf("removed key still present")
+	}
+
+	if _, ok := withB.Get(&collidingKey{"a"}); !ok {
+		t.Errorf("removing a key changed the map it was removed from")
+	}
+}
+
+func TestArrayPush(t *testing.T) {
+	a := (&object.Array{}).Push(&object.Integer{Value: 1})
+	b := a.Push(&object.Integer{Value: 2})
+	c := a.Push(&object.Integer{Value: 3})
+	d := b.Push(&object.Integer{Value: 4})
+
+	tests := map[*object.Array]string{
+		a: "[1]",
+		b: "[1, 2]",
+		c: "[1, 3]",
+		d: "[1, 2, 4]",
+	}
+
+	for arr, expected := range tests {
+		if arr.Inspect() != expected {
+			t.Errorf("wrong elements. got=%s, want=%s", arr.Inspect(), expected)
+		}
+	}
+}
+
+func TestCyclicInspect(t *testing.T) {
+	arr := &object.Array{}
+	arr.Elements = []object.Object{&object.Integer{Value: 1}, arr}
+
+	if arr.Inspect() != "[1, [...]]" {
+		t.Errorf("wrong inspect output. got=%s", arr.Inspect())
+	}
+
+	if _, ok := object.AsHashable(arr); ok {
+		t.Errorf("cyclic array is hashable")
+	}
+}
+
+func TestInspectIndented(t *testing.T) {
+	hash := object.NewHashMap()
+	hash.Set(&object.String{Value: "a"}, &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}})
+	hash.Set(&object.String{Value: "b"}, &object.Array{})
+
+	cyclic := &object.Array{}
+	cyclic.Elements = []object.Object{cyclic}
+
+	tests := []struct {
+		obj      object.Object
+		expected string
+	}{
+		{&object.Integer{Value: 1}, "1"},
+		{&object.Array{}, "[]"},
+		{object.NewHashMap(), "{}"},
+		{hash, "{\n    \"a\": [\n        1,\n    ],\n    \"b\": [],\n}"},
+		{cyclic, "[\n    [...],\n]"},
+	}
+
+	for _, tt := range tests {
+		if got := object.InspectIndented(tt.obj); got != tt.expected {
+			t.Errorf("InspectIndented(%s) is %q, expected %q", tt.obj.Inspect(), got, tt.expected)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"monkey/internal/ast"
+	"strconv"
 	"strings"
 )
 
@@ -19,6 +20,27 @@ func (i *Integer) Type() ObjectType {
 	return T_INTEGER
 }
 
+// Float has no literal syntax; floats come from builtins such as json_parse
+// and from arithmetic that involves them.
+type Float struct {
+	Value float64
+}
+
+// Inspect always shows a decimal point or an exponent, so floats with an
+// integral value can be told apart from integers.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.Trim(s, "-0123456789") == "" {
+		s += ".0"
+	}
+
+	return s
+}
+
+func (f *Float) Type() ObjectType {
+	return T_FLOAT
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -49,8 +71,10 @@ func (n *Null) Type() ObjectType {
 }
 
 type Function struct {
+	Name   string // see ast.FunctionLiteral
 	Params []*ast.ID
 	Body   *ast.BlockStatement
+	Locals []string
 	Env    *Environment
 }
 
@@ -85,6 +109,17 @@ func (rv *ReturnValue) Type() ObjectType {
 	return T_RETURN_VALUE
 }
 
+// TailCall is a call in tail position that has not been applied yet. The
+// evaluator hands it back to applyFunc instead of nesting another call, so
+// tail-recursive functions run in constant Go stack space.
+type TailCall struct {
+	Fn   Object
+	Args []Object
+}
+
+func (tc *TailCall) Type() ObjectType { return T_TAIL_CALL }
+func (tc *TailCall) Inspect() string  { return "tail call" }
+
 type BuiltinFunc func(args ...Object) Object
 
 type Builtin struct {
@@ -94,18 +129,21 @@ type Builtin struct {
 func (b *Builtin) Type() ObjectType { return T_BUILTIN }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
+// Array is persistent: Elements must never be modified in place, because it
+// may share its backing array with other arrays. Push appends without
+// copying when it can do so unnoticed by any other array.
 type Array struct {
 	Elements []Object
+	used     *int
 }
 
 func (a *Array) Type() ObjectType { return T_ARRAY }
-func (a *Array) Inspect() string {
-	elements := make([]string, len(a.Elements))
-	for i, e := range a.Elements {
-		elements[i] = e.Inspect()
-	}
+func (a *Array) Inspect() string  { return inspect(a, nil) }
 
-	return "[" + strings.Join(elements, ", ") + "]"
+// Push returns a new array with elems appended, leaving a unchanged.
+func (a *Array) Push(elems ...Object) *Array {
+	elements, used := appendShared(a.Elements, a.used, elems...)
+	return &Array{Elements: elements, used: used}
 }
 
 type HashPair struct {
@@ -113,19 +151,124 @@ type HashPair struct {
 	Value Object
 }
 
+// HashMap is a persistent map that remembers the order its keys were first
+// inserted in. The pairs live in a hash array mapped trie, so With and
+// Without copy only the path to the key that changes; keys whose HashKeys
+// collide share a leaf and are told apart with KeyEquals. order lists the
+// keys by insertion and may hold stale entries for keys that were removed
+// since, which Pairs skips.
 type HashMap struct {
-	Pairs map[HashKey]HashPair
+	root  *hamtNode
+	size  int
+	seq   int
+	order []orderEntry
+	used  *int
+}
+
+type orderEntry struct {
+	key Hashable
+	seq int
+}
+
+func NewHashMap() *HashMap {
+	return &HashMap{root: &hamtNode{}}
 }
 
 func (h *HashMap) Type() ObjectType { return T_HASHMAP }
-func (h *HashMap) Inspect() string {
-	pairs := make([]string, len(h.Pairs))
-	i := 0
-	for _, pair := range h.Pairs {
-		pairs[i] = fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect())
-		i++
+func (h *HashMap) Inspect() string  { return inspect(h, nil) }
+
+func (h *HashMap) Get(key Hashable) (HashPair, bool) {
+	e, ok := h.root.get(hashOf(key.HashKey()), 0, key)
+	if !ok {
+		return HashPair{}, false
+	}
+
+	return HashPair{Key: e.key, Value: e.value}, true
+}
+
+// With returns a new map with key bound to value, leaving h unchanged. A key
+// that is already present keeps its place in the iteration order.
+func (h *HashMap) With(key Hashable, value Object) *HashMap {
+	c := *h
+
+	root, added := h.root.set(hashOf(key.HashKey()), 0, hamtEntry{key: key, value: value, seq: h.seq})
+	c.root = root
+
+	if added {
+		c.size++
+		c.seq++
+		c.order, c.used = appendShared(h.order, h.used, orderEntry{key: key, seq: h.seq})
+	}
+
+	return &c
+}
+
+// Without returns a new map without key, leaving h unchanged.
+func (h *HashMap) Without(key Hashable) *HashMap {
+	root, removed := h.root.remove(hashOf(key.HashKey()), 0, key)
+	if !removed {
+		return h.Copy()
+	}
+
+	if root == nil {
+		root = &hamtNode{}
+	}
+
+	c := *h
+	c.root = root
+	c.size--
+
+	if stale := len(c.order) - c.size; stale > 32 && stale > c.size {
+		c.compact()
+	}
+
+	return &c
+}
+
+// Set binds key to value in h itself. Every other map, including ones made
+// from h with With, is unaffected.
+func (h *HashMap) Set(key Hashable, value Object) {
+	*h = *h.With(key, value)
+}
+
+// Delete removes key from h itself.
+func (h *HashMap) Delete(key Hashable) {
+	*h = *h.Without(key)
+}
+
+func (h *HashMap) Len() int { return h.size }
+
+// Pairs returns the pairs in insertion order.
+func (h *HashMap) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, h.size)
+	for _, o := range h.order {
+		e, ok := h.root.get(hashOf(o.key.HashKey()), 0, o.key)
+		if ok && e.seq == o.seq {
+			pairs = append(pairs, HashPair{Key: e.key, Value: e.value})
+		}
+	}
+
+	return pairs
+}
+
+// Copy returns a map with the same pairs as h. It is cheap, since nothing
+// is copied until one of the two maps changes.
+func (h *HashMap) Copy() *HashMap {
+	c := *h
+	return &c
+}
+
+func (h *HashMap) compact() {
+	order := make([]orderEntry, 0, h.size)
+	for _, o := range h.order {
+		e, ok := h.root.get(hashOf(o.key.HashKey()), 0, o.key)
+		if ok && e.seq == o.seq {
+			order = append(order, o)
+		}
 	}
-	return "{" + strings.Join(pairs, ", ") + "}"
+
+	n := len(order)
+	h.order, h.used = order, &n
 }
 
 type Error struct {
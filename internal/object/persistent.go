@@ -0,0 +1,221 @@
+package object
+
+// This file holds the persistent data structures behind Array and HashMap.
+// Updates never modify storage another value can observe; they copy the
+// parts that change and share the rest.
+
+// appendShared appends items to s without disturbing any other slice that
+// shares its backing array. used points to the number of backing elements
+// claimed so far: only the slice that ends exactly there may grow in place,
+// every other one is copied first. It returns the new slice and the counter
+// for its backing array.
+func appendShared[T any](s []T, used *int, items ...T) ([]T, *int) {
+	if used != nil && *used == len(s) && cap(s)-len(s) >= len(items) {
+		s = append(s, items...)
+		*used = len(s)
+		return s, used
+	}
+
+	grown := make([]T, len(s), 2*(len(s)+len(items)))
+	copy(grown, s)
+	grown = append(grown, items...)
+
+	n := len(grown)
+	return grown, &n
+}
+
+const (
+	hamtBits  = 5
+	hamtWidth = 1 << hamtBits
+	hamtMask  = hamtWidth - 1
+)
+
+// hamtEntry is a key bound in the trie. seq records when the key was
+// inserted, which is what orders the pairs of a HashMap.
+type hamtEntry struct {
+	key   Hashable
+	value Object
+	seq   int
+}
+
+// hamtLeaf holds all entries whose hashes are identical.
+type hamtLeaf struct {
+	hash    uint64
+	entries []hamtEntry
+}
+
+// hamtNode is a bitmap-indexed node of a hash array mapped trie. children
+// holds a *hamtNode or a *hamtLeaf for every bit set in bitmap.
+type hamtNode struct {
+	bitmap   uint32
+	children []interface{}
+}
+
+func hashOf(key HashKey) uint64 {
+	return key.Value ^ uint64(key.Type)*0x9e3779b97f4a7c15
+}
+
+func bitpos(hash uint64, shift uint) uint32 {
+	return 1 << ((hash >> shift) & hamtMask)
+}
+
+func (n *hamtNode) index(bit uint32) int {
+	return popcount(n.bitmap & (bit - 1))
+}
+
+func popcount(x uint32) int {
+	count := 0
+	for ; x != 0; x &= x - 1 {
+		count++
+	}
+
+	return count
+}
+
+func (n *hamtNode) get(hash uint64, shift uint, key Hashable) (hamtEntry, bool) {
+	for {
+		bit := bitpos(hash, shift)
+		if n.bitmap&bit == 0 {
+			return hamtEntry{}, false
+		}
+
+		switch child := n.children[n.index(bit)].(type) {
+		case *hamtNode:
+			n = child
+			shift += hamtBits
+		case *hamtLeaf:
+			if child.hash != hash {
+				return hamtEntry{}, false
+			}
+
+			for _, e := range child.entries {
+				if key.KeyEquals(e.key) {
+					return e, true
+				}
+			}
+
+			return hamtEntry{}, false
+		}
+	}
+}
+
+// set returns a copy of n with entry bound, and whether its key is new.
+// An existing key keeps its seq.
+func (n *hamtNode) set(hash uint64, shift uint, entry hamtEntry) (*hamtNode, bool) {
+	bit := bitpos(hash, shift)
+	i := n.index(bit)
+
+	if n.bitmap&bit == 0 {
+		c := &hamtNode{bitmap: n.bitmap | bit, children: make([]interface{}, len(n.children)+1)}
+		copy(c.children, n.children[:i])
+		c.children[i] = &hamtLeaf{hash: hash, entries: []hamtEntry{entry}}
+		copy(c.children[i+1:], n.children[i:])
+		return c, true
+	}
+
+	var child interface{}
+	added := false
+
+	switch old := n.children[i].(type) {
+	case *hamtNode:
+		child, added = old.set(hash, shift+hamtBits, entry)
+	case *hamtLeaf:
+		if old.hash == hash {
+			child, added = old.set(entry)
+			break
+		}
+
+		sub := &hamtNode{bitmap: bitpos(old.hash, shift+hamtBits), children: []interface{}{old}}
+		child, added = sub.set(hash, shift+hamtBits, entry)
+	}
+
+	c := &hamtNode{bitmap: n.bitmap, children: make([]interface{}, len(n.children))}
+	copy(c.children, n.children)
+	c.children[i] = child
+	return c, added
+}
+
+func (l *hamtLeaf) set(entry hamtEntry) (*hamtLeaf, bool) {
+	entries := make([]hamtEntry, len(l.entries), len(l.entries)+1)
+	copy(entries, l.entries)
+
+	for i, e := range entries {
+		if entry.key.KeyEquals(e.key) {
+			entry.seq = e.seq
+			entries[i] = entry
+			return &hamtLeaf{hash: l.hash, entries: entries}, false
+		}
+	}
+
+	return &hamtLeaf{hash: l.hash, entries: append(entries, entry)}, true
+}
+
+// remove returns a copy of n without key, or nil if nothing is left. The
+// bool reports whether key was present.
+func (n *hamtNode) remove(hash uint64, shift uint, key Hashable) (*hamtNode, bool) {
+	bit := bitpos(hash, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	i := n.index(bit)
+	var child interface{}
+
+	switch old := n.children[i].(type) {
+	case *hamtNode:
+		sub, removed := old.remove(hash, shift+hamtBits, key)
+		if !removed {
+			return n, false
+		}
+
+		if sub != nil {
+			child = sub
+		}
+	case *hamtLeaf:
+		if old.hash != hash {
+			return n, false
+		}
+
+		leaf, removed := old.remove(key)
+		if !removed {
+			return n, false
+		}
+
+		if leaf != nil {
+			child = leaf
+		}
+	}
+
+	if child != nil {
+		c := &hamtNode{bitmap: n.bitmap, children: make([]interface{}, len(n.children))}
+		copy(c.children, n.children)
+		c.children[i] = child
+		return c, true
+	}
+
+	if len(n.children) == 1 {
+		return nil, true
+	}
+
+	c := &hamtNode{bitmap: n.bitmap &^ bit, children: make([]interface{}, 0, len(n.children)-1)}
+	c.children = append(c.children, n.children[:i]...)
+	c.children = append(c.children, n.children[i+1:]...)
+	return c, true
+}
+
+func (l *hamtLeaf) remove(key Hashable) (*hamtLeaf, bool) {
+	for i, e := range l.entries {
+		if key.KeyEquals(e.key) {
+			if len(l.entries) == 1 {
+				return nil, true
+			}
+
+			entries := make([]hamtEntry, 0, len(l.entries)-1)
+			entries = append(entries, l.entries[:i]...)
+			entries = append(entries, l.entries[i+1:]...)
+			return &hamtLeaf{hash: l.hash, entries: entries}, true
+		}
+	}
+
+	return l, false
+}
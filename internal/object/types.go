@@ -12,4 +12,6 @@ const (
 	T_BUILTIN
 	T_ARRAY
 	T_HASHMAP
+	T_TAIL_CALL
+	T_FLOAT
 )
@@ -0,0 +1,118 @@
+// Package optimize rewrites Monkey programs into programs that do the same
+// with less work at runtime. Every transformation is a pass that can be
+// turned off:
+//
+//	inline         calls of small functions are replaced by their bodies
+//	fold           operators applied to literals are replaced by the result
+//	dead-branches  ifs with a literal condition are replaced by their branch
+//	unused-lets    lets of literals and functions that are never used go
+//
+// Programs do the same after the passes as before, with the same output and
+// the same errors, but tracers see fewer nodes and calls. The passes change
+// the tree in place and resolve it as they go, but not after the last
+// change: it has to be resolved again before it is run. Top-level bindings
+// may disappear, so a program whose bindings are read by name afterwards,
+// like a test file, should not be optimized.
+package optimize
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/eval"
+	"monkey/internal/resolver"
+)
+
+// Pass is a transformation of programs.
+type Pass struct {
+	Name string
+	Doc  string
+
+	run func(program *ast.Program, r *resolver.Resolver)
+}
+
+// Passes are all passes, in the order they run. Inlining comes first so
+// that folding sees the bodies of the inlined functions, and removing lets
+// last so that it sees the uses the other passes removed.
+var Passes = []*Pass{
+	{Name: "inline", Doc: "replace calls of functions that only combine their parameters with the function's body", run: inline},
+	{Name: "fold", Doc: "compute operators applied to literals", run: fold},
+	{Name: "dead-branches", Doc: "replace ifs whose condition is a literal by the branch they take", run: removeDeadBranches},
+	{Name: "unused-lets", Doc: "remove lets of literals and functions that are never used", run: removeUnusedLets},
+}
+
+// Optimizer optimizes programs with a set of passes.
+type Optimizer struct {
+	enabled map[string]bool
+}
+
+// New returns an optimizer with all passes enabled.
+func New() *Optimizer {
+	o := &Optimizer{enabled: make(map[string]bool)}
+	for _, p := range Passes {
+		o.enabled[p.Name] = true
+	}
+
+	return o
+}
+
+// Enable enables exactly the passes with the given names.
+func (o *Optimizer) Enable(names ...string) error {
+	if err := checkNames(names); err != nil {
+		return err
+	}
+
+	o.enabled = make(map[string]bool)
+	for _, name := range names {
+		o.enabled[name] = true
+	}
+
+	return nil
+}
+
+// Disable disables the passes with the given names.
+func (o *Optimizer) Disable(names ...string) error {
+	if err := checkNames(names); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		delete(o.enabled, name)
+	}
+
+	return nil
+}
+
+func checkNames(names []string) error {
+	for _, name := range names {
+		if findPass(name) == nil {
+			return fmt.Errorf("unknown pass %q", name)
+		}
+	}
+
+	return nil
+}
+
+func findPass(name string) *Pass {
+	for _, p := range Passes {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// Optimize runs the enabled passes over program. The program should have
+// no syntax errors.
+func (o *Optimizer) Optimize(program *ast.Program) {
+	for _, p := range Passes {
+		if !o.enabled[p.Name] {
+			continue
+		}
+
+		// Every pass sees the program as the previous one left it.
+		r := resolver.New(eval.BuiltinNames())
+		r.Resolve(program)
+		p.run(program, r)
+	}
+}
@@ -0,0 +1,184 @@
+package optimize_test
+
+import (
+	"bytes"
+	"monkey/internal/ast"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/optimize"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"testing"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if diags := p.Diagnostics(); len(diags) > 0 {
+		t.Fatalf("syntax errors in %q: %v", src, diags)
+	}
+
+	return program
+}
+
+func optimized(t *testing.T, src string, passes ...string) *ast.Program {
+	t.Helper()
+
+	program := parse(t, src)
+	o := optimize.New()
+	if err := o.Enable(passes...); err != nil {
+		t.Fatal(err)
+	}
+	o.Optimize(program)
+
+	return program
+}
+
+func TestPasses(t *testing.T) {
+	tests := []struct {
+		pass     string
+		input    string
+		expected string
+	}{
+		{"fold", "(10 / 2) * 5 + 30", "55"},
+		{"fold", `"a" + "b" == "ab"; !true; -(-3); 1 < 2 == true`, "true;false;3;true"},
+		{"fold", `x + 1 * 2; "a" - "b"; 1 + "a"; 1 / 0; 7 / 2`, `(x + 2);("a" - "b");(1 + "a");(1 / 0);3`},
+		{"fold", "fn(x) { x * (2 + 3) }", "fn(x){ (x * 5) }"},
+
+		{"dead-branches", "if (true) { 1 } else { 2 }; if (false) { 1 } else { 2 }", "1;2"},
+		{"dead-branches", `if (0) { "zero is true" }; if ("") { "so is the empty string" }`, `"zero is true";"so is the empty string"`},
+		{"dead-branches", "if (true) { let x = 1; x }; x", "let x = 1;x;x"},
+		{"dead-branches", "if (false) { 1 }; 2", "2"},
+		{"dead-branches", "let f = fn() { 1; if (false) { 2 } }", "let f = fn(){ 1;if (false) { 2 } };"},
+		{"dead-branches", "if (x) { 1 } else { 2 }", "if (x) { 1 }else{ 2 }"},
+
+		{"inline", "let double = fn(x) { x * 2 }; double(3); double(y)", "let double = fn(x){ (x * 2) };(3 * 2);double(y)"},
+		{"inline", "let sub = fn(a, b) { a - b }; fn(n) { sub(n, 1) }", "let sub = fn(a, b){ (a - b) };fn(n){ (n - 1) }"},
+		{"inline", "let answer = fn() { 42 }; answer() + answer()", "let answer = fn(){ 42 };(42 + 42)"},
+		{"inline", "let f = fn(x) { x }; f(len)", "let f = fn(x){ x };len"},
+		// Parameters used twice, out of order or not at all.
+		{"inline", "let sq = fn(x) { x * x }; sq(2)", "let sq = fn(x){ (x * x) };sq(2)"},
+		{"inline", "let sub = fn(a, b) { b - a }; sub(1, 2)", "let sub = fn(a, b){ (b - a) };sub(1, 2)"},
+		{"inline", "let k = fn(a, b) { a }; k(1, 2)", "let k = fn(a, b){ a };k(1, 2)"},
+		// Bodies and arguments that do more.
+		{"inline", "let g = fn(x) { f(x) }; g(1)", "let g = fn(x){ f(x) };g(1)"},
+		{"inline", "let y = 1; let add = fn(x) { x + y }; add(1)", "let y = 1;let add = fn(x){ (x + y) };add(1)"},
+		{"inline", `let double = fn(x) { x * 2 }; double(puts("hi"))`, `let double = fn(x){ (x * 2) };double(puts("hi"))`},
+		{"inline", "let double = fn(x) { x * 2 }; double(1, 2)", "let double = fn(x){ (x * 2) };double(1, 2)"},
+		// Calls that might run before the let.
+		{"inline", "double(1); let double = fn(x) { x * 2 }", "double(1);let double = fn(x){ (x * 2) };"},
+		{"inline", "if (c) { let one = fn() { 1 } }; one()", "if (c) { let one = fn(){ 1 }; };one()"},
+		{"inline", "let one = fn() { 1 }; let one = fn() { 2 }; one()", "let one = fn(){ 1 };let one = fn(){ 2 };one()"},
+
+		{"unused-lets", "let x = 1; let y = 2; y", "let y = 2;y"},
+		{"unused-lets", "let f = fn() { g() }; let g = fn() { 1 }; 0", "0"},
+		{"unused-lets", "let f = fn() { let a = [1, 2]; 3 }; f()", "let f = fn(){ 3 };f()"},
+		{"unused-lets", "let x = f(); let y = x + 1; let z = 1", "let x = f();let y = (x + 1);let z = 1;"},
+		{"unused-lets", "let rec = fn() { rec() }; 0", "let rec = fn(){ rec() };0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pass+"/"+tt.input, func(t *testing.T) {
+			if got := optimized(t, tt.input, tt.pass).String(); got != tt.expected {
+				t.Errorf("got %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAllPasses(t *testing.T) {
+	program := optimized(t, `let double = fn(x) { x * 2 };
+		let unused = "debugging";
+		if (false) { puts(unused) };
+		puts(double(21), (10 / 2) * 5 + 30)`,
+		"inline", "fold", "dead-branches", "unused-lets")
+
+	if got, expected := program.String(), "puts(42, 55)"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestNames(t *testing.T) {
+	o := optimize.New()
+	if err := o.Enable("fold", "loop-unrolling"); err == nil || err.Error() != `unknown pass "loop-unrolling"` {
+		t.Errorf("Enable returned %v", err)
+	}
+	if err := o.Disable("inlining"); err == nil || err.Error() != `unknown pass "inlining"` {
+		t.Errorf("Disable returned %v", err)
+	}
+}
+
+// programs are run with and without optimizing them, and have to print and
+// return the same.
+var programs = []string{
+	"(10 / 2) * 5 + 30",
+	`puts("a" + "b", 1 < 2, !5, -(-3), "b" > "a", 9223372036854775807 + 1)`,
+	`1 + "a"`,
+	`puts(1); 1 / 0 == 1`,
+	`-"a"`,
+	`let x = 1; if (true) { let x = 2; puts(x) }; x`,
+	`if (false) { 1 }`,
+	`let f = fn() { if (false) { 1 } }; f()`,
+	`let f = fn() { if (true) { return 1; 2 }; 3 }; f()`,
+	`if (true) { return 5 }; puts("not reached")`,
+	`let double = fn(x) { x * 2 }; let f = fn(n) { double(n) + double(1) }; f(4)`,
+	`let neg = fn(x) { -x }; neg("a")`,
+	`let neg = fn(x) { -x }; let f = fn(s) { neg(s) }; f("a")`,
+	`let k = fn(a, b) { a }; k(1, undefined)`,
+	`let first = fn(a, b) { a }; let f = fn(x) { first(x, 1) }; f(puts("once"))`,
+	`let id = fn(x) { x }; id(len)("abc")`,
+	`let twice = fn(f, x) { f(f(x)) }; let inc = fn(x) { x + 1 }; twice(inc, 1)`,
+	`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`,
+	`let unused = fn() { puts("never") }; let items = [1, 2, 3]; map([1, 2], fn(x) { x * 10 })`,
+	`let side = puts("effect"); 1`,
+	`let f = fn() { let x = 1 }; f()`,
+	`let f = fn() { let a = 1; let b = 2; b }; f()`,
+	`let h = {"k": 1 + 1}; h["k"] * 2`,
+	`let compose = fn(f, g) { fn(x) { f(g(x)) } }; let add1 = fn(x) { x + 1 }; compose(add1, add1)(1)`,
+	`let x = if (1 > 2) { "yes" } else { "no" }; x + "!"`,
+	`let s = fn(a, b) { a - b }; s(10, 3) * s(2, 5)`,
+	`let pow = fn(base, exp) { if (exp == 0) { 1 } else { base * pow(base, exp - 1) } }; pow(2, 10)`,
+	`let a = [1, 2, 3]; let b = a[0:2]; len(b) + 0`,
+	`let msg = "hello"; let shout = fn(s) { upper(s) + "!" }; shout(msg)`,
+}
+
+func run(t *testing.T, program *ast.Program) string {
+	t.Helper()
+
+	resolver.New(eval.BuiltinNames()).Resolve(program)
+
+	var out bytes.Buffer
+	defer eval.SetOutput(eval.SetOutput(&out))
+
+	result := "<nil>"
+	if obj := eval.Eval(program, object.NewEnv()); obj != nil {
+		result = obj.Inspect()
+	}
+
+	return out.String() + "=> " + result
+}
+
+func TestDifferential(t *testing.T) {
+	configs := [][]string{{"inline"}, {"fold"}, {"dead-branches"}, {"unused-lets"}}
+	var all []string
+	for _, p := range optimize.Passes {
+		all = append(all, p.Name)
+	}
+	configs = append(configs, all)
+
+	for _, src := range programs {
+		t.Run(src, func(t *testing.T) {
+			expected := run(t, parse(t, src))
+
+			for _, passes := range configs {
+				program := optimized(t, src, passes...)
+				if got := run(t, program); got != expected {
+					t.Errorf("with %v, %s\ngave %q, expected %q", passes, program, got, expected)
+				}
+			}
+		})
+	}
+}
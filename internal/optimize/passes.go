@@ -0,0 +1,365 @@
+package optimize
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/eval"
+	"monkey/internal/object"
+	"monkey/internal/resolver"
+	"monkey/internal/token"
+	"strconv"
+)
+
+// fold replaces prefix and infix expressions whose operands are literals by
+// a literal of their value. The evaluator computes the value, so it is the
+// one the program would compute. Expressions that fail, like 1 + "a" or
+// 1 / 0, stay for the program to fail on them.
+func fold(program *ast.Program, _ *resolver.Resolver) {
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		switch n := node.(type) {
+		case *ast.PrefixExpression:
+			if literal(n.Right) {
+				return evaluate(n, n.Token.Pos)
+			}
+		case *ast.InfixExpression:
+			if literal(n.Left) && literal(n.Right) {
+				return evaluate(n, ast.TokenOf(n.Left).Pos)
+			}
+		}
+
+		return node
+	})
+}
+
+// literal reports whether e is an integer, string or boolean literal.
+func literal(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.BooleanExpression:
+		return true
+	}
+
+	return false
+}
+
+// evaluate returns a literal at pos for the value of e, or e if its value
+// cannot be written as a literal.
+func evaluate(e ast.Expression, pos token.Position) ast.Expression {
+	switch obj := eval.Eval(e, object.NewEnv()).(type) {
+	case *object.Integer:
+		lit := strconv.FormatInt(obj.Value, 10)
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: lit, Pos: pos}, Value: obj.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos}, Value: obj.Value}
+	case *object.Boolean:
+		tok := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+		if obj.Value {
+			tok = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+		}
+		return &ast.BooleanExpression{Token: tok, Value: obj.Value}
+	}
+
+	return e
+}
+
+// removeDeadBranches replaces an if whose condition is a literal by the
+// branch it takes. A branch that is a single expression replaces the if
+// wherever it is; other branches only replace ifs that are statements of
+// their own, whose statements they take the place of, as blocks have no
+// scope of their own. An if that takes no branch as the last statement of
+// a block or program stays, as it gives the block its value.
+func removeDeadBranches(program *ast.Program, _ *resolver.Resolver) {
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		switch n := node.(type) {
+		case *ast.IfExpression:
+			branch, ok := taken(n)
+			if !ok || branch == nil || len(branch.Statements) != 1 {
+				break
+			}
+
+			if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok && es.Expression != nil {
+				return es.Expression
+			}
+		case *ast.Program:
+			n.Statements = spliceBranches(n.Statements)
+		case *ast.BlockStatement:
+			n.Statements = spliceBranches(n.Statements)
+		}
+
+		return node
+	})
+}
+
+// taken returns the branch ie takes if its condition is a literal, which is
+// nil for a false condition without else.
+func taken(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	if !literal(ie.Condition) {
+		return nil, false
+	}
+
+	if b, ok := ie.Condition.(*ast.BooleanExpression); ok && !b.Value {
+		return ie.Alternative, true
+	}
+
+	return ie.Consequence, true
+}
+
+func spliceBranches(stmts []ast.Statement) []ast.Statement {
+	var out []ast.Statement
+	for i, stmt := range stmts {
+		branch, ok := takenByStatement(stmt)
+		last := i == len(stmts)-1
+
+		switch {
+		case !ok:
+			out = append(out, stmt)
+		case branch == nil || len(branch.Statements) == 0:
+			if last {
+				out = append(out, stmt)
+			}
+		default:
+			out = append(out, branch.Statements...)
+		}
+	}
+
+	if out == nil {
+		return stmts[:0]
+	}
+
+	return out
+}
+
+func takenByStatement(stmt ast.Statement) (*ast.BlockStatement, bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+
+	return taken(ie)
+}
+
+// inline replaces calls of functions whose body is a single expression of
+// literals, operators and each of their parameters once, in order, by the
+// body with the arguments in place of the parameters. As the arguments are
+// then evaluated where the parameters were, only literals, parameters and
+// builtins, which cannot fail or change anything when evaluated, are
+// inlined. The function has to be bound by a single let that runs before
+// the call: one directly in the program or a function body that comes
+// before it in the source.
+func inline(program *ast.Program, r *resolver.Resolver) {
+	lets := make(map[*ast.ID]int)
+	candidates := make(map[*ast.ID]*ast.LetStatement)
+	params := make(map[*ast.ID]bool)
+
+	direct := func(stmts []ast.Statement) {
+		for _, stmt := range stmts {
+			if let, ok := stmt.(*ast.LetStatement); ok && let.Name != nil {
+				if fn, ok := let.Value.(*ast.FunctionLiteral); ok && inlinable(fn, r) {
+					candidates[r.Declaration(let.Name)] = let
+				}
+			}
+		}
+	}
+
+	direct(program.Statements)
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.LetStatement:
+			if n.Name != nil {
+				lets[r.Declaration(n.Name)]++
+			}
+		case *ast.FunctionLiteral:
+			for _, param := range n.Params {
+				params[param] = true
+			}
+			if n.Body != nil {
+				direct(n.Body.Statements)
+			}
+		}
+		return true
+	})
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		id, ok := call.Function.(*ast.ID)
+		if !ok {
+			return node
+		}
+
+		decl := r.Declaration(id)
+		let, ok := candidates[decl]
+		if !ok || lets[decl] != 1 || !let.Token.Pos.Before(call.Token.Pos) {
+			return node
+		}
+
+		fn := let.Value.(*ast.FunctionLiteral)
+		if len(call.Arguments) != len(fn.Params) {
+			return node
+		}
+
+		args := make(map[*ast.ID]ast.Expression)
+		for i, arg := range call.Arguments {
+			if !harmless(arg, r, params) {
+				return node
+			}
+			args[fn.Params[i]] = arg
+		}
+
+		return substitute(fn.Body.Statements[0].(*ast.ExpressionStatement).Expression, r, args)
+	})
+}
+
+// inlinable reports whether the body of fn is a single expression of
+// literals, operators and each parameter once, in order.
+func inlinable(fn *ast.FunctionLiteral, r *resolver.Resolver) bool {
+	if fn.Body == nil || len(fn.Body.Statements) != 1 {
+		return false
+	}
+
+	es, ok := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok || es.Expression == nil {
+		return false
+	}
+
+	var uses []*ast.ID
+	simple := true
+	ast.Inspect(es.Expression, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.ID:
+			uses = append(uses, r.Declaration(n))
+		case *ast.PrefixExpression, *ast.InfixExpression, *ast.IntegerLiteral, *ast.StringLiteral, *ast.BooleanExpression, nil:
+		default:
+			simple = false
+		}
+		return simple
+	})
+
+	if !simple || len(uses) != len(fn.Params) {
+		return false
+	}
+	for i, param := range fn.Params {
+		if uses[i] != param {
+			return false
+		}
+	}
+
+	return true
+}
+
+// harmless reports whether evaluating arg can neither fail nor have an
+// effect.
+func harmless(arg ast.Expression, r *resolver.Resolver, params map[*ast.ID]bool) bool {
+	if literal(arg) {
+		return true
+	}
+
+	id, ok := arg.(*ast.ID)
+	return ok && (id.Scope == ast.Builtin || params[r.Declaration(id)])
+}
+
+// substitute returns a copy of e, which inlinable accepted, with the
+// parameters replaced by args.
+func substitute(e ast.Expression, r *resolver.Resolver, args map[*ast.ID]ast.Expression) ast.Expression {
+	switch n := e.(type) {
+	case *ast.ID:
+		return args[r.Declaration(n)]
+	case *ast.PrefixExpression:
+		return &ast.PrefixExpression{Token: n.Token, Operator: n.Operator, Right: substitute(n.Right, r, args)}
+	case *ast.InfixExpression:
+		return &ast.InfixExpression{
+			Token:    n.Token,
+			Left:     substitute(n.Left, r, args),
+			Operator: n.Operator,
+			Right:    substitute(n.Right, r, args),
+		}
+	case *ast.IntegerLiteral:
+		c := *n
+		return &c
+	case *ast.StringLiteral:
+		c := *n
+		return &c
+	case *ast.BooleanExpression:
+		c := *n
+		return &c
+	}
+
+	return e
+}
+
+// removeUnusedLets removes the lets of literals, functions and arrays of
+// them whose names are never used, over and over, as removing a function
+// can leave others unused. A let that is the last statement of a block or
+// program stays, as it gives the block its value.
+func removeUnusedLets(program *ast.Program, r *resolver.Resolver) {
+	for {
+		names := make(map[*ast.ID]bool)
+		uses := make(map[*ast.ID]int)
+		ast.Inspect(program, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.LetStatement:
+				names[n.Name] = true
+			case *ast.ID:
+				if !names[n] {
+					uses[r.Declaration(n)]++
+				}
+			}
+			return true
+		})
+
+		removed := false
+		unused := func(stmts []ast.Statement) []ast.Statement {
+			var kept []ast.Statement
+			for i, stmt := range stmts {
+				let, ok := stmt.(*ast.LetStatement)
+				if ok && i < len(stmts)-1 && let.Name != nil && uses[r.Declaration(let.Name)] == 0 && pure(let.Value) {
+					removed = true
+					continue
+				}
+				kept = append(kept, stmt)
+			}
+
+			if kept == nil {
+				return stmts[:0]
+			}
+			return kept
+		}
+
+		ast.Rewrite(program, func(node ast.Node) ast.Node {
+			switch n := node.(type) {
+			case *ast.Program:
+				n.Statements = unused(n.Statements)
+			case *ast.BlockStatement:
+				n.Statements = unused(n.Statements)
+			}
+			return node
+		})
+
+		if !removed {
+			return
+		}
+	}
+}
+
+// pure reports whether evaluating e can neither fail nor have an effect.
+func pure(e ast.Expression) bool {
+	switch n := e.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.BooleanExpression, *ast.FunctionLiteral:
+		return true
+	case *ast.ArrayLiteral:
+		for _, el := range n.Elements {
+			if !pure(el) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
 	"monkey/internal/lexer"
 	"monkey/internal/token"
 )
@@ -16,9 +17,21 @@ type (
 type Parser struct {
 	l         *lexer.Lexer
 	errors    []string
+	diags     []diagnostic.Diagnostic
 	currToken token.Token
 	peekToken token.Token
 
+	// panicking is set by the first error in a statement and silences the
+	// errors that follow from it until the parser has skipped to the start
+	// of the next statement.
+	panicking bool
+
+	// braces counts the braces opened up to the current token and level
+	// the ones opened around the statements being parsed, so recovery
+	// knows which closing brace ends the enclosing block.
+	braces int
+	level  int
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -64,6 +77,15 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.currToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.currToken.Type {
+	case token.LBRACE:
+		p.braces++
+	case token.RBRACE:
+		if p.braces > 0 {
+			p.braces--
+		}
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -71,17 +93,61 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.currToken.Type != token.EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
-		}
-
+		stmt, _ := p.parseStatementOrRecover()
+		program.Statements = append(program.Statements, stmt)
 		p.nextToken()
 	}
 
 	return program
 }
 
+// parseStatementOrRecover parses a statement and, if that fails, skips the
+// rest of it and reports true. A statement the parser could not make sense
+// of at all is replaced by an ast.BadStatement, so the result is never nil.
+func (p *Parser) parseStatementOrRecover() (ast.Statement, bool) {
+	tok := p.currToken
+	stmt := p.parseStatement()
+
+	if !p.panicking {
+		return stmt, false
+	}
+
+	p.synchronize()
+	p.panicking = false
+
+	if stmt == nil {
+		return &ast.BadStatement{Token: tok, End: p.currToken.Pos}, true
+	}
+
+	return stmt, true
+}
+
+// synchronize skips tokens up to the end of the broken statement: a
+// semicolon, or the token before let, return or the brace closing the
+// enclosing block. Anything between braces opened inside the statement is
+// skipped. If the parser already is on the brace that closes the enclosing
+// block, it stays there.
+func (p *Parser) synchronize() {
+	for p.currToken.Type != token.EOF && p.braces >= p.level {
+		if p.braces == p.level {
+			if p.currToken.Type == token.SEMICOLON {
+				return
+			}
+
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.EOF:
+				return
+			case token.RBRACE:
+				if p.level > 0 {
+					return
+				}
+			}
+		}
+
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currToken.Type {
 	case token.LET:
@@ -104,15 +170,38 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 }
 
 func (p *Parser) peekErr(t token.TokenType) {
-	p.errors = append(p.errors,
-		fmt.Sprintf("expected next token to be %s, but got %s instead", t.String(), p.peekToken.Type.String()),
-	)
+	p.errorf(p.peekToken.Pos, "expected next token to be %s, but got %s instead", t.String(), p.peekToken.Type.String())
+}
+
+// errorf records a syntax error unless the parser is already recovering
+// from one, or the same error was reported at the same position before.
+func (p *Parser) errorf(pos token.Position, format string, args ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	d := diagnostic.Diagnostic{Pos: pos, Severity: diagnostic.Error, Message: fmt.Sprintf(format, args...)}
+	for _, prev := range p.diags {
+		if prev == d {
+			return
+		}
+	}
+
+	p.diags = append(p.diags, d)
+	p.errors = append(p.errors, d.Message)
 }
 
+// Errors returns the messages of the syntax errors without their positions.
 func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Diagnostics returns the syntax errors in the order they were found.
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diags
+}
+
 func (p *Parser) registerPrefix(t token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[t] = fn
 }
@@ -139,7 +228,5 @@ func (p *Parser) currPrecedence() Precedence {
 
 func (p *Parser) PrintErrors(out io.Writer) {
 	io.WriteString(out, "PARSER ERRORS:\n")
-	for _, msg := range p.errors {
-		io.WriteString(out, "\t"+msg+"\n")
-	}
+	diagnostic.Print(out, p.diags)
 }
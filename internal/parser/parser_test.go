@@ -6,6 +6,7 @@ import (
 	"monkey/internal/lexer"
 	"monkey/internal/parser"
 	"monkey/internal/token"
+	"strings"
 	"testing"
 )
 
@@ -277,7 +278,7 @@ func TestStatementsParsing(t *testing.T) {
 				},
 				{
 					"3 + 4; -5 * 5",
-					"(3 + 4)((-5) * 5)",
+					"(3 + 4);((-5) * 5)",
 				},
 				{
 					"5 > 4 == 3 < 4",
@@ -568,6 +569,36 @@ func TestStatementsParsing(t *testing.T) {
 				return
 			}
 		})
+		t.Run("test slice expressions", func(t *testing.T) {
+			tests := []struct {
+				input    string
+				expected string
+			}{
+				{"a[1:2]", "(a[1:2])"},
+				{"a[:2]", "(a[:2])"},
+				{"a[1:]", "(a[1:])"},
+				{"a[:]", "(a[:])"},
+				{"a[::2]", "(a[::2])"},
+				{"a[-1::-1]", "(a[(-1)::(-1)])"},
+				{"a[1 + 1:b * 2:c]", "(a[(1 + 1):(b * 2):c])"},
+			}
+
+			for _, tt := range tests {
+				l := lexer.New(tt.input)
+				p := parser.New(l)
+				program := p.ParseProgram()
+				checkParserErrors(t, p)
+
+				stmt := program.Statements[0].(*ast.ExpressionStatement)
+				if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+					t.Fatalf("stmt.Expression is not *ast.SliceExpression. got=%T", stmt.Expression)
+				}
+
+				if actual := program.String(); actual != tt.expected {
+					t.Errorf("expected=%q, got=%q", tt.expected, actual)
+				}
+			}
+		})
 		t.Run("test hash map literal", func(t *testing.T) {
 			input := `{"one": 1, "two": 2}`
 
@@ -591,13 +622,18 @@ func TestStatementsParsing(t *testing.T) {
 				"two": 2,
 			}
 
-			for k, v := range hm.Pairs {
-				literal, ok := k.(*ast.StringLiteral)
+			for i, pair := range hm.Pairs {
+				literal, ok := pair.Key.(*ast.StringLiteral)
 				if !ok {
-					t.Errorf("k is not *ast.StringLiteral. got=%T", k)
+					t.Errorf("pair.Key is not *ast.StringLiteral. got=%T", pair.Key)
+					continue
+				}
+
+				if want := []string{"one", "two"}[i]; literal.Value != want {
+					t.Errorf("pairs are not in source order. got=%q at %d, want=%q", literal.Value, i, want)
 				}
 
-				testIntegerLiteral(t, v, int64(expected[literal.String()]))
+				testIntegerLiteral(t, pair.Value, int64(expected[literal.Value]))
 			}
 		})
 		t.Run("empty hash-map literal", func(t *testing.T) {
@@ -645,20 +681,20 @@ func TestStatementsParsing(t *testing.T) {
 				},
 			}
 
-			for k, v := range hashMap.Pairs {
-				literal, ok := k.(*ast.StringLiteral)
+			for _, pair := range hashMap.Pairs {
+				literal, ok := pair.Key.(*ast.StringLiteral)
 				if !ok {
-					t.Errorf("k is not *ast.StringLiteral. got=%T", k)
+					t.Errorf("pair.Key is not *ast.StringLiteral. got=%T", pair.Key)
 					continue
 				}
 
-				testFunc, ok := pairsTest[literal.String()]
+				testFunc, ok := pairsTest[literal.Value]
 				if !ok {
-					t.Errorf("no test function for key %q found", literal.String())
+					t.Errorf("no test function for key %q found", literal.Value)
 					continue
 				}
 
-				testFunc(v)
+				testFunc(pair.Value)
 			}
 		})
 	})
@@ -720,6 +756,30 @@ func TestFunctionParamParsing(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 1;", "let x: int = 1;"},
+		{"let xs: [string] = [];", "let xs: [string] = [];"},
+		{"let h: {string: [int]} = {};", "let h: {string: [int]} = {};"},
+		{"fn(x: int, y) -> bool { x }", "fn(x: int, y) -> bool{ x }"},
+		{"fn(f: fn(int, any) -> fn() -> null) -> {int: int} { {} }", "fn(f: fn(int, any) -> fn() -> null) -> {int: int}{ {} }"},
+		{"let f = fn() -> int { 1 } - 1;", "let f = (fn() -> int{ 1 } - 1);"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("wrong program for %q. got=%q, want=%q", tt.input, actual, tt.expected)
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral() is not let. got=%s", s.TokenLiteral())
@@ -855,3 +915,147 @@ func testBoolExpr(t *testing.T, exp ast.Expression, value bool) bool {
 
 	return true
 }
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		errors   []string
+		expected string
+	}{
+		{
+			"let = 5; let y = 2; y",
+			[]string{"1:5: error: expected next token to be ID, but got = instead"},
+			"<bad statement>let y = 2;y",
+		},
+		{
+			"let x = (1 + 2; let z = 3;",
+			[]string{"1:15: error: expected next token to be ), but got ; instead"},
+			"let x = <bad expression>;let z = 3;",
+		},
+		{
+			"add(1, 2\nlet x = 3;",
+			[]string{"2:1: error: expected next token to be ), but got let instead"},
+			"<bad expression>;let x = 3;",
+		},
+		{
+			"let f = fn() { 1 + }; let y = 2;",
+			[]string{"1:20: error: no prefix parse function for } found"},
+			"let f = fn(){ (1 + <bad expression>) };let y = 2;",
+		},
+		{
+			"fn() { if (x { 1 } }; let q = 1;",
+			[]string{"1:14: error: expected next token to be ), but got { instead"},
+			"fn(){ <bad expression> };let q = 1;",
+		},
+		{
+			"let a = ) ) ); 5",
+			[]string{"1:9: error: no prefix parse function for ) found"},
+			"let a = <bad expression>;5",
+		},
+		{
+			"{1: 2, 3 4}; 9",
+			[]string{"1:10: error: expected next token to be :, but got INT instead"},
+			"<bad expression>;9",
+		},
+		{
+			"fn(1) { x }; fn() { let = 1; let b = 2; b }",
+			[]string{
+				"1:4: error: expected next token to be ID, but got INT instead",
+				"1:25: error: expected next token to be ID, but got = instead",
+			},
+			"<bad expression>;fn(){ <bad statement>let b = 2;b }",
+		},
+		{
+			"let x: = 1; let f = fn(a: [int) { a }; fn() -> { 1 }",
+			[]string{
+				"1:8: error: expected a type, but got = instead",
+				"1:31: error: expected next token to be ], but got ) instead",
+				"1:50: error: expected a type, but got INT instead",
+			},
+			"<bad statement>let f = <bad expression>;<bad expression>",
+		},
+		{
+			"let x = 1 @ 2; x",
+			[]string{"1:11: error: invalid character \"@\""},
+			"let x = 1;<bad expression>;x",
+		},
+		{
+			`puts("hello)`,
+			[]string{"1:6: error: unterminated string"},
+			"<bad expression>",
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		diags := p.Diagnostics()
+		if len(diags) != len(tt.errors) {
+			t.Errorf("wrong number of errors for %q. got=%v", tt.input, diags)
+			continue
+		}
+
+		for i, d := range diags {
+			if d.String() != tt.errors[i] {
+				t.Errorf("wrong error for %q. got=%q, want=%q", tt.input, d.String(), tt.errors[i])
+			}
+		}
+
+		if len(p.Errors()) != len(diags) {
+			t.Errorf("Errors and Diagnostics disagree for %q. got=%v", tt.input, p.Errors())
+		}
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("wrong program for %q. got=%q, want=%q", tt.input, actual, tt.expected)
+		}
+	}
+}
+
+// FuzzParser checks that the parser gets through any input and that a
+// program it parses without errors prints as source that parses back to
+// the same tree.
+func FuzzParser(f *testing.F) {
+	for _, seed := range []string{
+		"let x = 5 * (2 + -y); x[0]",
+		`let s = "a\"b\n"; puts(s, {"k": [1, 2][1:]})`,
+		"let f = fn(a: int, b) -> [string] { if (a) { return b; } else { c } }; f(1, 2)(3)",
+		"if (!x) { 1 }; if (x[1]) { 2 }; if (f(x)) { 3 }",
+		"fn() {}; {}; []; x[::-1]",
+		"let x = 1 @ 2; puts(\"unterminated",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+
+		printed := program.String()
+		p = parser.New(lexer.New(printed))
+		reparsed := p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Fatalf("%q prints as %q, which does not parse: %v", input, printed, errs)
+		}
+
+		if again := reparsed.String(); again != printed {
+			t.Fatalf("%q prints as %q, which prints as %q", input, printed, again)
+		}
+		if a, b := nodeKinds(program), nodeKinds(reparsed); a != b {
+			t.Fatalf("%q prints as %q, which parses to a different tree:\n%s\n%s", input, printed, a, b)
+		}
+	})
+}
+
+func nodeKinds(program *ast.Program) string {
+	var kinds []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		kinds = append(kinds, fmt.Sprintf("%T", node))
+		return true
+	})
+
+	return strings.Join(kinds, " ")
+}
@@ -1,10 +1,10 @@
 package parser
 
 import (
-	"fmt"
 	"monkey/internal/ast"
 	"monkey/internal/token"
 	"strconv"
+	"strings"
 )
 
 func (p *Parser) parseLetStatement() ast.Statement {
@@ -15,6 +15,9 @@ func (p *Parser) parseLetStatement() ast.Statement {
 	}
 
 	stmt.Name = &ast.ID{Token: p.currToken, Value: p.currToken.Literal}
+	if !p.parseAnnotation(stmt.Name) {
+		return nil
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -24,7 +27,11 @@ func (p *Parser) parseLetStatement() ast.Statement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	if p.peekToken.Type == token.SEMICOLON {
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fn.Name = stmt.Name.Value
+	}
+
+	if p.peekToken.Type == token.SEMICOLON && !p.panicking {
 		p.nextToken()
 	}
 
@@ -38,7 +45,7 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	if p.peekToken.Type == token.SEMICOLON {
+	if p.peekToken.Type == token.SEMICOLON && !p.panicking {
 		p.nextToken()
 	}
 
@@ -50,7 +57,7 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
-	if p.peekToken.Type == token.SEMICOLON {
+	if p.peekToken.Type == token.SEMICOLON && !p.panicking {
 		p.nextToken()
 	}
 
@@ -58,14 +65,23 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 }
 
 func (p *Parser) noPrefixParseFnErr(t token.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("no prefix parse function for %s found", t.String()))
+	if t == token.INVALID {
+		if strings.HasPrefix(p.currToken.Literal, `"`) {
+			p.errorf(p.currToken.Pos, "unterminated string")
+		} else {
+			p.errorf(p.currToken.Pos, "invalid character %q", p.currToken.Literal)
+		}
+		return
+	}
+
+	p.errorf(p.currToken.Pos, "no prefix parse function for %s found", t.String())
 }
 
 func (p *Parser) parseExpression(precedence Precedence) ast.Expression {
 	prefix := p.prefixParseFns[p.currToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnErr(p.currToken.Type)
-		return nil
+		return &ast.BadExpression{Token: p.currToken}
 	}
 	leftExp := prefix()
 
@@ -94,10 +110,8 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
 	if err != nil {
-		p.errors = append(p.errors,
-			fmt.Sprintf("could not parse %q as integer", p.currToken.Literal),
-		)
-		return nil
+		p.errorf(p.currToken.Pos, "could not parse %q as integer", p.currToken.Literal)
+		return &ast.BadExpression{Token: p.currToken}
 	}
 	lit.Value = value
 
@@ -135,11 +149,12 @@ func (p *Parser) parseBooleanExpression() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	tok := p.currToken
 	p.nextToken()
 
 	expr := p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return &ast.BadExpression{Token: tok}
 	}
 
 	return expr
@@ -149,15 +164,21 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currToken}
 	block.Statements = []ast.Statement{}
 
+	outer := p.level
+	p.level = p.braces
+	defer func() { p.level = outer }()
+
 	p.nextToken()
 
 	for p.currToken.Type != token.RBRACE && p.currToken.Type != token.EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
+		stmt, recovered := p.parseStatementOrRecover()
+		block.Statements = append(block.Statements, stmt)
+		if recovered && p.braces < p.level {
+			break
 		}
 		p.nextToken()
 	}
+	block.End = p.currToken.Pos
 
 	return block
 }
@@ -166,17 +187,17 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expr := &ast.IfExpression{Token: p.currToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: expr.Token}
 	}
 
 	p.nextToken()
 	expr.Condition = p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return &ast.BadExpression{Token: expr.Token}
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return &ast.BadExpression{Token: expr.Token}
 	}
 
 	expr.Consequence = p.parseBlockStatement()
@@ -185,7 +206,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		p.nextToken()
 
 		if !p.expectPeek(token.LBRACE) {
-			return nil
+			return &ast.BadExpression{Token: expr.Token}
 		}
 
 		expr.Alternative = p.parseBlockStatement()
@@ -198,13 +219,24 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	fn := &ast.FunctionLiteral{Token: p.currToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: fn.Token}
 	}
 
 	fn.Params = p.parseFunctionParams()
+	if fn.Params == nil {
+		return &ast.BadExpression{Token: fn.Token}
+	}
+
+	if p.peekToken.Type == token.ARROW {
+		p.nextToken()
+		p.nextToken()
+		if fn.ReturnType = p.parseType(); fn.ReturnType == nil {
+			return &ast.BadExpression{Token: fn.Token}
+		}
+	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return &ast.BadExpression{Token: fn.Token}
 	}
 
 	fn.Body = p.parseBlockStatement()
@@ -224,16 +256,26 @@ func (p *Parser) parseFunctionParams() []*ast.ID {
 		return identifiers
 	}
 
-	p.nextToken()
+	if !p.expectPeek(token.ID) {
+		return nil
+	}
 
 	id := &ast.ID{Token: p.currToken, Value: p.currToken.Literal}
+	if !p.parseAnnotation(id) {
+		return nil
+	}
 	identifiers = append(identifiers, id)
 
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(token.ID) {
+			return nil
+		}
 
 		id := &ast.ID{Token: p.currToken, Value: p.currToken.Literal}
+		if !p.parseAnnotation(id) {
+			return nil
+		}
 		identifiers = append(identifiers, id)
 	}
 
@@ -247,6 +289,10 @@ func (p *Parser) parseFunctionParams() []*ast.ID {
 func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.currToken, Function: fn}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	if exp.Arguments == nil {
+		return &ast.BadExpression{Token: exp.Token}
+	}
+
 	return exp
 }
 
@@ -254,6 +300,9 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return &ast.BadExpression{Token: array.Token}
+	}
 
 	return array
 }
@@ -283,43 +332,158 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
+	tok := p.currToken
+
+	var index ast.Expression
+	if p.peekToken.Type != token.COLON {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekToken.Type == token.COLON {
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return &ast.BadExpression{Token: tok}
+	}
+
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
+
+// parseSliceExpression parses the rest of left[start:end:step] with the
+// current token right before the first colon.
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	exp.End = p.parseSliceBound()
+
+	if p.peekToken.Type == token.COLON {
+		p.nextToken()
+		exp.Step = p.parseSliceBound()
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
-		return nil
+		return &ast.BadExpression{Token: tok}
 	}
 
 	return exp
 }
 
+// parseSliceBound parses the expression after a colon, if there is one.
+func (p *Parser) parseSliceBound() ast.Expression {
+	if p.peekToken.Type == token.COLON || p.peekToken.Type == token.RBRACKET {
+		return nil
+	}
+
+	p.nextToken()
+	return p.parseExpression(LOWEST)
+}
+
 func (p *Parser) parseHashMapLiteral() ast.Expression {
 	hashMap := &ast.HashMapLiteral{Token: p.currToken}
-	hashMap.Pairs = make(map[ast.Expression]ast.Expression)
+	hashMap.Pairs = []ast.HashMapPair{}
 
 	for p.peekToken.Type != token.RBRACE {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
 
 		if !p.expectPeek(token.COLON) {
-			return nil
+			return &ast.BadExpression{Token: hashMap.Token}
 		}
 
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
-		hashMap.Pairs[key] = value
+		hashMap.Pairs = append(hashMap.Pairs, ast.HashMapPair{Key: key, Value: value})
 
 		if p.peekToken.Type != token.RBRACE && !p.expectPeek(token.COMMA) {
-			return nil
+			return &ast.BadExpression{Token: hashMap.Token}
 		}
 	}
 
 	if !p.expectPeek(token.RBRACE) {
-		return nil
+		return &ast.BadExpression{Token: hashMap.Token}
 	}
 
 	return hashMap
 }
+
+// parseAnnotation parses the optional ": type" after the name id declares.
+// It reports false if there is one and it is malformed.
+func (p *Parser) parseAnnotation(id *ast.ID) bool {
+	if p.peekToken.Type != token.COLON {
+		return true
+	}
+
+	p.nextToken()
+	p.nextToken()
+	id.Type = p.parseType()
+
+	return id.Type != nil
+}
+
+// parseType parses a type starting at the current token: a name like int,
+// [T] for arrays, {K: V} for hash maps or fn(T, ...) -> R for functions.
+// It returns nil after reporting an error if there is no valid type.
+func (p *Parser) parseType() ast.Type {
+	switch p.currToken.Type {
+	case token.ID:
+		return &ast.NamedType{Token: p.currToken, Name: p.currToken.Literal}
+	case token.LBRACKET:
+		t := &ast.ArrayType{Token: p.currToken}
+		p.nextToken()
+		if t.Element = p.parseType(); t.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+
+		return t
+	case token.LBRACE:
+		t := &ast.HashMapType{Token: p.currToken}
+		p.nextToken()
+		if t.Key = p.parseType(); t.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		if t.Value = p.parseType(); t.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+
+		return t
+	case token.FUNC:
+		t := &ast.FunctionType{Token: p.currToken, Params: []ast.Type{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+
+		for p.peekToken.Type != token.RPAREN {
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			t.Params = append(t.Params, param)
+
+			if p.peekToken.Type != token.RPAREN && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		p.nextToken()
+		if t.Return = p.parseType(); t.Return == nil {
+			return nil
+		}
+
+		return t
+	}
+
+	p.errorf(p.currToken.Pos, "expected a type, but got %s instead", p.currToken.Type.String())
+	return nil
+}
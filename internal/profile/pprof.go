@@ -0,0 +1,162 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format of
+// pprof, with the number of calls and the time spent as sample values.
+// Every call stack is one sample whose locations are the lines its calls
+// are at in filename.
+func (p *Profile) WritePprof(w io.Writer, filename string) error {
+	var b protoBuffer
+	index := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = int64(len(table))
+		table = append(table, s)
+		return int64(len(table) - 1)
+	}
+
+	valueType := func(typ, unit string) []byte {
+		var vt protoBuffer
+		vt.int(1, str(typ))
+		vt.int(2, str(unit))
+		return vt.bytes()
+	}
+
+	b.message(1, valueType("calls", "count"))
+	b.message(1, valueType("time", "nanoseconds"))
+
+	functions := make(map[string]uint64)
+	locations := make(map[siteKey]uint64)
+	var funcs, locs protoBuffer
+
+	p.walk(func(s *site) {
+		var ids []uint64
+		for c := s; c.parent != nil; c = c.parent {
+			key := siteKey{c.name, c.line}
+			if c.builtin {
+				key.line = 0
+			}
+
+			id, ok := locations[key]
+			if !ok {
+				fid, ok := functions[c.name]
+				if !ok {
+					fid = uint64(len(functions) + 1)
+					functions[c.name] = fid
+
+					var fn protoBuffer
+					fn.uint(1, fid)
+					fn.int(2, str(c.name))
+					fn.int(3, str(c.name))
+					if !c.builtin {
+						fn.int(4, str(filename))
+					}
+					funcs.message(5, fn.bytes())
+				}
+
+				id = uint64(len(locations) + 1)
+				locations[key] = id
+
+				var line protoBuffer
+				line.uint(1, fid)
+				line.int(2, int64(key.line))
+
+				var loc protoBuffer
+				loc.uint(1, id)
+				loc.message(4, line.bytes())
+				locs.message(4, loc.bytes())
+			}
+
+			ids = append(ids, id)
+		}
+
+		if s.calls == 0 && s.self == 0 {
+			return
+		}
+
+		var sample protoBuffer
+		sample.packedUint(1, ids)
+		sample.packedInt(2, []int64{int64(s.calls), s.self.Nanoseconds()})
+		b.message(2, sample.bytes())
+	})
+
+	b.append(locs.bytes())
+	b.append(funcs.bytes())
+
+	// The string table has to come after everything that adds to it.
+	periodType := valueType("time", "nanoseconds")
+	for _, s := range table {
+		b.message(6, []byte(s))
+	}
+
+	b.int(9, p.start.UnixNano())
+	b.int(10, p.Duration.Nanoseconds())
+	b.message(11, periodType)
+	b.int(12, 1)
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.bytes()); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// protoBuffer encodes the fields of a protocol buffer message.
+type protoBuffer struct {
+	buf []byte
+}
+
+func (b *protoBuffer) bytes() []byte { return b.buf }
+
+func (b *protoBuffer) append(data []byte) { b.buf = append(b.buf, data...) }
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+func (b *protoBuffer) key(field int, wireType uint64) {
+	b.varint(uint64(field)<<3 | wireType)
+}
+
+func (b *protoBuffer) uint(field int, x uint64) {
+	b.key(field, 0)
+	b.varint(x)
+}
+
+func (b *protoBuffer) int(field int, x int64) {
+	b.uint(field, uint64(x))
+}
+
+func (b *protoBuffer) message(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.buf = append(b.buf, data...)
+}
+
+func (b *protoBuffer) packedUint(field int, xs []uint64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.message(field, packed.bytes())
+}
+
+func (b *protoBuffer) packedInt(field int, xs []int64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.message(field, packed.bytes())
+}
@@ -0,0 +1,308 @@
+// Package profile measures where a Monkey program spends its time. A
+// Profiler traces evaluation and charges the time between two events to
+// the call stack and source line active at the time, so the numbers it
+// reports are exact rather than sampled.
+package profile
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/eval"
+	"monkey/internal/object"
+	"sort"
+	"time"
+)
+
+// site is a call stack together with the line each call in it is at. Sites
+// form a tree rooted at the program, so a stack is identified by the site
+// of its innermost call.
+type site struct {
+	name    string
+	line    int
+	builtin bool
+	parent  *site
+
+	children map[siteKey]*site
+	self     time.Duration
+	calls    int
+}
+
+type siteKey struct {
+	name string
+	line int
+}
+
+func (s *site) child(name string, line int, builtin bool) *site {
+	key := siteKey{name, line}
+	if c, ok := s.children[key]; ok {
+		return c
+	}
+
+	c := &site{name: name, line: line, builtin: builtin, parent: s, children: make(map[siteKey]*site)}
+	s.children[key] = c
+	return c
+}
+
+// frame is a call in progress.
+type frame struct {
+	name    string
+	builtin bool
+	parent  *site // the site of the caller
+	site    *site // the site of this call at its current line
+	start   time.Time
+}
+
+func (f *frame) setLine(line int) {
+	if f.site.line != line {
+		f.site = f.parent.child(f.name, line, f.builtin)
+	}
+}
+
+// nodeEntry remembers the line a frame was at before it entered a node.
+type nodeEntry struct {
+	frame *frame
+	line  int
+	stmt  bool
+}
+
+// Profiler is an eval.Tracer that builds a Profile of the evaluation it
+// follows.
+type Profiler struct {
+	// Clock tells the time. It is time.Now unless replaced, for example to
+	// get reproducible numbers in tests.
+	Clock func() time.Time
+
+	start, last time.Time
+	root        *site // above the program, which is its only child
+	frames      []*frame
+	nodes       []nodeEntry
+
+	functions map[string]*FunctionStats
+	active    map[string]int // calls in progress per function
+	lines     map[int]*LineStats
+	running   map[int]int       // statements in progress per line
+	lineStart map[int]time.Time // of the outermost statement in progress
+}
+
+func New() *Profiler {
+	return &Profiler{Clock: time.Now}
+}
+
+// Start installs the profiler as the evaluator's tracer.
+func (p *Profiler) Start() {
+	p.root = &site{children: make(map[siteKey]*site)}
+	p.start = p.Clock()
+	p.last = p.start
+	p.frames = []*frame{{name: "main", parent: p.root, site: p.root.child("main", 0, false), start: p.start}}
+	p.frames[0].site.calls = 1
+	p.nodes = nil
+	p.functions = map[string]*FunctionStats{"main": {Name: "main", Calls: 1}}
+	p.active = map[string]int{"main": 1}
+	p.lines = make(map[int]*LineStats)
+	p.running = make(map[int]int)
+	p.lineStart = make(map[int]time.Time)
+
+	eval.SetTracer(p)
+}
+
+// Stop removes the tracer and returns what the profiler measured since
+// Start.
+func (p *Profiler) Stop() *Profile {
+	eval.SetTracer(nil)
+
+	now := p.charge()
+	p.functions["main"].Cum = now.Sub(p.frames[0].start)
+
+	prof := &Profile{Duration: now.Sub(p.start), start: p.start, root: p.root}
+	prof.walk(func(s *site) {
+		p.functions[s.name].Flat += s.self
+		if s.line > 0 {
+			p.lineStats(s.line).Flat += s.self
+		}
+	})
+
+	for _, f := range p.functions {
+		prof.Functions = append(prof.Functions, *f)
+	}
+	for _, l := range p.lines {
+		prof.Lines = append(prof.Lines, *l)
+	}
+
+	sort.Slice(prof.Functions, func(i, j int) bool {
+		a, b := prof.Functions[i], prof.Functions[j]
+		if a.Flat != b.Flat {
+			return a.Flat > b.Flat
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(prof.Lines, func(i, j int) bool {
+		return prof.Lines[i].Line < prof.Lines[j].Line
+	})
+
+	return prof
+}
+
+// charge charges the time since the previous event to the current site
+// and returns the time now.
+func (p *Profiler) charge() time.Time {
+	now := p.Clock()
+	p.frames[len(p.frames)-1].site.self += now.Sub(p.last)
+	p.last = now
+	return now
+}
+
+func (p *Profiler) EnterNode(node ast.Node) {
+	now := p.charge()
+
+	top := p.frames[len(p.frames)-1]
+	entry := nodeEntry{frame: top, line: top.site.line}
+
+	if line := nodeLine(node); line > 0 {
+		top.setLine(line)
+
+		if isStatement(node) {
+			entry.stmt = true
+			p.lineStats(line).Hits++
+			if p.running[line] == 0 {
+				p.lineStart[line] = now
+			}
+			p.running[line]++
+		}
+	}
+
+	p.nodes = append(p.nodes, entry)
+}
+
+func (p *Profiler) ExitNode(node ast.Node, result object.Object) {
+	now := p.charge()
+
+	entry := p.nodes[len(p.nodes)-1]
+	p.nodes = p.nodes[:len(p.nodes)-1]
+
+	if entry.stmt {
+		line := nodeLine(node)
+		p.running[line]--
+		if p.running[line] == 0 {
+			p.lineStats(line).Cum += now.Sub(p.lineStart[line])
+		}
+	}
+
+	entry.frame.setLine(entry.line)
+}
+
+func (p *Profiler) EnterCall(fn object.Object, args []object.Object) {
+	now := p.charge()
+
+	name, builtin := functionName(fn)
+	caller := p.frames[len(p.frames)-1]
+
+	// A call starts at the line of its caller, which is where the time of
+	// builtins, which have no lines of their own, is charged.
+	f := &frame{name: name, builtin: builtin, parent: caller.site, start: now}
+	f.site = caller.site.child(name, caller.site.line, builtin)
+	f.site.calls++
+	p.frames = append(p.frames, f)
+
+	stats, ok := p.functions[name]
+	if !ok {
+		stats = &FunctionStats{Name: name, Builtin: builtin}
+		p.functions[name] = stats
+	}
+	stats.Calls++
+	p.active[name]++
+}
+
+func (p *Profiler) ExitCall(fn object.Object, result object.Object) {
+	now := p.charge()
+
+	f := p.frames[len(p.frames)-1]
+	p.frames = p.frames[:len(p.frames)-1]
+
+	// Only the outermost call of a recursive function counts towards its
+	// cumulative time, or the time of the inner calls would count twice.
+	p.active[f.name]--
+	if p.active[f.name] == 0 {
+		p.functions[f.name].Cum += now.Sub(f.start)
+	}
+}
+
+func (p *Profiler) lineStats(line int) *LineStats {
+	stats, ok := p.lines[line]
+	if !ok {
+		stats = &LineStats{Line: line}
+		p.lines[line] = stats
+	}
+
+	return stats
+}
+
+// functionName returns the name a profile shows fn under.
+func functionName(fn object.Object) (string, bool) {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name != "" {
+			return fn.Name, false
+		}
+		return fmt.Sprintf("<fn at %s>", fn.Body.Token.Pos), false
+	case *object.Builtin:
+		return eval.BuiltinName(fn), true
+	}
+
+	return fn.Type().String(), true
+}
+
+func isStatement(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.LetStatement, *ast.ReturnStatement, *ast.ExpressionStatement:
+		return true
+	}
+
+	return false
+}
+
+// nodeLine returns the line of the token that stands for node, or 0 for
+// nodes without one. Blocks have none: the brace that opens a function body
+// is usually on the line of the let that names it, which should not be
+// charged for the time until the first statement of every call.
+func nodeLine(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token.Pos.Line
+	case *ast.ReturnStatement:
+		return node.Token.Pos.Line
+	case *ast.ExpressionStatement:
+		return node.Token.Pos.Line
+	case *ast.ID:
+		return node.Token.Pos.Line
+	case *ast.IntegerLiteral:
+		return node.Token.Pos.Line
+	case *ast.StringLiteral:
+		return node.Token.Pos.Line
+	case *ast.BooleanExpression:
+		return node.Token.Pos.Line
+	case *ast.PrefixExpression:
+		return node.Token.Pos.Line
+	case *ast.InfixExpression:
+		return node.Token.Pos.Line
+	case *ast.IfExpression:
+		return node.Token.Pos.Line
+	case *ast.FunctionLiteral:
+		return node.Token.Pos.Line
+	case *ast.CallExpression:
+		return node.Token.Pos.Line
+	case *ast.ArrayLiteral:
+		return node.Token.Pos.Line
+	case *ast.IndexExpression:
+		return node.Token.Pos.Line
+	case *ast.SliceExpression:
+		return node.Token.Pos.Line
+	case *ast.HashMapLiteral:
+		return node.Token.Pos.Line
+	case *ast.BadStatement:
+		return node.Token.Pos.Line
+	case *ast.BadExpression:
+		return node.Token.Pos.Line
+	}
+
+	return 0
+}
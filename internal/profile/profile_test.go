@@ -0,0 +1,238 @@
+package profile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/profile"
+	"monkey/internal/resolver"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `let fib = fn(n) {
+    if (n < 2) {
+        return n;
+    }
+    fib(n - 1) + fib(n - 2)
+};
+let total = len([fib(5), fib(1)]);
+`
+
+// run profiles program with a clock that advances a millisecond every
+// time it is read.
+func run(t *testing.T) *profile.Profile {
+	t.Helper()
+
+	p := parser.New(lexer.New(program))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	if diags := resolver.New(eval.BuiltinNames()).Resolve(prog); len(diags) != 0 {
+		t.Fatalf("resolver diagnostics: %v", diags)
+	}
+
+	now := time.Unix(0, 0)
+	profiler := profile.New()
+	profiler.Clock = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	profiler.Start()
+	result := eval.Eval(prog, object.NewEnv())
+	prof := profiler.Stop()
+
+	if err, ok := result.(*object.Error); ok {
+		t.Fatalf("evaluation failed: %s", err.Inspect())
+	}
+
+	return prof
+}
+
+func TestProfile(t *testing.T) {
+	prof := run(t)
+
+	t.Run("functions", func(t *testing.T) {
+		calls := map[string]int{}
+		var flat time.Duration
+		for _, f := range prof.Functions {
+			calls[f.Name] = f.Calls
+			flat += f.Flat
+
+			if f.Cum < f.Flat || f.Cum > prof.Duration {
+				t.Errorf("%s: cumulative time %s is not between %s and %s", f.Name, f.Cum, f.Flat, prof.Duration)
+			}
+			if f.Builtin != (f.Name == "len") {
+				t.Errorf("%s: Builtin is %t", f.Name, f.Builtin)
+			}
+		}
+
+		expected := map[string]int{"main": 1, "fib": 16, "len": 1}
+		for name, n := range expected {
+			if calls[name] != n {
+				t.Errorf("%s was called %d times, expected %d", name, calls[name], n)
+			}
+		}
+		if len(calls) != len(expected) {
+			t.Errorf("unexpected functions %v", calls)
+		}
+
+		if flat != prof.Duration {
+			t.Errorf("flat times add up to %s, expected %s", flat, prof.Duration)
+		}
+		if prof.Functions[0].Name != "fib" {
+			t.Errorf("%s comes first, expected fib", prof.Functions[0].Name)
+		}
+	})
+
+	t.Run("lines", func(t *testing.T) {
+		hits := map[int]int{}
+		for _, l := range prof.Lines {
+			hits[l.Line] = l.Hits
+		}
+
+		// fib(5) returns from line 3 eight times and recurses from line 5
+		// seven times, fib(1) returns from line 3.
+		expected := map[int]int{1: 1, 2: 16, 3: 9, 5: 7, 7: 1}
+		for line, n := range expected {
+			if hits[line] != n {
+				t.Errorf("line %d was hit %d times, expected %d", line, hits[line], n)
+			}
+		}
+		if len(hits) != len(expected) {
+			t.Errorf("unexpected lines %v", hits)
+		}
+	})
+
+	t.Run("folded", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := prof.WriteFolded(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		stacks := map[string]bool{}
+		var total time.Duration
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			i := strings.LastIndexByte(line, ' ')
+			ns, err := strconv.ParseInt(line[i+1:], 10, 64)
+			if err != nil {
+				t.Fatalf("invalid line %q", line)
+			}
+
+			stacks[line[:i]] = true
+			total += time.Duration(ns)
+		}
+
+		for _, stack := range []string{"main", "main;fib", "main;fib;fib;fib;fib;fib", "main;len"} {
+			if !stacks[stack] {
+				t.Errorf("missing stack %q in\n%s", stack, buf.String())
+			}
+		}
+		if stacks["main;fib;fib;fib;fib;fib;fib"] {
+			t.Errorf("fib(5) recursed too deep in\n%s", buf.String())
+		}
+		if total != prof.Duration {
+			t.Errorf("stacks add up to %s, expected %s", total, prof.Duration)
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := prof.WriteText(&buf, program); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range []string{"len (builtin)", "fib(n - 1) + fib(n - 2)", "total time"} {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("missing %q in\n%s", s, buf.String())
+			}
+		}
+	})
+
+	t.Run("pprof", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := prof.WritePprof(&buf, "fib.mk"); err != nil {
+			t.Fatal(err)
+		}
+
+		zr, err := gzip.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		strs := stringTable(t, data)
+		if len(strs) == 0 || strs[0] != "" {
+			t.Fatalf("string table %q does not start with an empty string", strs)
+		}
+		for _, s := range []string{"calls", "count", "time", "nanoseconds", "main", "fib", "len", "fib.mk"} {
+			if !contains(strs, s) {
+				t.Errorf("string table %q is missing %q", strs, s)
+			}
+		}
+	})
+}
+
+// stringTable decodes the top level fields of a pprof profile and returns
+// the strings of its string table.
+func stringTable(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	varint := func() uint64 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			if len(data) == 0 {
+				t.Fatal("truncated varint")
+			}
+			b := data[0]
+			data = data[1:]
+			x |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				return x
+			}
+		}
+	}
+
+	var strs []string
+	for len(data) > 0 {
+		key := varint()
+		switch key & 7 {
+		case 0:
+			varint()
+		case 2:
+			n := varint()
+			if uint64(len(data)) < n {
+				t.Fatalf("field %d is truncated", key>>3)
+			}
+			if key>>3 == 6 {
+				strs = append(strs, string(data[:n]))
+			}
+			data = data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+
+	return strs
+}
+
+func contains(strs []string, s string) bool {
+	for _, x := range strs {
+		if x == s {
+			return true
+		}
+	}
+
+	return false
+}
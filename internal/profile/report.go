@@ -0,0 +1,151 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Profile is what a Profiler measured.
+type Profile struct {
+	Duration  time.Duration
+	Functions []FunctionStats // by decreasing flat time
+	Lines     []LineStats     // by line
+
+	start time.Time
+	root  *site
+}
+
+// FunctionStats are the numbers of one function. Flat time is spent in the
+// function itself, cumulative time also in the functions it calls.
+type FunctionStats struct {
+	Name      string
+	Builtin   bool
+	Calls     int
+	Flat, Cum time.Duration
+}
+
+// LineStats are the numbers of one source line. Hits counts the statements
+// run that start on the line.
+type LineStats struct {
+	Line      int
+	Hits      int
+	Flat, Cum time.Duration
+}
+
+// walk calls fn for every site of the profile, callers before callees.
+func (p *Profile) walk(fn func(s *site)) {
+	var visit func(s *site)
+	visit = func(s *site) {
+		fn(s)
+		for _, c := range sortedChildren(s) {
+			visit(c)
+		}
+	}
+
+	for _, c := range sortedChildren(p.root) {
+		visit(c)
+	}
+}
+
+func sortedChildren(s *site) []*site {
+	children := make([]*site, 0, len(s.children))
+	for _, c := range s.children {
+		children = append(children, c)
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].name != children[j].name {
+			return children[i].name < children[j].name
+		}
+		return children[i].line < children[j].line
+	})
+
+	return children
+}
+
+// stack returns the names of the calls leading to s, outermost first.
+func (s *site) stack() []string {
+	var names []string
+	for ; s.parent != nil; s = s.parent {
+		names = append(names, s.name)
+	}
+
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+
+	return names
+}
+
+// WriteText writes a report for people: the functions by the time spent
+// in them, then the lines of src that took any time.
+func (p *Profile) WriteText(w io.Writer, src string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintf(tw, "total time %s\n\n", p.Duration)
+	fmt.Fprintf(tw, "calls\tflat\tflat%%\tcum\tcum%%\t function\n")
+	for _, f := range p.Functions {
+		name := f.Name
+		if f.Builtin {
+			name += " (builtin)"
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t %s\n",
+			f.Calls, f.Flat, p.percent(f.Flat), f.Cum, p.percent(f.Cum), name)
+	}
+
+	lines := strings.Split(src, "\n")
+	fmt.Fprintf(tw, "\nhits\tflat\tflat%%\tcum\tcum%%\tline\t source\n")
+	for _, l := range p.Lines {
+		text := ""
+		if l.Line <= len(lines) {
+			text = strings.TrimSpace(lines[l.Line-1])
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t %s\n",
+			l.Hits, l.Flat, p.percent(l.Flat), l.Cum, p.percent(l.Cum), l.Line, text)
+	}
+
+	return tw.Flush()
+}
+
+func (p *Profile) percent(d time.Duration) string {
+	if p.Duration == 0 {
+		return "0.0%"
+	}
+
+	return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(p.Duration))
+}
+
+// WriteFolded writes the profile as folded stacks, one line per call stack
+// with the names of its calls separated by semicolons and the nanoseconds
+// spent in it, which flame graph tools read.
+func (p *Profile) WriteFolded(w io.Writer) error {
+	folded := make(map[string]time.Duration)
+	var stacks []string
+
+	p.walk(func(s *site) {
+		if s.self == 0 {
+			return
+		}
+
+		stack := strings.Join(s.stack(), ";")
+		if _, ok := folded[stack]; !ok {
+			stacks = append(stacks, stack)
+		}
+		folded[stack] += s.self
+	})
+
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(w, "%s %d\n", stack, folded[stack].Nanoseconds()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"monkey/internal/debug"
+	"monkey/internal/diagnostic"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const DebugPrompt = "(debug) "
+
+const debugHelp = `commands:
+    c, continue         run until the next breakpoint
+    s, step             stop at the next statement, entering calls
+    n, next             stop at the next statement of this function
+    o, out              stop after this function returns
+    b, break LINE       set a breakpoint
+    d, delete LINE      delete a breakpoint
+    bt, stack           show the calls in progress
+    f, frame N          select the call to inspect
+    l, locals           show the environments of the selected call
+    p, print EXPR       evaluate EXPR in the selected call
+    q, quit             stop the program
+`
+
+// debugFile runs the file at path under the debugger, in the environment of
+// the REPL session so its bindings stay around afterwards. The program
+// stops before its first statement, and at every stop the user's commands
+// are read from scanner.
+func debugFile(path string, scanner *bufio.Scanner, out io.Writer, env *object.Environment, r *resolver.Resolver) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(out, "debug: %s\n", err)
+		return
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		p.PrintErrors(out)
+		return
+	}
+
+	if diags := r.Resolve(program); len(diags) != 0 {
+		diagnostic.Print(out, diags)
+		if diagnostic.HasErrors(diags) {
+			return
+		}
+	}
+
+	lines := strings.Split(string(src), "\n")
+	frame := 0
+
+	d := debug.New()
+	d.StopOnEntry()
+	d.Stopped = func(d *debug.Debugger, stop debug.Stop) debug.Action {
+		frame = 0
+		fmt.Fprintf(out, "stopped at %s:%s (%s)\n", path, stop.Pos, stop.Reason)
+		if line := stop.Pos.Line; line >= 1 && line <= len(lines) {
+			fmt.Fprintf(out, "%5d | %s\n", line, lines[line-1])
+		}
+
+		for {
+			fmt.Fprint(out, DebugPrompt)
+			if !scanner.Scan() {
+				return debug.Terminate
+			}
+
+			cmd, arg, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+			arg = strings.TrimSpace(arg)
+
+			switch cmd {
+			case "c", "continue":
+				return debug.Continue
+			case "s", "step":
+				return debug.StepIn
+			case "n", "next":
+				return debug.StepOver
+			case "o", "out":
+				return debug.StepOut
+			case "q", "quit":
+				return debug.Terminate
+			case "b", "break", "d", "delete":
+				line, err := strconv.Atoi(arg)
+				if err != nil {
+					fmt.Fprintf(out, "invalid line %q\n", arg)
+					continue
+				}
+
+				breakpoints := []int{}
+				for _, bp := range d.Breakpoints() {
+					if bp != line {
+						breakpoints = append(breakpoints, bp)
+					}
+				}
+				if cmd == "b" || cmd == "break" {
+					breakpoints = append(breakpoints, line)
+				}
+				d.SetBreakpoints(breakpoints)
+			case "bt", "stack":
+				for i, f := range d.Stack() {
+					marker := " "
+					if i == frame {
+						marker = "*"
+					}
+					fmt.Fprintf(out, "%s #%d %s at %s\n", marker, i, f.Name, f.Pos)
+				}
+			case "f", "frame":
+				n, err := strconv.Atoi(arg)
+				if err != nil || n < 0 || n >= len(d.Stack()) {
+					fmt.Fprintf(out, "invalid frame %q\n", arg)
+					continue
+				}
+				frame = n
+			case "l", "locals":
+				for _, scope := range d.Scopes(frame) {
+					fmt.Fprintf(out, "%s:\n", scope.Name)
+					for _, v := range scope.Variables {
+						fmt.Fprintf(out, "    %s = %s\n", v.Name, v.Value.Inspect())
+					}
+				}
+			case "p", "print":
+				result, err := d.Evaluate(arg, frame)
+				if err != nil {
+					fmt.Fprintf(out, "%s\n", err)
+					continue
+				}
+				fmt.Fprintln(out, result.Inspect())
+			case "", "h", "help":
+				io.WriteString(out, debugHelp)
+			default:
+				fmt.Fprintf(out, "unknown command %q, type help for a list\n", cmd)
+			}
+		}
+	}
+
+	if result := d.Run(program, env); result != nil {
+		io.WriteString(out, result.Inspect()+"\n")
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/internal/diagnostic"
 	"monkey/internal/eval"
 	"monkey/internal/lexer"
 	"monkey/internal/object"
 	"monkey/internal/parser"
+	"monkey/internal/resolver"
 	"strings"
 )
 
@@ -17,6 +19,7 @@ func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 
 	env := object.NewEnv()
+	r := resolver.New(eval.BuiltinNames())
 	for {
 		fmt.Printf(Prompt)
 		scanned := scanner.Scan()
@@ -29,6 +32,16 @@ func Start(in io.Reader, out io.Writer) {
 			return
 		}
 
+		if path, ok := strings.CutPrefix(strings.TrimSpace(line), ":debug"); ok {
+			if path = strings.TrimSpace(path); path == "" {
+				io.WriteString(out, "usage: :debug FILE\n")
+				continue
+			}
+
+			debugFile(path, scanner, out, env, r)
+			continue
+		}
+
 		l := lexer.New(line)
 		p := parser.New(l)
 		program := p.ParseProgram()
@@ -38,6 +51,15 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		if diags := r.Resolve(program); len(diags) != 0 {
+			io.WriteString(out, "RESOLVER DIAGNOSTICS:\n")
+			diagnostic.Print(out, diags)
+
+			if diagnostic.HasErrors(diags) {
+				continue
+			}
+		}
+
 		eval := eval.Eval(program, env)
 		if eval != nil {
 			io.WriteString(out, eval.Inspect()+"\n")
@@ -0,0 +1,295 @@
+package resolver
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
+	"monkey/internal/token"
+	"sort"
+)
+
+// scope holds the bindings of one environment: the global one or the one
+// created for a function call. Blocks of if expressions do not get their own
+// scope, just like they do not get their own environment at runtime.
+type scope struct {
+	slots map[string]int
+	decls []*ast.ID
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{slots: make(map[string]int), outer: outer}
+}
+
+func (s *scope) names() []string {
+	names := make([]string, len(s.decls))
+	for i, decl := range s.decls {
+		names[i] = decl.Value
+	}
+
+	return names
+}
+
+// Resolver assigns every identifier a lexical address (depth, slot) so the
+// evaluator can reach variables without hashing their names.
+type Resolver struct {
+	global   *scope
+	current  *scope
+	builtins map[string]bool
+	diags    []diagnostic.Diagnostic
+	decls    map[*ast.ID]*ast.ID
+}
+
+func New(builtins []string) *Resolver {
+	r := &Resolver{
+		global:   newScope(nil),
+		builtins: make(map[string]bool),
+	}
+
+	for _, name := range builtins {
+		r.builtins[name] = true
+	}
+
+	return r
+}
+
+// Resolve annotates the identifiers and function literals of program and
+// reports undefined variables as errors and shadowing as warnings.
+// Declarations stay in the global scope across calls, so a REPL can resolve
+// each line with the same Resolver it used for the previous ones.
+func (r *Resolver) Resolve(program *ast.Program) []diagnostic.Diagnostic {
+	r.current = r.global
+	r.diags = []diagnostic.Diagnostic{}
+	r.decls = make(map[*ast.ID]*ast.ID)
+
+	for _, stmt := range program.Statements {
+		r.hoist(stmt)
+	}
+
+	for _, stmt := range program.Statements {
+		r.resolve(stmt)
+	}
+
+	sort.SliceStable(r.diags, func(i, j int) bool {
+		return r.diags[i].Pos.Before(r.diags[j].Pos)
+	})
+
+	return r.diags
+}
+
+// hoist declares every let binding made directly in the current scope before
+// any identifier is resolved, so functions can refer to bindings made after
+// them, including themselves.
+func (r *Resolver) hoist(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		r.declare(node.Name)
+		r.hoist(node.Value)
+	case *ast.ReturnStatement:
+		r.hoist(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.hoist(node.Expression)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			r.hoist(stmt)
+		}
+	case *ast.PrefixExpression:
+		r.hoist(node.Right)
+	case *ast.InfixExpression:
+		r.hoist(node.Left)
+		r.hoist(node.Right)
+	case *ast.IfExpression:
+		r.hoist(node.Condition)
+		r.hoist(node.Consequence)
+		if node.Alternative != nil {
+			r.hoist(node.Alternative)
+		}
+	case *ast.CallExpression:
+		r.hoist(node.Function)
+		for _, arg := range node.Arguments {
+			r.hoist(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			r.hoist(e)
+		}
+	case *ast.IndexExpression:
+		r.hoist(node.Left)
+		r.hoist(node.Index)
+	case *ast.SliceExpression:
+		r.hoist(node.Left)
+		r.hoist(node.Start)
+		r.hoist(node.End)
+		r.hoist(node.Step)
+	case *ast.HashMapLiteral:
+		for _, pair := range node.Pairs {
+			r.hoist(pair.Key)
+			r.hoist(pair.Value)
+		}
+	}
+}
+
+func (r *Resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		r.resolve(node.Value)
+		r.bind(node.Name)
+	case *ast.ReturnStatement:
+		r.resolve(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			r.resolve(stmt)
+		}
+	case *ast.ID:
+		r.lookup(node)
+	case *ast.PrefixExpression:
+		r.resolve(node.Right)
+	case *ast.InfixExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Right)
+	case *ast.IfExpression:
+		r.resolve(node.Condition)
+		r.resolve(node.Consequence)
+		if node.Alternative != nil {
+			r.resolve(node.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		r.resolveFunction(node)
+	case *ast.CallExpression:
+		r.resolve(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolve(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			r.resolve(e)
+		}
+	case *ast.IndexExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Index)
+	case *ast.SliceExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Start)
+		r.resolve(node.End)
+		r.resolve(node.Step)
+	case *ast.HashMapLiteral:
+		for _, pair := range node.Pairs {
+			r.resolve(pair.Key)
+			r.resolve(pair.Value)
+		}
+	}
+}
+
+func (r *Resolver) resolveFunction(fn *ast.FunctionLiteral) {
+	r.current = newScope(r.current)
+	defer func() { r.current = r.current.outer }()
+
+	for _, param := range fn.Params {
+		if _, ok := r.current.slots[param.Value]; ok {
+			r.errorf(param.Token.Pos, "duplicate parameter %s", param.Value)
+			continue
+		}
+
+		r.declare(param)
+		r.bind(param)
+	}
+
+	if fn.Body == nil {
+		return
+	}
+
+	r.hoist(fn.Body)
+	r.resolve(fn.Body)
+
+	fn.Locals = r.current.names()
+}
+
+// declare adds id to the current scope unless it is already there.
+func (r *Resolver) declare(id *ast.ID) {
+	if id == nil {
+		return
+	}
+
+	if _, ok := r.current.slots[id.Value]; ok {
+		return
+	}
+
+	if decl := r.find(r.current.outer, id.Value); decl != nil {
+		r.warnf(id.Token.Pos, "%s shadows the variable declared at %s", id.Value, decl.Token.Pos)
+	} else if r.builtins[id.Value] {
+		r.warnf(id.Token.Pos, "%s shadows the builtin function of the same name", id.Value)
+	}
+
+	r.current.slots[id.Value] = len(r.current.decls)
+	r.current.decls = append(r.current.decls, id)
+}
+
+// bind addresses a declared id in the current scope.
+func (r *Resolver) bind(id *ast.ID) {
+	if id == nil {
+		return
+	}
+
+	id.Scope = ast.Local
+	id.Depth = 0
+	id.Slot = r.current.slots[id.Value]
+	r.decls[id] = r.current.decls[id.Slot]
+}
+
+func (r *Resolver) lookup(id *ast.ID) {
+	depth := 0
+	for s := r.current; s != nil; s = s.outer {
+		if slot, ok := s.slots[id.Value]; ok {
+			id.Scope = ast.Local
+			id.Depth = depth
+			id.Slot = slot
+			r.decls[id] = s.decls[slot]
+			return
+		}
+
+		depth++
+	}
+
+	if r.builtins[id.Value] {
+		id.Scope = ast.Builtin
+		return
+	}
+
+	r.errorf(id.Token.Pos, "undefined variable: %s", id.Value)
+}
+
+// Declaration returns the identifier that declared the variable id refers
+// to, which is id itself for the first declaration of a name. It only knows
+// the identifiers of the last program resolved, and returns nil for
+// builtins and undefined variables.
+func (r *Resolver) Declaration(id *ast.ID) *ast.ID {
+	return r.decls[id]
+}
+
+func (r *Resolver) find(s *scope, name string) *ast.ID {
+	for ; s != nil; s = s.outer {
+		if slot, ok := s.slots[name]; ok {
+			return s.decls[slot]
+		}
+	}
+
+	return nil
+}
+
+func (r *Resolver) errorf(pos token.Position, format string, args ...interface{}) {
+	r.report(pos, diagnostic.Error, format, args...)
+}
+
+func (r *Resolver) warnf(pos token.Position, format string, args ...interface{}) {
+	r.report(pos, diagnostic.Warning, format, args...)
+}
+
+func (r *Resolver) report(pos token.Position, severity diagnostic.Severity, format string, args ...interface{}) {
+	r.diags = append(r.diags, diagnostic.Diagnostic{
+		Pos:      pos,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
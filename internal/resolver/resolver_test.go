@@ -0,0 +1,183 @@
+package resolver_test
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	t.Run("lexical addresses", func(t *testing.T) {
+		input := `
+		let a = 1;
+		let f = fn(x, y) {
+			let z = x + y;
+			fn(w) { a + z + w + len };
+		};`
+
+		program := parse(t, input)
+		diags := resolver.New([]string{"len"}).Resolve(program)
+		if len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		type address struct {
+			scope ast.Scope
+			depth int
+			slot  int
+		}
+
+		expected := map[string]address{
+			"a":   {ast.Local, 2, 0},
+			"z":   {ast.Local, 1, 2},
+			"w":   {ast.Local, 0, 0},
+			"len": {ast.Builtin, 0, 0},
+		}
+
+		inner := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral).
+			Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+
+		var ids []*ast.ID
+		collectIDs(inner.Body, &ids)
+		if len(ids) != len(expected) {
+			t.Fatalf("wrong number of identifiers. got=%d", len(ids))
+		}
+
+		for _, id := range ids {
+			want := expected[id.Value]
+			got := address{id.Scope, id.Depth, id.Slot}
+			if got != want {
+				t.Errorf("wrong address for %s. got=%+v, want=%+v", id.Value, got, want)
+			}
+		}
+
+		outer := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+		if len(outer.Locals) != 3 || outer.Locals[2] != "z" {
+			t.Errorf("wrong locals. got=%v", outer.Locals)
+		}
+	})
+
+	t.Run("diagnostics", func(t *testing.T) {
+		tests := []struct {
+			input    string
+			expected []string
+		}{
+			{"foo;", []string{"1:1: error: undefined variable: foo"}},
+			{"let f = fn() { g() }; let g = fn() { 1 };", []string{}},
+			{"let f = fn() { f() };", []string{}},
+			{
+				"let x = 1; let f = fn(x) { x };",
+				[]string{"1:23: warning: x shadows the variable declared at 1:5"},
+			},
+			{
+				"let len = fn(x) { 1 };",
+				[]string{"1:5: warning: len shadows the builtin function of the same name"},
+			},
+			{
+				"fn(a, a) { a };",
+				[]string{"1:7: error: duplicate parameter a"},
+			},
+			{
+				"fn() { if (true) { let y = 1; } y };",
+				[]string{},
+			},
+			{
+				"let a = [1]; a[i:1:j];",
+				[]string{"1:16: error: undefined variable: i", "1:20: error: undefined variable: j"},
+			},
+		}
+
+		for _, tt := range tests {
+			diags := resolver.New([]string{"len"}).Resolve(parse(t, tt.input))
+			if len(diags) != len(tt.expected) {
+				t.Errorf("wrong number of diagnostics for %q. got=%v", tt.input, diags)
+				continue
+			}
+
+			for i, d := range diags {
+				if d.String() != tt.expected[i] {
+					t.Errorf("wrong diagnostic for %q. got=%q, want=%q", tt.input, d.String(), tt.expected[i])
+				}
+			}
+		}
+	})
+
+	t.Run("globals persist across calls", func(t *testing.T) {
+		r := resolver.New(nil)
+
+		if diags := r.Resolve(parse(t, "let a = 1; let b = 2;")); len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		program := parse(t, "b;")
+		if diags := r.Resolve(program); diagnostic.HasErrors(diags) {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		id := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ID)
+		if id.Scope != ast.Local || id.Depth != 0 || id.Slot != 1 {
+			t.Errorf("wrong address for b. got=%+v", id)
+		}
+	})
+
+	t.Run("declarations", func(t *testing.T) {
+		program := parse(t, "let a = 1; let f = fn(a) { a }; let a = a + len(f);")
+		r := resolver.New([]string{"len"})
+		r.Resolve(program)
+
+		first := program.Statements[0].(*ast.LetStatement).Name
+		fn := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+		param := fn.Params[0]
+		inner := fn.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ID)
+		again := program.Statements[2].(*ast.LetStatement)
+		sum := again.Value.(*ast.InfixExpression)
+		call := sum.Right.(*ast.CallExpression)
+
+		tests := []struct {
+			name     string
+			id       *ast.ID
+			expected *ast.ID
+		}{
+			{"declaration", first, first},
+			{"parameter", inner, param},
+			{"redeclaration", again.Name, first},
+			{"use", sum.Left.(*ast.ID), first},
+			{"builtin", call.Function.(*ast.ID), nil},
+		}
+
+		for _, tt := range tests {
+			if got := r.Declaration(tt.id); got != tt.expected {
+				t.Errorf("%s: wrong declaration. got=%v, expected=%v", tt.name, got, tt.expected)
+			}
+		}
+	})
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func collectIDs(node ast.Node, ids *[]*ast.ID) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			collectIDs(stmt, ids)
+		}
+	case *ast.ExpressionStatement:
+		collectIDs(node.Expression, ids)
+	case *ast.InfixExpression:
+		collectIDs(node.Left, ids)
+		collectIDs(node.Right, ids)
+	case *ast.ID:
+		*ids = append(*ids, node)
+	}
+}
@@ -0,0 +1,221 @@
+package tester
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// name returns how reports call the test of r.
+func (r Result) name() string {
+	if r.Name == "" {
+		return "<file>"
+	}
+
+	return r.Name
+}
+
+// location returns where r failed, or where its test is if that is not
+// known.
+func (r Result) location() string {
+	pos := r.FailPos
+	if pos.Line == 0 {
+		pos = r.Pos
+	}
+
+	return fmt.Sprintf("%s:%s", r.File, pos)
+}
+
+// details says where and why r failed. The failures of files that cannot
+// be run say where themselves.
+func (r Result) details() string {
+	if r.Name == "" {
+		return r.Failure
+	}
+
+	return r.location() + ": " + r.Failure
+}
+
+// WriteText writes results for people: every failed test with why it
+// failed and what it printed, every passed test too if verbose is set, a
+// line per file and a last line that says PASS or FAIL.
+func WriteText(w io.Writer, results []Result, verbose bool) error {
+	var b strings.Builder
+
+	failed := 0
+	for i, r := range results {
+		if r.Passed() {
+			if verbose {
+				fmt.Fprintf(&b, "--- PASS: %s (%s)\n", r.name(), r.Duration)
+			}
+		} else {
+			failed++
+			fmt.Fprintf(&b, "--- FAIL: %s (%s)\n", r.name(), r.Duration)
+			fmt.Fprintf(&b, "    %s\n", indent(r.details(), "    "))
+		}
+		if r.Output != "" && (verbose || !r.Passed()) {
+			fmt.Fprintf(&b, "    output:\n        %s\n", indent(strings.TrimSuffix(r.Output, "\n"), "        "))
+		}
+
+		if i == len(results)-1 || results[i+1].File != r.File {
+			writeFileLine(&b, results, r.File)
+		}
+	}
+
+	switch {
+	case len(results) == 0:
+		b.WriteString("no tests to run\n")
+	case failed == 0:
+		fmt.Fprintf(&b, "PASS: %d tests\n", len(results))
+	default:
+		fmt.Fprintf(&b, "FAIL: %d of %d tests failed\n", failed, len(results))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFileLine(b *strings.Builder, results []Result, file string) {
+	tests, failed := 0, 0
+	var d time.Duration
+	for _, r := range results {
+		if r.File == file {
+			tests++
+			d += r.Duration
+			if !r.Passed() {
+				failed++
+			}
+		}
+	}
+
+	if failed == 0 {
+		fmt.Fprintf(b, "ok    %s (%d tests, %s)\n", file, tests, d)
+	} else {
+		fmt.Fprintf(b, "FAIL  %s (%d of %d tests failed, %s)\n", file, failed, tests, d)
+	}
+}
+
+// indent indents all lines of s but the first with prefix.
+func indent(s, prefix string) string {
+	return strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes results in the JUnit XML format that CI servers read,
+// with a test suite per file.
+func WriteJUnit(w io.Writer, results []Result) error {
+	var suites junitSuites
+	var total time.Duration
+	var durations []time.Duration // of the suites
+
+	for i, r := range results {
+		if i == 0 || results[i-1].File != r.File {
+			suites.Suites = append(suites.Suites, junitSuite{Name: r.File})
+			durations = append(durations, 0)
+		}
+		suite := &suites.Suites[len(suites.Suites)-1]
+
+		c := junitCase{
+			Name:      r.name(),
+			Classname: strings.TrimSuffix(r.File, ".mk"),
+			File:      r.File,
+			Line:      r.Pos.Line,
+			Time:      seconds(r.Duration),
+			SystemOut: r.Output,
+		}
+		if !r.Passed() {
+			message, _, _ := strings.Cut(r.Failure, "\n")
+			c.Failure = &junitFailure{Message: message, Text: r.details()}
+			suite.Failures++
+			suites.Failures++
+		}
+
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+		suites.Tests++
+		durations[len(durations)-1] += r.Duration
+		total += r.Duration
+	}
+
+	suites.Time = seconds(total)
+	for i, d := range durations {
+		suites.Suites[i].Time = seconds(d)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteTAP writes results in the Test Anything Protocol, version 13, with
+// the details of failed tests in YAML blocks.
+func WriteTAP(w io.Writer, results []Result) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(results))
+	for i, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(&b, "ok %d - %s %s\n", i+1, r.File, r.name())
+			continue
+		}
+
+		fmt.Fprintf(&b, "not ok %d - %s %s\n", i+1, r.File, r.name())
+		b.WriteString("  ---\n")
+		fmt.Fprintf(&b, "  message: |\n    %s\n", indent(r.Failure, "    "))
+		if r.Name != "" {
+			fmt.Fprintf(&b, "  at: %s\n", r.location())
+		}
+		if r.Output != "" {
+			fmt.Fprintf(&b, "  output: |\n    %s\n", indent(strings.TrimSuffix(r.Output, "\n"), "    "))
+		}
+		b.WriteString("  ...\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
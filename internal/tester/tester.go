@@ -0,0 +1,295 @@
+// Package tester runs tests written in Monkey. Tests live in files whose
+// names end in _test.mk, and every top-level let that binds a function
+// without parameters to a name starting with test_ is a test. A test fails
+// if calling it results in an error, such as the ones the assert builtins
+// return.
+package tester
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"monkey/internal/ast"
+	"monkey/internal/cover"
+	"monkey/internal/diagnostic"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/object"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"monkey/internal/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of one test. A file that cannot be run at all
+// has a single result with an empty Name.
+type Result struct {
+	File string
+	Name string
+	Pos  token.Position // of the test's let
+
+	// Failure says why the test failed and is empty if it passed. FailPos
+	// is the statement that failed, if known.
+	Failure string
+	FailPos token.Position
+
+	Output   string // what the test printed
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool {
+	return r.Failure == ""
+}
+
+// Discover returns the test files in paths. Directories are searched
+// recursively, skipping hidden ones, and files are taken as they are.
+// Without paths it searches the current directory.
+func Discover(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				if name != path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if strings.HasSuffix(name, "_test.mk") {
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// Runner runs the tests of files.
+type Runner struct {
+	// Filter selects the tests to run by name. All tests run if it is nil.
+	Filter *regexp.Regexp
+
+	// Coverage, if set, records which parts of the test files run.
+	Coverage *cover.Coverage
+}
+
+func New() *Runner {
+	return &Runner{}
+}
+
+// RunFile runs the tests in src, the contents of the file called name. Every
+// test runs in an environment of its own, in which the top-level
+// statements of the file have run, so tests cannot see each other's
+// changes.
+func (r *Runner) RunFile(name, src string) []Result {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	diags := p.Diagnostics()
+	if len(diags) == 0 {
+		diags = resolver.New(eval.BuiltinNames()).Resolve(program)
+	}
+
+	if diagnostic.HasErrors(diags) {
+		var msgs []string
+		for _, d := range diags {
+			msgs = append(msgs, fmt.Sprintf("%s:%s", name, d))
+		}
+		return []Result{{File: name, Failure: strings.Join(msgs, "\n")}}
+	}
+
+	if r.Coverage != nil {
+		r.Coverage.Add(name, src, program)
+		r.Coverage.Start()
+		defer r.Coverage.Stop()
+	}
+
+	t := &failures{}
+	t.next = eval.SetTracer(t)
+	defer eval.SetTracer(t.next)
+
+	var results []Result
+	for _, test := range tests(program) {
+		if r.Filter != nil && !r.Filter.MatchString(test.Name.Value) {
+			continue
+		}
+
+		results = append(results, r.run(name, program, test, t))
+	}
+
+	return results
+}
+
+func (r *Runner) run(file string, program *ast.Program, test *ast.LetStatement, t *failures) Result {
+	result := Result{File: file, Name: test.Name.Value, Pos: test.Token.Pos}
+
+	var output bytes.Buffer
+	defer eval.SetOutput(eval.SetOutput(&output))
+
+	start := time.Now()
+	t.reset()
+
+	env := object.NewEnv()
+	failed, ok := eval.Eval(program, env).(*object.Error)
+	if ok {
+		result.Failure = "running the file failed: " + failed.Message
+	} else {
+		fn, ok := env.Get(test.Name.Value)
+		if !ok {
+			result.Failure = test.Name.Value + " was not defined"
+		} else if f, ok := fn.(*object.Function); ok && len(f.Params) != 0 {
+			result.Failure = fmt.Sprintf("test functions take no parameters, %s takes %d", test.Name.Value, len(f.Params))
+		} else if failed, ok := eval.Call(fn).(*object.Error); ok {
+			result.Failure = failed.Message
+		}
+	}
+
+	result.Duration = time.Since(start)
+	result.Output = output.String()
+	if !result.Passed() {
+		result.FailPos = t.failPos
+	}
+
+	return result
+}
+
+// tests returns the tests of program in source order.
+func tests(program *ast.Program) []*ast.LetStatement {
+	var tests []*ast.LetStatement
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, "test_") {
+			continue
+		}
+
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			tests = append(tests, let)
+		}
+	}
+
+	return tests
+}
+
+// failures is an eval.Tracer that remembers where an error came from: the
+// innermost statement that resulted in it, or the call of the builtin that
+// returned it. Errors are passed on unchanged, so an error that is not the
+// one remembered is new. It passes all events on to the tracer it
+// replaced.
+type failures struct {
+	next eval.Tracer
+
+	failed  *object.Error
+	failPos token.Position
+
+	stmts []token.Position // the statements in progress
+	calls []token.Position // where the calls in progress were made
+
+	// tailPos is the statement that just handed back a tail call, which is
+	// made after the statement is done.
+	tailPos token.Position
+}
+
+func (t *failures) reset() {
+	*t = failures{next: t.next}
+}
+
+func (t *failures) EnterNode(node ast.Node) {
+	if t.next != nil {
+		t.next.EnterNode(node)
+	}
+
+	if pos, ok := stmtPos(node); ok {
+		t.stmts = append(t.stmts, pos)
+		t.tailPos = token.Position{}
+	}
+}
+
+func (t *failures) ExitNode(node ast.Node, result object.Object) {
+	if t.next != nil {
+		t.next.ExitNode(node, result)
+	}
+
+	pos, ok := stmtPos(node)
+	if !ok {
+		return
+	}
+	t.stmts = t.stmts[:len(t.stmts)-1]
+
+	switch result := result.(type) {
+	case *object.Error:
+		t.fail(result, pos)
+	case *object.TailCall:
+		t.tailPos = pos
+	}
+}
+
+func (t *failures) EnterCall(fn object.Object, args []object.Object) {
+	if t.next != nil {
+		t.next.EnterCall(fn, args)
+	}
+
+	pos := t.tailPos
+	if pos == (token.Position{}) && len(t.stmts) > 0 {
+		pos = t.stmts[len(t.stmts)-1]
+	}
+	t.calls = append(t.calls, pos)
+	t.tailPos = token.Position{}
+}
+
+func (t *failures) ExitCall(fn object.Object, result object.Object) {
+	if t.next != nil {
+		t.next.ExitCall(fn, result)
+	}
+
+	pos := t.calls[len(t.calls)-1]
+	t.calls = t.calls[:len(t.calls)-1]
+
+	if err, ok := result.(*object.Error); ok {
+		t.fail(err, pos)
+	}
+}
+
+func (t *failures) fail(err *object.Error, pos token.Position) {
+	if err != t.failed {
+		t.failed = err
+		t.failPos = pos
+	}
+}
+
+func stmtPos(node ast.Node) (token.Position, bool) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token.Pos, true
+	case *ast.ReturnStatement:
+		return node.Token.Pos, true
+	case *ast.ExpressionStatement:
+		return node.Token.Pos, true
+	}
+
+	return token.Position{}, false
+}
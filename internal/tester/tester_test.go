@@ -0,0 +1,243 @@
+package tester_test
+
+import (
+	"bytes"
+	"monkey/internal/cover"
+	"monkey/internal/tester"
+	"monkey/internal/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const tests = `let double = fn(x) { x * 2 };
+let seen = [];
+
+let test_double = fn() {
+    assert_eq(double(2), 4);
+};
+
+let test_isolated = fn() {
+    push_mut(seen, 1);
+    assert_eq(len(seen), 1);
+};
+
+let test_isolated_too = fn() {
+    push_mut(seen, 2);
+    assert_eq(seen, [2]);
+};
+
+let test_fails = fn() {
+    puts("before");
+    let x = double(3);
+    assert_eq(x, 7, "double");
+    puts("after");
+};
+
+let test_handled_error = fn() {
+    let msg = assert_error(fn() { double("a") });
+    assert(len(msg) == 0, "unexpected message");
+};
+
+let test_params = fn(x) { x };
+let helper = fn() { assert(false) };
+`
+
+// run runs the tests in tests, with durations left out for comparisons.
+func run(t *testing.T, r *tester.Runner) []tester.Result {
+	t.Helper()
+
+	results := r.RunFile("math_test.mk", tests)
+	for i := range results {
+		results[i].Duration = 0
+	}
+
+	return results
+}
+
+func TestRunFile(t *testing.T) {
+	results := run(t, tester.New())
+
+	type outcome struct {
+		name, failure, at, output string
+	}
+
+	var got []outcome
+	for _, r := range results {
+		at := ""
+		if r.FailPos.Line != 0 {
+			at = r.FailPos.String()
+		}
+		got = append(got, outcome{r.Name, r.Failure, at, r.Output})
+	}
+
+	expected := []outcome{
+		{"test_double", "", "", ""},
+		{"test_isolated", "", "", ""},
+		{"test_isolated_too", "", "", ""},
+		{"test_fails", "assertion failed: double: 6 != 7", "21:5", "\"before\"\n"},
+		{"test_handled_error", "assertion failed: unexpected message", "27:5", ""},
+		{"test_params", "test functions take no parameters, test_params takes 1", "", ""},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got\n%q\nexpected\n%q", got, expected)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	r := tester.New()
+	r.Filter = regexp.MustCompile("isolated")
+
+	var names []string
+	for _, result := range run(t, r) {
+		names = append(names, result.Name)
+	}
+
+	if expected := []string{"test_isolated", "test_isolated_too"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("ran %v, expected %v", names, expected)
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	results := tester.New().RunFile("bad_test.mk", "let test_x = fn() { y };")
+
+	expected := []tester.Result{{File: "bad_test.mk", Failure: "bad_test.mk:1:21: error: undefined variable: y"}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("got %+v, expected %+v", results, expected)
+	}
+}
+
+func TestUndefinedTest(t *testing.T) {
+	results := tester.New().RunFile("early_test.mk", "return 1;\nlet test_a = fn() { assert(true) };")
+	for i := range results {
+		results[i].Duration = 0
+	}
+
+	expected := []tester.Result{{File: "early_test.mk", Name: "test_a", Pos: token.Position{Line: 2, Column: 1}, Failure: "test_a was not defined"}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("got %+v, expected %+v", results, expected)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	r := tester.New()
+	r.Filter = regexp.MustCompile("double")
+	r.Coverage = cover.New()
+	run(t, r)
+
+	// Only test_double ran.
+	s := r.Coverage.Summaries()[0]
+	if s.FunctionsCalled != 2 || s.Functions != 9 {
+		t.Errorf("%d of %d functions called, expected 2 of 9", s.FunctionsCalled, s.Functions)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a_test.mk", "b.mk", "sub/c_test.mk", ".hidden/d_test.mk"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := tester.Discover([]string{dir, filepath.Join(dir, "b.mk")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{filepath.Join(dir, "a_test.mk"), filepath.Join(dir, "sub/c_test.mk"), filepath.Join(dir, "b.mk")}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("found %v, expected %v", files, expected)
+	}
+}
+
+func TestReports(t *testing.T) {
+	r := tester.New()
+	r.Filter = regexp.MustCompile("double|fails")
+	results := run(t, r)
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := tester.WriteText(&buf, results, false); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `--- FAIL: test_fails (0s)
+    math_test.mk:21:5: assertion failed: double: 6 != 7
+    output:
+        "before"
+FAIL  math_test.mk (1 of 2 tests failed, 0s)
+FAIL: 1 of 2 tests failed
+`
+		if buf.String() != expected {
+			t.Errorf("got\n%s\nexpected\n%s", buf.String(), expected)
+		}
+	})
+
+	t.Run("verbose", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := tester.WriteText(&buf, results[:1], true); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "--- PASS: test_double (0s)\nok    math_test.mk (1 tests, 0s)\nPASS: 1 tests\n"
+		if buf.String() != expected {
+			t.Errorf("got\n%s\nexpected\n%s", buf.String(), expected)
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := tester.WriteJUnit(&buf, results); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="2" failures="1" time="0.000">
+  <testsuite name="math_test.mk" tests="2" failures="1" time="0.000">
+    <testcase name="test_double" classname="math_test" file="math_test.mk" line="4" time="0.000"></testcase>
+    <testcase name="test_fails" classname="math_test" file="math_test.mk" line="18" time="0.000">
+      <failure message="assertion failed: double: 6 != 7">math_test.mk:21:5: assertion failed: double: 6 != 7</failure>
+      <system-out>&#34;before&#34;&#xA;</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+		if buf.String() != expected {
+			t.Errorf("got\n%s\nexpected\n%s", buf.String(), expected)
+		}
+	})
+
+	t.Run("tap", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := tester.WriteTAP(&buf, results); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.Join([]string{
+			"TAP version 13",
+			"1..2",
+			"ok 1 - math_test.mk test_double",
+			"not ok 2 - math_test.mk test_fails",
+			"  ---",
+			"  message: |",
+			"    assertion failed: double: 6 != 7",
+			"  at: math_test.mk:21:5",
+			"  output: |",
+			`    "before"`,
+			"  ...",
+			"",
+		}, "\n")
+		if buf.String() != expected {
+			t.Errorf("got\n%s\nexpected\n%s", buf.String(), expected)
+		}
+	})
+}
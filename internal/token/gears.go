@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 var keywords = map[string]TokenType{
 	"fn":     FUNC,
 	"let":    LET,
@@ -44,6 +46,8 @@ func (t TokenType) String() string {
 		return "=="
 	case NOT_EQ:
 		return "!="
+	case ARROW:
+		return "->"
 	case LT:
 		return "<"
 	case GT:
@@ -86,3 +90,20 @@ func (t TokenType) String() string {
 		return "UNKNOWN"
 	}
 }
+
+// MarshalText encodes t as its String, so tokens read well in JSON.
+func (t TokenType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes what MarshalText encodes.
+func (t *TokenType) UnmarshalText(text []byte) error {
+	for tt := INVALID; tt <= STRING; tt++ {
+		if tt.String() == string(text) {
+			*t = tt
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown token type %q", text)
+}
@@ -1,12 +1,40 @@
 package token
 
+import "fmt"
+
 type TokenType uint
 
+// Position is the 1-based line and column of a token's first character,
+// counted in runes.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Before reports whether p comes before q in the source.
+func (p Position) Before(q Position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
+
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Pos     Position  `json:"pos"`
+}
+
+// Comment is a // comment, which the lexer skips but records. Text includes
+// the slashes. Trailing is set when the comment follows a token on the same
+// line.
+type Comment struct {
+	Pos      Position
+	Text     string
+	Trailing bool
 }
 
 func New(t TokenType, l rune) Token {
-	return Token{t, string(l)}
+	return Token{Type: t, Literal: string(l)}
 }
@@ -18,6 +18,7 @@ const (
 	// COMBINED OPERATORS
 	EQ     // ==
 	NOT_EQ // !=
+	ARROW  // ->
 
 	LT // <
 	GT // >
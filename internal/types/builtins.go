@@ -0,0 +1,63 @@
+package types
+
+// builtins gives the types of the builtin functions with a fixed number
+// of parameters of fixed types. Each call gets fresh variables from fresh.
+// The others, which take optional arguments or callbacks with optional
+// parameters, have type any.
+var builtins = map[string]func(fresh func() Type) Type{
+	"len":   fn1(Any, Int),
+	"first": elementOf,
+	"last":  elementOf,
+	"rest": func(fresh func() Type) Type {
+		a := &Array{Element: fresh()}
+		return &Function{Params: []Type{a}, Return: a}
+	},
+	"keys": func(fresh func() Type) Type {
+		h := &HashMap{Key: fresh(), Value: fresh()}
+		return &Function{Params: []Type{h}, Return: &Array{Element: h.Key}}
+	},
+	"values": func(fresh func() Type) Type {
+		h := &HashMap{Key: fresh(), Value: fresh()}
+		return &Function{Params: []Type{h}, Return: &Array{Element: h.Value}}
+	},
+	"has": func(fresh func() Type) Type {
+		h := &HashMap{Key: fresh(), Value: fresh()}
+		return &Function{Params: []Type{h, h.Key}, Return: Bool}
+	},
+	"split":       fn2(String, String, &Array{Element: String}),
+	"join":        fn2(&Array{Element: String}, String, String),
+	"upper":       fn1(String, String),
+	"lower":       fn1(String, String),
+	"contains":    fn2(String, String, Bool),
+	"starts_with": fn2(String, String, Bool),
+	"ends_with":   fn2(String, String, Bool),
+	"index_of":    fn2(String, String, Int),
+	"chars":       fn1(String, &Array{Element: String}),
+	"repeat":      fn2(String, Int, String),
+}
+
+func fn1(param, result Type) func(func() Type) Type {
+	return func(func() Type) Type {
+		return &Function{Params: []Type{param}, Return: result}
+	}
+}
+
+func fn2(param1, param2, result Type) func(func() Type) Type {
+	return func(func() Type) Type {
+		return &Function{Params: []Type{param1, param2}, Return: result}
+	}
+}
+
+func elementOf(fresh func() Type) Type {
+	e := fresh()
+	return &Function{Params: []Type{&Array{Element: e}}, Return: e}
+}
+
+// builtin returns the type of the builtin function name.
+func (c *Checker) builtin(name string) Type {
+	if typ, ok := builtins[name]; ok {
+		return typ(func() Type { return c.fresh() })
+	}
+
+	return Any
+}
@@ -0,0 +1,669 @@
+package types
+
+import (
+	"fmt"
+	"monkey/internal/ast"
+	"monkey/internal/diagnostic"
+	"monkey/internal/resolver"
+	"monkey/internal/token"
+	"sort"
+)
+
+// Checker infers the types of a program resolved by a resolver.
+type Checker struct {
+	resolver *resolver.Resolver
+
+	bindings map[*ast.ID]*binding
+	types    map[ast.Expression]Type
+
+	// level is the number of let bindings of functions being checked.
+	level int
+	trail []change
+	fn    *function
+
+	diags []diagnostic.Diagnostic
+}
+
+// binding is a variable, keyed in Checker.bindings by the identifier that
+// declared it.
+type binding struct {
+	scheme scheme
+	// declared is the annotation of the first declaration, which every
+	// value the variable is bound to must match.
+	declared Type
+	// defined is set once a let binding has been checked and used if the
+	// variable is referred to before that.
+	defined, used bool
+}
+
+// function is a function literal being checked.
+type function struct {
+	name     string
+	declared Type
+	returns  []Type
+}
+
+// New returns a checker for programs r has resolved.
+func New(r *resolver.Resolver) *Checker {
+	return &Checker{
+		resolver: r,
+		bindings: make(map[*ast.ID]*binding),
+		types:    make(map[ast.Expression]Type),
+	}
+}
+
+// Check checks program and returns its type errors in source order.
+func (c *Checker) Check(program *ast.Program) []diagnostic.Diagnostic {
+	c.diags = nil
+
+	for _, stmt := range program.Statements {
+		c.hoist(stmt)
+	}
+	c.statements(program.Statements)
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[i].Pos.Before(c.diags[j].Pos)
+	})
+
+	return c.diags
+}
+
+// TypeOf returns the type inferred for e, or nil if e was not checked. The
+// names of let bindings and parameters have types too.
+func (c *Checker) TypeOf(e ast.Expression) Type {
+	return c.types[e]
+}
+
+// hoist declares the variables bound by let in the function or program
+// node belongs to before any of them is checked, so they can be referred
+// to before they are bound, as the resolver allows.
+func (c *Checker) hoist(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if c.declaration(node.Name) == node.Name {
+			b := &binding{}
+			if node.Name.Type != nil {
+				b.declared = c.annotation(node.Name.Type)
+				b.scheme = mono(b.declared)
+			} else {
+				b.scheme = mono(c.fresh())
+			}
+			c.bindings[node.Name] = b
+		}
+		c.hoist(node.Value)
+	case *ast.ReturnStatement:
+		c.hoist(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.hoist(node.Expression)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			c.hoist(stmt)
+		}
+	case *ast.PrefixExpression:
+		c.hoist(node.Right)
+	case *ast.InfixExpression:
+		c.hoist(node.Left)
+		c.hoist(node.Right)
+	case *ast.IfExpression:
+		c.hoist(node.Condition)
+		c.hoist(node.Consequence)
+		if node.Alternative != nil {
+			c.hoist(node.Alternative)
+		}
+	case *ast.CallExpression:
+		c.hoist(node.Function)
+		for _, arg := range node.Arguments {
+			c.hoist(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			c.hoist(e)
+		}
+	case *ast.IndexExpression:
+		c.hoist(node.Left)
+		c.hoist(node.Index)
+	case *ast.SliceExpression:
+		c.hoist(node.Left)
+		if node.Start != nil {
+			c.hoist(node.Start)
+		}
+		if node.End != nil {
+			c.hoist(node.End)
+		}
+		if node.Step != nil {
+			c.hoist(node.Step)
+		}
+	case *ast.HashMapLiteral:
+		for _, pair := range node.Pairs {
+			c.hoist(pair.Key)
+			c.hoist(pair.Value)
+		}
+	}
+}
+
+// declaration returns the identifier that declared the variable id refers
+// to. Without a resolved declaration, id stands for itself.
+func (c *Checker) declaration(id *ast.ID) *ast.ID {
+	if decl := c.resolver.Declaration(id); decl != nil {
+		return decl
+	}
+
+	return id
+}
+
+// statements checks stmts and returns the type of their value, which is
+// that of the last one, or nil if they always return.
+func (c *Checker) statements(stmts []ast.Statement) Type {
+	var typ Type = Null
+	returns := false
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			c.let(stmt)
+			typ = Null
+		case *ast.ReturnStatement:
+			c.ret(stmt)
+			returns = true
+		case *ast.ExpressionStatement:
+			typ = c.expr(stmt.Expression)
+			if typ == nil {
+				returns = true
+			}
+		}
+	}
+
+	if returns {
+		return nil
+	}
+
+	return typ
+}
+
+func (c *Checker) let(stmt *ast.LetStatement) {
+	decl := c.declaration(stmt.Name)
+	b := c.bindings[decl]
+	if b == nil {
+		b = &binding{scheme: mono(c.fresh())}
+		c.bindings[decl] = b
+	}
+
+	declared := b.declared
+	if stmt.Name != decl && stmt.Name.Type != nil {
+		declared = c.annotation(stmt.Name.Type)
+	}
+
+	if declared != nil {
+		c.expect(declared, c.value(stmt.Value), pos(stmt.Value), "the declaration of %s", stmt.Name.Value)
+		b.scheme = mono(declared)
+		b.defined = true
+		c.types[stmt.Name] = b.scheme.typ
+		return
+	}
+
+	// The value is checked as if it was bound one level further in, so the
+	// types only it constrains can be generalized, whether it is a function
+	// literal or e.g. another name for a polymorphic function. A function's
+	// recursive calls see the same type as the function, and uses of the
+	// variable before its declaration share the type too.
+	c.level++
+	var typ Type
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		if b.defined || !b.used {
+			b.scheme = mono(c.fresh())
+		}
+
+		typ = c.expr(fn)
+		c.tryUnify(b.scheme.typ, typ)
+	} else {
+		typ = c.value(stmt.Value)
+		if b.used && !b.defined {
+			c.tryUnify(b.scheme.typ, typ)
+		}
+	}
+	c.level--
+
+	b.scheme = c.generalize(typ)
+	b.defined = true
+	c.types[stmt.Name] = b.scheme.typ
+}
+
+func (c *Checker) ret(stmt *ast.ReturnStatement) {
+	typ := c.value(stmt.ReturnValue)
+
+	switch {
+	case c.fn == nil:
+	case c.fn.declared != nil:
+		c.expect(c.fn.declared, typ, pos(stmt.ReturnValue), "the return value of %s", c.fn.name)
+	default:
+		c.fn.returns = append(c.fn.returns, typ)
+	}
+}
+
+// value returns the type of e, which is any if e never produces a value.
+func (c *Checker) value(e ast.Expression) Type {
+	if typ := c.expr(e); typ != nil {
+		return typ
+	}
+
+	return Any
+}
+
+// expr checks e and returns its type, or nil if evaluating e always
+// returns from the function it is in.
+func (c *Checker) expr(e ast.Expression) Type {
+	typ := c.infer(e)
+	if typ != nil {
+		c.types[e] = typ
+	}
+
+	return typ
+}
+
+func (c *Checker) infer(e ast.Expression) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.StringLiteral:
+		return String
+	case *ast.BooleanExpression:
+		return Bool
+	case *ast.ID:
+		return c.id(e)
+	case *ast.PrefixExpression:
+		return c.prefix(e)
+	case *ast.InfixExpression:
+		return c.infix(e)
+	case *ast.IfExpression:
+		c.value(e.Condition)
+		cons := c.statements(e.Consequence.Statements)
+		var alt Type = Null
+		if e.Alternative != nil {
+			alt = c.statements(e.Alternative.Statements)
+		}
+
+		return c.join(cons, alt)
+	case *ast.FunctionLiteral:
+		return c.function(e)
+	case *ast.CallExpression:
+		return c.call(e)
+	case *ast.ArrayLiteral:
+		elements := make([]Type, len(e.Elements))
+		for i, el := range e.Elements {
+			elements[i] = c.value(el)
+		}
+
+		if len(elements) == 0 {
+			return &Array{Element: c.fresh()}
+		}
+		return &Array{Element: c.join(elements...)}
+	case *ast.HashMapLiteral:
+		if len(e.Pairs) == 0 {
+			return &HashMap{Key: c.fresh(), Value: c.fresh()}
+		}
+
+		keys := make([]Type, len(e.Pairs))
+		values := make([]Type, len(e.Pairs))
+		for i, pair := range e.Pairs {
+			keys[i] = c.value(pair.Key)
+			if k := kind(keys[i]); k == "hash" || k == "fn" {
+				c.errorf(pos(pair.Key), "unusable as hash key: %s", keys[i])
+			}
+			values[i] = c.value(pair.Value)
+		}
+
+		return &HashMap{Key: c.join(keys...), Value: c.join(values...)}
+	case *ast.IndexExpression:
+		return c.index(e)
+	case *ast.SliceExpression:
+		return c.slice(e)
+	}
+
+	return Any
+}
+
+func (c *Checker) id(id *ast.ID) Type {
+	if id.Scope == ast.Builtin {
+		return c.builtin(id.Value)
+	}
+
+	b := c.bindings[c.declaration(id)]
+	if b == nil {
+		return Any
+	}
+	if !b.defined {
+		b.used = true
+	}
+
+	return c.instantiate(b.scheme)
+}
+
+func (c *Checker) prefix(e *ast.PrefixExpression) Type {
+	right := c.value(e.Right)
+	if e.Operator == "!" {
+		return Bool
+	}
+
+	switch t := prune(right).(type) {
+	case anyType, *variable:
+		return t
+	case Basic:
+		if t == Int || t == Float {
+			return t
+		}
+	}
+
+	c.errorf(e.Token.Pos, "unknown operator: %s%s", e.Operator, right)
+	return Any
+}
+
+func (c *Checker) infix(e *ast.InfixExpression) Type {
+	left, right := c.value(e.Left), c.value(e.Right)
+
+	op := e.Operator
+	if op == "==" || op == "!=" {
+		return Bool
+	}
+
+	compare := op == "<" || op == ">"
+	result := func(t Type) Type {
+		if compare {
+			return Bool
+		}
+		return t
+	}
+
+	l, r := prune(left), prune(right)
+	if l == Any || r == Any {
+		return result(Any)
+	}
+
+	// A type not known yet is taken to be that of the other operand.
+	_, lvar := l.(*variable)
+	_, rvar := r.(*variable)
+	if lvar || rvar {
+		if !c.tryUnify(l, r) {
+			return result(Any)
+		}
+		if l = prune(l); kind(l) == "" {
+			return result(l)
+		}
+		r = l
+	}
+
+	lb, _ := l.(Basic)
+	rb, _ := r.(Basic)
+	switch {
+	case isNumber(l) && isNumber(r):
+		if lb == Float || rb == Float {
+			return result(Float)
+		}
+		return result(Int)
+	case lb == String && rb == String && (op == "+" || compare):
+		return result(String)
+	case kind(l) != kind(r):
+		c.errorf(e.Token.Pos, "type mismatch: %s", c.describe("%s "+op+" %s", l, r))
+	default:
+		c.errorf(e.Token.Pos, "unknown operator: %s", c.describe("%s "+op+" %s", l, r))
+	}
+
+	return Any
+}
+
+func isNumber(t Type) bool {
+	return t == Int || t == Float
+}
+
+func (c *Checker) function(fn *ast.FunctionLiteral) Type {
+	typ := &Function{Params: make([]Type, len(fn.Params))}
+	for i, param := range fn.Params {
+		if param.Type != nil {
+			typ.Params[i] = c.annotation(param.Type)
+		} else {
+			typ.Params[i] = c.fresh()
+		}
+
+		b := &binding{scheme: mono(typ.Params[i]), defined: true}
+		c.bindings[param] = b
+		c.types[param] = typ.Params[i]
+	}
+
+	f := &function{name: fn.Name}
+	if f.name == "" {
+		f.name = "the function"
+	}
+	if fn.ReturnType != nil {
+		f.declared = c.annotation(fn.ReturnType)
+		typ.Return = f.declared
+	} else {
+		typ.Return = c.fresh()
+	}
+
+	outer := c.fn
+	c.fn = f
+	c.hoist(fn.Body)
+	body := c.statements(fn.Body.Statements)
+	c.fn = outer
+
+	if body != nil {
+		if f.declared != nil {
+			c.expect(f.declared, body, fallthroughPos(fn.Body), "the return value of %s", f.name)
+		} else {
+			f.returns = append(f.returns, body)
+		}
+	}
+
+	if f.declared == nil {
+		// Unlike unify, this makes the result any if the values returned
+		// have no common type.
+		joined := c.join(f.returns...)
+		if v, ok := prune(typ.Return).(*variable); ok && joined != nil {
+			c.bind(v, joined)
+		} else if joined != nil {
+			c.tryUnify(typ.Return, joined)
+		}
+	}
+
+	return typ
+}
+
+// fallthroughPos returns where the value a block ends with starts, or
+// where the block ends if it does not end with an expression.
+func fallthroughPos(block *ast.BlockStatement) token.Position {
+	if n := len(block.Statements); n > 0 {
+		if stmt, ok := block.Statements[n-1].(*ast.ExpressionStatement); ok {
+			return pos(stmt.Expression)
+		}
+	}
+
+	return block.End
+}
+
+func (c *Checker) call(call *ast.CallExpression) Type {
+	callee := c.value(call.Function)
+	args := make([]Type, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		args[i] = c.value(arg)
+	}
+
+	name := "the function"
+	if id, ok := call.Function.(*ast.ID); ok {
+		name = id.Value
+	}
+
+	switch fn := prune(callee).(type) {
+	case anyType:
+		return Any
+	case *variable:
+		ret := c.fresh()
+		if !c.tryUnify(fn, &Function{Params: args, Return: ret}) {
+			return Any
+		}
+		return ret
+	case *Function:
+		if len(args) != len(fn.Params) {
+			c.errorf(pos(call.Function), "%s takes %s, but is called with %d", name, arguments(len(fn.Params)), len(args))
+			return fn.Return
+		}
+
+		for i, arg := range args {
+			c.expect(fn.Params[i], arg, pos(call.Arguments[i]), "argument %d to %s", i+1, name)
+		}
+		return fn.Return
+	}
+
+	c.errorf(pos(call.Function), "not a function: %s", callee)
+	return Any
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+
+	return fmt.Sprintf("%d arguments", n)
+}
+
+func (c *Checker) index(e *ast.IndexExpression) Type {
+	left := c.value(e.Left)
+	index := c.value(e.Index)
+
+	switch l := prune(left).(type) {
+	case anyType, *variable:
+		return Any
+	case *Array:
+		c.expect(Int, index, pos(e.Index), "an index")
+		return l.Element
+	case *HashMap:
+		c.expect(l.Key, index, pos(e.Index), "a hash key")
+		return l.Value
+	case Basic:
+		if l == String {
+			c.expect(Int, index, pos(e.Index), "an index")
+			return String
+		}
+	}
+
+	c.errorf(pos(e.Left), "index operator not supported: %s", left)
+	return Any
+}
+
+func (c *Checker) slice(e *ast.SliceExpression) Type {
+	left := c.value(e.Left)
+	for _, bound := range []ast.Expression{e.Start, e.End, e.Step} {
+		if bound != nil {
+			c.expect(Int, c.value(bound), pos(bound), "a slice bound")
+		}
+	}
+
+	switch l := prune(left).(type) {
+	case anyType, *variable, *Array:
+		return l
+	case Basic:
+		if l == String {
+			return l
+		}
+	}
+
+	c.errorf(pos(e.Left), "slice operator not supported: %s", left)
+	return Any
+}
+
+// annotation returns the type an annotation stands for.
+func (c *Checker) annotation(t ast.Type) Type {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		if typ, ok := named[t.Name]; ok {
+			return typ
+		}
+
+		c.errorf(t.Token.Pos, "unknown type %s", t.Name)
+	case *ast.ArrayType:
+		return &Array{Element: c.annotation(t.Element)}
+	case *ast.HashMapType:
+		return &HashMap{Key: c.annotation(t.Key), Value: c.annotation(t.Value)}
+	case *ast.FunctionType:
+		typ := &Function{Params: make([]Type, len(t.Params)), Return: c.annotation(t.Return)}
+		for i, p := range t.Params {
+			typ.Params[i] = c.annotation(p)
+		}
+
+		return typ
+	}
+
+	return Any
+}
+
+var named = map[string]Type{
+	"int":    Int,
+	"float":  Float,
+	"string": String,
+	"bool":   Bool,
+	"null":   Null,
+	"any":    Any,
+}
+
+// expect reports an error at the given position unless got can be used as want. what
+// describes where the value is used.
+func (c *Checker) expect(want, got Type, at token.Position, what string, args ...interface{}) {
+	if c.tryUnify(want, got) {
+		return
+	}
+
+	c.errorf(at, "cannot use %s in %s", c.describe("%s as %s", got, want), fmt.Sprintf(what, args...))
+}
+
+// describe formats types with the names of their variables shared.
+func (c *Checker) describe(format string, types ...Type) string {
+	n := newNamer()
+	args := make([]interface{}, len(types))
+	for i, t := range types {
+		args[i] = n.name(t)
+	}
+
+	return fmt.Sprintf(format, args...)
+}
+
+func (c *Checker) errorf(pos token.Position, format string, args ...interface{}) {
+	c.diags = append(c.diags, diagnostic.Diagnostic{
+		Pos:      pos,
+		Severity: diagnostic.Error,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// pos returns where e starts.
+func pos(e ast.Expression) token.Position {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return pos(e.Left)
+	case *ast.CallExpression:
+		return pos(e.Function)
+	case *ast.IndexExpression:
+		return pos(e.Left)
+	case *ast.SliceExpression:
+		return pos(e.Left)
+	case *ast.ID:
+		return e.Token.Pos
+	case *ast.IntegerLiteral:
+		return e.Token.Pos
+	case *ast.StringLiteral:
+		return e.Token.Pos
+	case *ast.BooleanExpression:
+		return e.Token.Pos
+	case *ast.PrefixExpression:
+		return e.Token.Pos
+	case *ast.IfExpression:
+		return e.Token.Pos
+	case *ast.FunctionLiteral:
+		return e.Token.Pos
+	case *ast.ArrayLiteral:
+		return e.Token.Pos
+	case *ast.HashMapLiteral:
+		return e.Token.Pos
+	case *ast.BadExpression:
+		return e.Token.Pos
+	}
+
+	return token.Position{}
+}
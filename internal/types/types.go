@@ -0,0 +1,157 @@
+// Package types infers the types of Monkey programs and reports the
+// operations that would fail with a type error when they run.
+//
+// Annotations are optional. Unannotated parameters and bindings get their
+// types by Hindley-Milner inference, so functions bound by let can be used
+// at several types:
+//
+//	let id = fn(x) { x };              // fn('a) -> 'a
+//	let add = fn(a: int, b) { a + b }; // fn(int, int) -> int
+//
+// The type any opts out of checking: it is compatible with every type, in
+// both directions. Where inference cannot find a single type, for an array
+// with elements of different types or a function returning different
+// types, it falls back to any instead of reporting an error, so programs
+// that run fine are not rejected.
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the type of a Monkey value.
+type Type interface {
+	String() string
+}
+
+// Basic is the type of scalar values.
+type Basic int
+
+const (
+	Int Basic = iota
+	Float
+	String
+	Bool
+	Null
+)
+
+var basicNames = [...]string{
+	Int:    "int",
+	Float:  "float",
+	String: "string",
+	Bool:   "bool",
+	Null:   "null",
+}
+
+func (b Basic) String() string { return basicNames[b] }
+
+type anyType struct{}
+
+func (anyType) String() string { return "any" }
+
+// Any is the type of values that are not checked.
+var Any Type = anyType{}
+
+// Array is the type of arrays whose elements have type Element.
+type Array struct {
+	Element Type
+}
+
+func (a *Array) String() string { return typeString(a) }
+
+// HashMap is the type of hash maps from Key to Value.
+type HashMap struct {
+	Key   Type
+	Value Type
+}
+
+func (h *HashMap) String() string { return typeString(h) }
+
+// Function is the type of functions.
+type Function struct {
+	Params []Type
+	Return Type
+}
+
+func (f *Function) String() string { return typeString(f) }
+
+// variable is a type inference has not found yet. Once it has, bound holds
+// it. level is the nesting of let bindings the variable was created in, so
+// generalization can tell which variables are local to a binding.
+type variable struct {
+	level int
+	bound Type
+}
+
+func (v *variable) String() string { return typeString(v) }
+
+// prune follows bound variables to the type they stand for.
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*variable)
+		if !ok || v.bound == nil {
+			return t
+		}
+		t = v.bound
+	}
+}
+
+// typeString renders t with its unknown parts named 'a, 'b and so on.
+func typeString(t Type) string {
+	return newNamer().name(t)
+}
+
+// namer renders types with consistent names for their variables, so the
+// types in one message can share them.
+type namer map[*variable]string
+
+func newNamer() namer {
+	return make(namer)
+}
+
+func (n namer) name(t Type) string {
+	switch t := prune(t).(type) {
+	case *variable:
+		if name, ok := n[t]; ok {
+			return name
+		}
+
+		name := "'" + string(rune('a'+len(n)%26))
+		if len(n) >= 26 {
+			name += fmt.Sprint(len(n) / 26)
+		}
+		n[t] = name
+		return name
+	case *Array:
+		return "[" + n.name(t.Element) + "]"
+	case *HashMap:
+		return "{" + n.name(t.Key) + ": " + n.name(t.Value) + "}"
+	case *Function:
+		params := make([]string, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = n.name(p)
+		}
+
+		return "fn(" + strings.Join(params, ", ") + ") -> " + n.name(t.Return)
+	default:
+		return t.String()
+	}
+}
+
+// kind names the runtime type of values of type t, which decides between
+// "type mismatch" and "unknown operator" errors like the evaluator does.
+func kind(t Type) string {
+	switch t := prune(t).(type) {
+	case Basic:
+		return t.String()
+	case *Array:
+		return "array"
+	case *HashMap:
+		return "hash"
+	case *Function:
+		return "fn"
+	}
+
+	return ""
+}
@@ -0,0 +1,208 @@
+package types_test
+
+import (
+	"monkey/internal/ast"
+	"monkey/internal/eval"
+	"monkey/internal/lexer"
+	"monkey/internal/parser"
+	"monkey/internal/resolver"
+	"monkey/internal/types"
+	"reflect"
+	"testing"
+)
+
+func check(t *testing.T, src string) (*ast.Program, *types.Checker, []string) {
+	t.Helper()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		t.Fatalf("parser errors: %v", p.Diagnostics())
+	}
+
+	r := resolver.New(eval.BuiltinNames())
+	r.Resolve(program)
+
+	c := types.New(r)
+	var errors []string
+	for _, d := range c.Check(program) {
+		errors = append(errors, d.String())
+	}
+
+	return program, c, errors
+}
+
+func TestInference(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"let x = 1;", "int"},
+		{`let x = "a" + "b";`, "string"},
+		{"let x = 1 < 2;", "bool"},
+		{"let x = [1, 2];", "[int]"},
+		{`let x = [1, "a"];`, "[any]"},
+		{"let x = [];", "['a]"},
+		{`let x = {"a": [true]};`, "{string: [bool]}"},
+		{"let x = fn(a) { a };", "fn('a) -> 'a"},
+		{"let x = fn(a, b) { a + b };", "fn('a, 'a) -> 'a"},
+		{"let x = fn(a) { a + 1 };", "fn(int) -> int"},
+		{"let x = fn(f, a) { f(a) };", "fn(fn('a) -> 'b, 'a) -> 'b"},
+		{"let x = fn(n) { if (n < 2) { return n; } x(n - 1) * n };", "fn(int) -> int"},
+		{`let x = fn(b) { if (b) { 1 } else { "one" } };`, "fn('a) -> any"},
+		{"let x = fn(b) { if (b) { 1 } };", "fn('a) -> any"},
+		{"let x = fn(a: int, b: string) -> [string] { [b] };", "fn(int, string) -> [string]"},
+		{"let id = fn(a) { a }; let x = [id(1), id(2)];", "[int]"},
+		{"let id = fn(a) { a }; let x = id;", "fn('a) -> 'a"},
+		{`let p = fn(a) { a }; let q = p; let x = q(1) + 1; q("a");`, "int"},
+		{"let x = first([[1]]);", "[int]"},
+		{`let x = keys({"a": 1});`, "[string]"},
+		{"let x = map([1], fn(a) { a });", "any"},
+		{"let x = len;", "fn(any) -> int"},
+		{`let x = "abc"[1];`, "string"},
+		{"let x = [1, 2, 3][1:];", "[int]"},
+		{"let x = y + 1; let y = 2;", "int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			program, c, errors := check(t, tt.src)
+			if errors != nil {
+				t.Fatalf("unexpected errors: %q", errors)
+			}
+
+			var x *ast.LetStatement
+			for _, stmt := range program.Statements {
+				if let, ok := stmt.(*ast.LetStatement); ok && let.Name.Value == "x" {
+					x = let
+				}
+			}
+
+			if got := c.TypeOf(x.Name).String(); got != tt.expected {
+				t.Errorf("x has type %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected []string
+	}{
+		{
+			"operators",
+			`1 + "a"; "a" - "b"; -"a"; [1] + [2]; true == 1;`,
+			[]string{
+				"1:3: error: type mismatch: int + string",
+				"1:14: error: unknown operator: string - string",
+				"1:21: error: unknown operator: -string",
+				"1:31: error: unknown operator: [int] + [int]",
+			},
+		},
+		{
+			"inferred operands",
+			`let f = fn(a) { a + 1 }; f("a"); let g = fn(a) { a }; g("a") + 1;`,
+			[]string{
+				"1:28: error: cannot use string as int in argument 1 to f",
+				"1:62: error: type mismatch: string + int",
+			},
+		},
+		{
+			"annotations",
+			`let n: int = "a"; let f = fn(a: string) -> bool { a }; f(1); let h: {string: int} = {"a": "b"};`,
+			[]string{
+				"1:14: error: cannot use string as int in the declaration of n",
+				"1:51: error: cannot use string as bool in the return value of f",
+				"1:58: error: cannot use int as string in argument 1 to f",
+				"1:85: error: cannot use {string: string} as {string: int} in the declaration of h",
+			},
+		},
+		{
+			"redeclaration of an annotated variable",
+			`let n: int = 1; let n = "a";`,
+			[]string{"1:25: error: cannot use string as int in the declaration of n"},
+		},
+		{
+			"returns",
+			`let f = fn() -> int { if (true) { return "a"; } 1 }; let g = fn() -> int { let x = 1; };`,
+			[]string{
+				"1:42: error: cannot use string as int in the return value of f",
+				"1:87: error: cannot use null as int in the return value of g",
+			},
+		},
+		{
+			"calls",
+			"let f = fn(a) { a }; f(); 1(); f(1)(2); let apply = fn(g) { g(1) }; apply(fn(a, b) { a });",
+			[]string{
+				"1:22: error: f takes 1 argument, but is called with 0",
+				"1:27: error: not a function: int",
+				"1:32: error: not a function: int",
+				"1:75: error: cannot use fn('a, 'b) -> 'a as fn(int) -> 'c in argument 1 to apply",
+			},
+		},
+		{
+			"builtins",
+			`len(1, 2); split("a", 1); join([1], ","); first("a");`,
+			[]string{
+				"1:1: error: len takes 1 argument, but is called with 2",
+				"1:23: error: cannot use int as string in argument 2 to split",
+				"1:32: error: cannot use [int] as [string] in argument 1 to join",
+				"1:49: error: cannot use string as ['a] in argument 1 to first",
+			},
+		},
+		{
+			"indexes",
+			`[1]["a"]; {"a": 1}[1]; 1[0]; "abc"[true:]; true[:];`,
+			[]string{
+				"1:5: error: cannot use string as int in an index",
+				"1:20: error: cannot use int as string in a hash key",
+				"1:24: error: index operator not supported: int",
+				"1:36: error: cannot use bool as int in a slice bound",
+				"1:44: error: slice operator not supported: bool",
+			},
+		},
+		{
+			"unknown types",
+			"let f = fn(a: number) -> [str] { a };",
+			[]string{
+				"1:15: error: unknown type number",
+				"1:27: error: unknown type str",
+			},
+		},
+		{
+			"any",
+			`let f = fn(a: any) -> any { a + 1 }; f("a") + 1; let x: any = 1; x + "a";`,
+			nil,
+		},
+		{
+			"unannotated programs that run",
+			`let xs = [1, "a", true];
+let first_or = fn(xs, d) { if (len(xs) == 0) { return d; } first(xs) };
+first_or(xs, 0);
+let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+let ops = {"add": fn(a, b) { a + b }, "sub": fn(a, b) { a - b }};
+ops["add"](1, 2) + ops["sub"](3, 4);
+let data = json_parse("[1]");
+data[0] + 1;
+let v = 1;
+let v = "now a string";
+let greet = fn(name) { "hello " + name };
+greet(v);
+let total = reduce(map([1, 2], fn(x, i) { x * i }), fn(a, b) { a + b }, 0);
+puts(total, v, fn() { 1 });`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, errors := check(t, tt.src)
+			if !reflect.DeepEqual(errors, tt.expected) {
+				t.Errorf("got\n%q\nexpected\n%q", errors, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package types
+
+// scheme is a type that may be used at different types for its variables
+// in vars, like the type of a function bound by let.
+type scheme struct {
+	vars []*variable
+	typ  Type
+}
+
+// mono returns the scheme of a type that is used as it is.
+func mono(t Type) scheme {
+	return scheme{typ: t}
+}
+
+// change records the level of a variable before unify bound it or moved
+// it to another level, so tryUnify can undo that. Variables are unbound
+// until unify binds them.
+type change struct {
+	v     *variable
+	level int
+}
+
+func (c *Checker) fresh() *variable {
+	return &variable{level: c.level}
+}
+
+// tryUnify makes a and b the same type by binding their variables. If they
+// cannot be made the same it undoes what it bound and reports false.
+func (c *Checker) tryUnify(a, b Type) bool {
+	mark := len(c.trail)
+	if c.unify(a, b) {
+		return true
+	}
+
+	for i := len(c.trail) - 1; i >= mark; i-- {
+		ch := c.trail[i]
+		ch.v.level, ch.v.bound = ch.level, nil
+	}
+	c.trail = c.trail[:mark]
+
+	return false
+}
+
+func (c *Checker) unify(a, b Type) bool {
+	a, b = prune(a), prune(b)
+	if a == Any || b == Any {
+		return true
+	}
+
+	if v, ok := a.(*variable); ok {
+		return c.bind(v, b)
+	}
+	if v, ok := b.(*variable); ok {
+		return c.bind(v, a)
+	}
+
+	switch a := a.(type) {
+	case Basic:
+		return a == b
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && c.unify(a.Element, b.Element)
+	case *HashMap:
+		b, ok := b.(*HashMap)
+		return ok && c.unify(a.Key, b.Key) && c.unify(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Params) != len(b.Params) {
+			return false
+		}
+
+		for i := range a.Params {
+			if !c.unify(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+
+		return c.unify(a.Return, b.Return)
+	}
+
+	return false
+}
+
+// bind binds v to t unless t contains v. The variables in t move out to
+// the level of v, since t now belongs to the binding v belongs to.
+func (c *Checker) bind(v *variable, t Type) bool {
+	if t == v {
+		return true
+	}
+	if c.occurs(v, t) {
+		return false
+	}
+
+	c.adjust(t, v.level)
+	c.trail = append(c.trail, change{v: v, level: v.level})
+	v.bound = t
+
+	return true
+}
+
+func (c *Checker) occurs(v *variable, t Type) bool {
+	switch t := prune(t).(type) {
+	case *variable:
+		return t == v
+	case *Array:
+		return c.occurs(v, t.Element)
+	case *HashMap:
+		return c.occurs(v, t.Key) || c.occurs(v, t.Value)
+	case *Function:
+		for _, p := range t.Params {
+			if c.occurs(v, p) {
+				return true
+			}
+		}
+
+		return c.occurs(v, t.Return)
+	}
+
+	return false
+}
+
+func (c *Checker) adjust(t Type, level int) {
+	switch t := prune(t).(type) {
+	case *variable:
+		if t.level > level {
+			c.trail = append(c.trail, change{v: t, level: t.level})
+			t.level = level
+		}
+	case *Array:
+		c.adjust(t.Element, level)
+	case *HashMap:
+		c.adjust(t.Key, level)
+		c.adjust(t.Value, level)
+	case *Function:
+		for _, p := range t.Params {
+			c.adjust(p, level)
+		}
+		c.adjust(t.Return, level)
+	}
+}
+
+// join returns the type of a value that may come from any of ts. If they
+// have no common type, it is any. A nil type stands for a value that is
+// never produced, like that of a block that always returns, so it is
+// left out, and the join of nothing but nil is nil.
+func (c *Checker) join(ts ...Type) Type {
+	var joined Type
+	for _, t := range ts {
+		switch {
+		case t == nil:
+		case joined == nil:
+			joined = t
+		case !c.tryUnify(joined, t):
+			joined = Any
+		}
+	}
+
+	return joined
+}
+
+// generalize turns the variables of t created inside the current let
+// binding into variables of a scheme.
+func (c *Checker) generalize(t Type) scheme {
+	s := scheme{typ: t}
+	seen := make(map[*variable]bool)
+
+	var collect func(t Type)
+	collect = func(t Type) {
+		switch t := prune(t).(type) {
+		case *variable:
+			if t.level > c.level && !seen[t] {
+				seen[t] = true
+				s.vars = append(s.vars, t)
+			}
+		case *Array:
+			collect(t.Element)
+		case *HashMap:
+			collect(t.Key)
+			collect(t.Value)
+		case *Function:
+			for _, p := range t.Params {
+				collect(p)
+			}
+			collect(t.Return)
+		}
+	}
+	collect(t)
+
+	return s
+}
+
+// instantiate returns the type of s with fresh variables for those of s.
+func (c *Checker) instantiate(s scheme) Type {
+	if len(s.vars) == 0 {
+		return s.typ
+	}
+
+	fresh := make(map[*variable]Type, len(s.vars))
+	for _, v := range s.vars {
+		fresh[v] = c.fresh()
+	}
+
+	var substitute func(t Type) Type
+	substitute = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *variable:
+			if f, ok := fresh[t]; ok {
+				return f
+			}
+			return t
+		case *Array:
+			return &Array{Element: substitute(t.Element)}
+		case *HashMap:
+			return &HashMap{Key: substitute(t.Key), Value: substitute(t.Value)}
+		case *Function:
+			params := make([]Type, len(t.Params))
+			for i, p := range t.Params {
+				params[i] = substitute(p)
+			}
+			return &Function{Params: params, Return: substitute(t.Return)}
+		default:
+			return t
+		}
+	}
+
+	return substitute(s.typ)
+}